@@ -4,7 +4,6 @@ package engine
 import (
 	"context"
 	"fmt"
-	"net"
 	"runtime"
 	"sort"
 	"strings"
@@ -42,8 +41,23 @@ func (e *Engine) SetShowProgress(show bool) {
 	e.showProgress = show
 }
 
-// Scan runs the scanner against all target IPs concurrently.
+// Scan runs the scanner against all target IPs concurrently. Scanners
+// that implement scanner.BatchScanner scan all targets in one pass.
 func (e *Engine) Scan(ctx context.Context, targets []string) []scanner.Result {
+	if b, ok := e.scanner.(scanner.BatchScanner); ok {
+		var progress func(int)
+		if e.showProgress {
+			progress = func(done int) { printProgress(done, len(targets)) }
+		}
+		if results, ok := b.ScanAll(ctx, targets, progress); ok {
+			if e.showProgress {
+				fmt.Println() // Newline after progress bar
+			}
+			sortResults(results)
+			return results
+		}
+	}
+
 	var (
 		results []scanner.Result
 		mu      sync.Mutex
@@ -94,16 +108,18 @@ func (e *Engine) Scan(ctx context.Context, targets []string) []scanner.Result {
 		fmt.Println() // Newline after progress bar
 	}
 
-	// Sort results by IP address
-	sort.Slice(results, func(i, j int) bool {
-		ip1 := net.ParseIP(results[i].IP)
-		ip2 := net.ParseIP(results[j].IP)
-		return network.IPToUint32(ip1) < network.IPToUint32(ip2)
-	})
+	sortResults(results)
 
 	return results
 }
 
+// sortResults sorts results by IP address.
+func sortResults(results []scanner.Result) {
+	sort.Slice(results, func(i, j int) bool {
+		return network.LessIP(results[i].IP, results[j].IP)
+	})
+}
+
 // printProgress displays a progress bar.
 func printProgress(current, total int) {
 	percentage := float64(current) / float64(total) * 100
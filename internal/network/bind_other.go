@@ -0,0 +1,11 @@
+//go:build !linux
+
+package network
+
+import "errors"
+
+const canBindToDevice = false
+
+func bindToDevice(fd uintptr, iface string) error {
+	return errors.New("binding to an interface is only supported on Linux")
+}
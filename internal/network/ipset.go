@@ -0,0 +1,321 @@
+package network
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// ipRange is an inclusive range of addresses of a single family.
+type ipRange struct {
+	from, to netip.Addr
+}
+
+// IPSet is a set of IPv4 and IPv6 addresses stored as sorted, disjoint
+// ranges, so that large prefixes can be added, removed and counted
+// without enumerating them. The zero value is an empty set.
+type IPSet struct {
+	ranges []ipRange
+}
+
+// AddRange adds every address from from to to (inclusive) to the set.
+// Both ends must be of the same family; IPv4-mapped IPv6 addresses are
+// treated as IPv4.
+func (s *IPSet) AddRange(from, to netip.Addr) {
+	from, to = from.Unmap(), to.Unmap()
+	if from.Is4() != to.Is4() {
+		return
+	}
+	if to.Less(from) {
+		from, to = to, from
+	}
+
+	merged := ipRange{from, to}
+	out := make([]ipRange, 0, len(s.ranges)+1)
+	inserted := false
+	for _, r := range s.ranges {
+		switch {
+		case strictlyBefore(r, merged):
+			out = append(out, r)
+		case strictlyBefore(merged, r):
+			if !inserted {
+				out = append(out, merged)
+				inserted = true
+			}
+			out = append(out, r)
+		default:
+			// Overlapping or touching: absorb r into merged.
+			if r.from.Less(merged.from) {
+				merged.from = r.from
+			}
+			if merged.to.Less(r.to) {
+				merged.to = r.to
+			}
+		}
+	}
+	if !inserted {
+		out = append(out, merged)
+	}
+	s.ranges = out
+}
+
+// AddPrefix adds every address of p to the set.
+func (s *IPSet) AddPrefix(p netip.Prefix) {
+	from, to := prefixBounds(p)
+	s.AddRange(from, to)
+}
+
+// Add adds a single address to the set.
+func (s *IPSet) Add(ip netip.Addr) {
+	s.AddRange(ip, ip)
+}
+
+// AddSet adds every address of other to the set.
+func (s *IPSet) AddSet(other *IPSet) {
+	for _, r := range other.ranges {
+		s.AddRange(r.from, r.to)
+	}
+}
+
+// RemoveRange removes every address from from to to (inclusive).
+func (s *IPSet) RemoveRange(from, to netip.Addr) {
+	from, to = from.Unmap(), to.Unmap()
+	if from.Is4() != to.Is4() {
+		return
+	}
+	if to.Less(from) {
+		from, to = to, from
+	}
+
+	out := make([]ipRange, 0, len(s.ranges)+1)
+	for _, r := range s.ranges {
+		if r.from.Is4() != from.Is4() || r.to.Less(from) || to.Less(r.from) {
+			out = append(out, r)
+			continue
+		}
+		if r.from.Less(from) {
+			out = append(out, ipRange{r.from, from.Prev()})
+		}
+		if to.Less(r.to) {
+			out = append(out, ipRange{to.Next(), r.to})
+		}
+	}
+	s.ranges = out
+}
+
+// RemovePrefix removes every address of p from the set.
+func (s *IPSet) RemovePrefix(p netip.Prefix) {
+	from, to := prefixBounds(p)
+	s.RemoveRange(from, to)
+}
+
+// Remove removes a single address from the set.
+func (s *IPSet) Remove(ip netip.Addr) {
+	s.RemoveRange(ip, ip)
+}
+
+// RemoveSet removes every address of other from the set.
+func (s *IPSet) RemoveSet(other *IPSet) {
+	for _, r := range other.ranges {
+		s.RemoveRange(r.from, r.to)
+	}
+}
+
+// Contains reports whether ip is in the set.
+func (s *IPSet) Contains(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return !rangeBefore(s.ranges[i], ip)
+	})
+	return i < len(s.ranges) && s.ranges[i].from.Is4() == ip.Is4() && !ip.Less(s.ranges[i].from)
+}
+
+// Count returns the number of addresses in the set.
+func (s *IPSet) Count() *big.Int {
+	total := new(big.Int)
+	for _, r := range s.ranges {
+		n := new(big.Int).Sub(addrInt(r.to), addrInt(r.from))
+		total.Add(total, n.Add(n, big.NewInt(1)))
+	}
+	return total
+}
+
+// Split returns the IPv4 and IPv6 parts of the set.
+func (s *IPSet) Split() (v4, v6 *IPSet) {
+	v4, v6 = &IPSet{}, &IPSet{}
+	for _, r := range s.ranges {
+		if r.from.Is4() {
+			v4.ranges = append(v4.ranges, r)
+		} else {
+			v6.ranges = append(v6.ranges, r)
+		}
+	}
+	return v4, v6
+}
+
+// Prefixes returns the smallest list of CIDR prefixes that covers exactly
+// the addresses in the set, in ascending order.
+func (s *IPSet) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range s.ranges {
+		prefixes = append(prefixes, rangePrefixes(r.from, r.to)...)
+	}
+	return prefixes
+}
+
+// Addrs enumerates the addresses in the set in ascending order. It fails
+// rather than allocate when the set holds more than limit addresses.
+func (s *IPSet) Addrs(limit int) ([]string, error) {
+	count := s.Count()
+	if count.Cmp(big.NewInt(int64(limit))) > 0 {
+		return nil, fmt.Errorf("%s addresses is too many to enumerate (limit %d)", count, limit)
+	}
+
+	ips := make([]string, 0, count.Int64())
+	for _, r := range s.ranges {
+		for ip := r.from; ; ip = ip.Next() {
+			ips = append(ips, ip.String())
+			if ip == r.to {
+				break
+			}
+		}
+	}
+	return ips, nil
+}
+
+// Summary formats the set as comma-separated aggregated prefixes, with
+// single addresses written without a prefix length.
+func (s *IPSet) Summary() string {
+	prefixes := s.Prefixes()
+	parts := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsSingleIP() {
+			parts = append(parts, p.Addr().String())
+		} else {
+			parts = append(parts, p.String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseRangeSpec parses a single address, CIDR prefix or "from-to" range.
+// For prefixes, hostsOnly drops the addresses ParseSet does not count as
+// hosts (network/broadcast for IPv4, subnet-router anycast for IPv6).
+func parseRangeSpec(spec string, hostsOnly bool) (netip.Addr, netip.Addr, error) {
+	switch {
+	case strings.Contains(spec, "/"):
+		p, err := netip.ParsePrefix(spec)
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid CIDR notation: %v", err)
+		}
+		p = p.Masked()
+		from, to := prefixBounds(p)
+		bits := p.Bits()
+		if p.Addr().Is4In6() {
+			bits -= 96 // as an IPv4 prefix
+		}
+		if hostsOnly && bits < from.BitLen()-1 {
+			from = from.Next()
+			if from.Is4() {
+				to = to.Prev()
+			}
+		}
+		return from, to, nil
+	case strings.Contains(spec, "-"):
+		lo, hi, _ := strings.Cut(spec, "-")
+		from, err := netip.ParseAddr(strings.TrimSpace(lo))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range start: %q", lo)
+		}
+		to, err := netip.ParseAddr(strings.TrimSpace(hi))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range end: %q", hi)
+		}
+		from, to = from.Unmap(), to.Unmap()
+		if from.Is4() != to.Is4() {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("range %q mixes IPv4 and IPv6", spec)
+		}
+		return from, to, nil
+	default:
+		ip, err := netip.ParseAddr(spec)
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid IP address: %q", spec)
+		}
+		return ip.Unmap(), ip.Unmap(), nil
+	}
+}
+
+// prefixBounds returns the first and last address of p.
+func prefixBounds(p netip.Prefix) (netip.Addr, netip.Addr) {
+	p = p.Masked()
+	from := p.Addr().Unmap()
+	b := from.AsSlice()
+	bits := p.Bits()
+	if p.Addr().Is4In6() {
+		bits -= 96
+	}
+	for i := range b {
+		keep := bits - i*8
+		switch {
+		case keep <= 0:
+			b[i] = 0xff
+		case keep < 8:
+			b[i] |= 0xff >> keep
+		}
+	}
+	to, _ := netip.AddrFromSlice(b)
+	return from, to
+}
+
+// rangePrefixes splits [from, to] into the minimal list of prefixes.
+func rangePrefixes(from, to netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	bitLen := from.BitLen()
+	for {
+		// Grow the prefix while it stays aligned on from and inside the range.
+		bits := bitLen
+		for bits > 0 {
+			p := netip.PrefixFrom(from, bits-1)
+			if p.Masked().Addr() != from {
+				break
+			}
+			if _, last := prefixBounds(p); to.Less(last) {
+				break
+			}
+			bits--
+		}
+		p := netip.PrefixFrom(from, bits)
+		prefixes = append(prefixes, p)
+
+		_, last := prefixBounds(p)
+		if last == to {
+			return prefixes
+		}
+		from = last.Next()
+	}
+}
+
+// rangeBefore reports whether r lies entirely before ip.
+func rangeBefore(r ipRange, ip netip.Addr) bool {
+	if r.from.Is4() != ip.Is4() {
+		return r.from.Is4()
+	}
+	return r.to.Less(ip)
+}
+
+// strictlyBefore reports whether a ends before b starts with at least one
+// address between them, so the two cannot be merged.
+func strictlyBefore(a, b ipRange) bool {
+	if a.from.Is4() != b.from.Is4() {
+		return a.from.Is4()
+	}
+	next := a.to.Next()
+	return next.IsValid() && next.Less(b.from)
+}
+
+// addrInt returns ip as a big integer.
+func addrInt(ip netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(ip.AsSlice())
+}
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mustSet parses spec with ParseSet, failing the test on error.
+func mustSet(t *testing.T, spec string, hostsOnly bool) *IPSet {
+	t.Helper()
+	s, err := ParseSet(spec, hostsOnly)
+	if err != nil {
+		t.Fatalf("ParseSet(%q): %v", spec, err)
+	}
+	return s
+}
+
+func TestIPSetSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     string
+		remove  string
+		summary string
+		count   string
+	}{
+		{"single", "10.0.0.1", "", "10.0.0.1", "1"},
+		{"prefix", "10.0.0.0/24", "", "10.0.0.0/24", "256"},
+		{"touching ranges merge", "10.0.0.0-10.0.0.127, 10.0.0.128-10.0.0.255", "", "10.0.0.0/24", "256"},
+		{"overlapping ranges merge", "10.0.0.0/25, 10.0.0.100-10.0.0.200, 10.0.0.128/25", "", "10.0.0.0/24", "256"},
+		{"reversed range", "10.0.0.9-10.0.0.2", "", "10.0.0.2/31, 10.0.0.4/30, 10.0.0.8/31", "8"},
+		{"duplicates", "10.0.0.1, 10.0.0.1, 10.0.0.1/32", "", "10.0.0.1", "1"},
+		{"unaligned range", "10.0.0.1-10.0.0.6", "", "10.0.0.1, 10.0.0.2/31, 10.0.0.4/31, 10.0.0.6", "6"},
+		{"hole in the middle", "10.0.0.0/24", "10.0.0.128/26", "10.0.0.0/25, 10.0.0.192/26", "192"},
+		{"overlapping excludes", "10.0.0.0/24", "10.0.0.0/26, 10.0.0.32-10.0.0.100, 10.0.0.64/27", "10.0.0.101, 10.0.0.102/31, 10.0.0.104/29, 10.0.0.112/28, 10.0.0.128/25", "155"},
+		{"exclude everything", "10.0.0.0/24", "10.0.0.0/16", "", "0"},
+		{"exclude spanning ranges", "10.0.0.0/30, 10.0.0.8/30, 10.0.0.16/30", "10.0.0.2-10.0.0.17", "10.0.0.0/31, 10.0.0.18/31", "4"},
+		{"exclude other family", "10.0.0.0/30", "::/0", "10.0.0.0/30", "4"},
+		{"IPv4 /0", "0.0.0.0/0", "", "0.0.0.0/0", "4294967296"},
+		{"IPv4 /0 minus first and last", "0.0.0.0/0", "0.0.0.0, 255.255.255.255", "0.0.0.1, 0.0.0.2/31, 0.0.0.4/30, 0.0.0.8/29, 0.0.0.16/28, 0.0.0.32/27, 0.0.0.64/26, 0.0.0.128/25, 0.0.1.0/24, 0.0.2.0/23, 0.0.4.0/22, 0.0.8.0/21, 0.0.16.0/20, 0.0.32.0/19, 0.0.64.0/18, 0.0.128.0/17, 0.1.0.0/16, 0.2.0.0/15, 0.4.0.0/14, 0.8.0.0/13, 0.16.0.0/12, 0.32.0.0/11, 0.64.0.0/10, 0.128.0.0/9, 1.0.0.0/8, 2.0.0.0/7, 4.0.0.0/6, 8.0.0.0/5, 16.0.0.0/4, 32.0.0.0/3, 64.0.0.0/2, 128.0.0.0/2, 192.0.0.0/3, 224.0.0.0/4, 240.0.0.0/5, 248.0.0.0/6, 252.0.0.0/7, 254.0.0.0/8, 255.0.0.0/9, 255.128.0.0/10, 255.192.0.0/11, 255.224.0.0/12, 255.240.0.0/13, 255.248.0.0/14, 255.252.0.0/15, 255.254.0.0/16, 255.255.0.0/17, 255.255.128.0/18, 255.255.192.0/19, 255.255.224.0/20, 255.255.240.0/21, 255.255.248.0/22, 255.255.252.0/23, 255.255.254.0/24, 255.255.255.0/25, 255.255.255.128/26, 255.255.255.192/27, 255.255.255.224/28, 255.255.255.240/29, 255.255.255.248/30, 255.255.255.252/31, 255.255.255.254", "4294967294"},
+		{"IPv6 /0", "::/0", "", "::/0", "340282366920938463463374607431768211456"},
+		{"IPv6 /128", "2001:db8::1/128", "", "2001:db8::1", "1"},
+		{"IPv6 top of space", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0/124", "16"},
+		{"IPv4-mapped address", "::ffff:10.0.0.1, 10.0.0.2", "", "10.0.0.1, 10.0.0.2", "2"},
+		{"IPv4-mapped prefix", "::ffff:10.0.0.0/120", "", "10.0.0.0/24", "256"},
+		{"IPv4-mapped exclude", "10.0.0.0/30", "::ffff:10.0.0.0/127", "10.0.0.2/31", "2"},
+		{"mixed families", "2001:db8::/126, 10.0.0.0/31", "", "10.0.0.0/31, 2001:db8::/126", "6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustSet(t, tt.add, false)
+			if tt.remove != "" {
+				s.RemoveSet(mustSet(t, tt.remove, false))
+			}
+			if got := s.Summary(); got != tt.summary {
+				t.Errorf("Summary() = %q, want %q", got, tt.summary)
+			}
+			if got := s.Count().String(); got != tt.count {
+				t.Errorf("Count() = %s, want %s", got, tt.count)
+			}
+		})
+	}
+}
+
+func TestIPSetContains(t *testing.T) {
+	s := mustSet(t, "10.0.0.0/24, 192.168.1.10-192.168.1.20, 2001:db8::/120", false)
+	s.Remove(netip.MustParseAddr("192.168.1.15"))
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.0", true},
+		{"10.0.0.255", true},
+		{"10.0.1.0", false},
+		{"9.255.255.255", false},
+		{"192.168.1.10", true},
+		{"192.168.1.15", false},
+		{"192.168.1.20", true},
+		{"192.168.1.21", false},
+		{"::ffff:10.0.0.7", true},
+		{"2001:db8::ff", true},
+		{"2001:db8::100", false},
+		{"::a00:7", false}, // 10.0.0.7's bits, but IPv6
+		{"255.255.255.255", false},
+	}
+	for _, tt := range tests {
+		if got := s.Contains(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	if (&IPSet{}).Contains(netip.MustParseAddr("10.0.0.1")) {
+		t.Error("empty set contains 10.0.0.1")
+	}
+}
+
+func TestIPSetAddrs(t *testing.T) {
+	s := mustSet(t, "10.0.0.254-10.0.1.1, 2001:db8::ffff-2001:db8::1:0", false)
+	got, err := s.Addrs(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1", "2001:db8::ffff", "2001:db8::1:0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Addrs() = %v, want %v", got, want)
+	}
+	if _, err := s.Addrs(5); err == nil {
+		t.Error("Addrs(5) of 6 addresses succeeded")
+	}
+	if _, err := mustSet(t, "::/0", false).Addrs(MaxTargets); err == nil {
+		t.Error("Addrs of ::/0 succeeded")
+	}
+}
+
+func TestIPSetSplit(t *testing.T) {
+	v4, v6 := mustSet(t, "10.0.0.0/30, 2001:db8::/127, 10.0.0.8", false).Split()
+	if v4.Summary() != "10.0.0.0/30, 10.0.0.8" || v6.Summary() != "2001:db8::/127" {
+		t.Errorf("Split() = %q, %q", v4.Summary(), v6.Summary())
+	}
+	if v4.Count().Cmp(big.NewInt(5)) != 0 || v6.Count().Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("Split() counts = %s, %s", v4.Count(), v6.Count())
+	}
+}
+
+func TestParseSetHostsOnly(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"10.0.0.0/24", "10.0.0.1-10.0.0.254"},
+		{"10.0.0.77/24", "10.0.0.1-10.0.0.254"},
+		{"10.0.0.0/31", "10.0.0.0-10.0.0.1"},
+		{"10.0.0.5/32", "10.0.0.5"},
+		{"::ffff:10.0.0.0/120", "10.0.0.1-10.0.0.254"},
+		{"2001:db8::/120", "2001:db8::1-2001:db8::ff"},
+		{"2001:db8::/127", "2001:db8::-2001:db8::1"},
+		{"2001:db8::1/128", "2001:db8::1"},
+		{"10.0.0.0-10.0.0.255", "10.0.0.0-10.0.0.255"},
+	}
+	for _, tt := range tests {
+		got := mustSet(t, tt.spec, true)
+		want := mustSet(t, tt.want, false)
+		if got.Summary() != want.Summary() {
+			t.Errorf("ParseSet(%q, true) = %q, want %q", tt.spec, got.Summary(), want.Summary())
+		}
+	}
+}
+
+func TestParseSetErrors(t *testing.T) {
+	for _, spec := range []string{
+		"10.0.0.0/33",
+		"2001:db8::/129",
+		"10.0.0.256",
+		"10.0.0.1-2001:db8::1",
+		"10.0.0.1-",
+		"-10.0.0.1",
+		"host.example",
+	} {
+		if _, err := ParseSet(spec, true); err == nil {
+			t.Errorf("ParseSet(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		exclude string
+		want    string // first, last and count, or the error prefix
+	}{
+		{"subnet", "192.168.1.0/24", "", "192.168.1.1 192.168.1.254 254"},
+		{"excludes", "192.168.1.0/24", "192.168.1.1, 192.168.1.200-192.168.1.254", "192.168.1.2 192.168.1.199 198"},
+		{"excluded prefix removed in full", "192.168.1.0/24", "192.168.1.0/25", "192.168.1.128 192.168.1.254 127"},
+		{"sorted across families", "2001:db8::/126, 10.0.0.5, 10.0.0.1", "", "10.0.0.1 2001:db8::3 5"},
+		{"everything excluded", "10.0.0.0/30", "10.0.0.0/24", "no targets specified"},
+		{"IPv6 too large", "2001:db8::/64", "", "18446744073709551615 IPv6 addresses is too many"},
+		{"IPv6 /112 allowed", "2001:db8::/112", "", "2001:db8::1 2001:db8::ffff 65535"},
+		{"IPv4-mapped prefix", "::ffff:10.0.0.0/120", "", "10.0.0.1 10.0.0.254 254"},
+		{"IPv4-mapped /96 too large", "::ffff:0.0.0.0/96", "", "4294967294 addresses is too many"},
+		{"bad exclusion", "10.0.0.0/30", "nope", "invalid exclusion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, err := ParseTargets(tt.spec, tt.exclude)
+			var got string
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = strings.Join([]string{ips[0], ips[len(ips)-1], big.NewInt(int64(len(ips))).String()}, " ")
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("ParseTargets(%q, %q) = %q, want %q", tt.spec, tt.exclude, got, tt.want)
+			}
+		})
+	}
+}
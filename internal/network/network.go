@@ -2,46 +2,168 @@
 package network
 
 import (
-	"encoding/binary"
+	"bytes"
 	"fmt"
+	"math/big"
 	"net"
+	"strings"
 )
 
-// ParseCIDR parses a CIDR notation and returns all usable host IP addresses.
-func ParseCIDR(cidr string) ([]string, error) {
-	ip, ipnet, err := net.ParseCIDR(cidr)
+// MaxIPv6HostBits is the largest number of host bits an IPv6 prefix may
+// have before ParseTargets refuses to enumerate it. A /112 (65536 addresses)
+// is the widest IPv6 range that can be swept address by address; anything
+// wider (such as a /64) has to be discovered by other means.
+const MaxIPv6HostBits = 16
+
+// MaxTargets is the largest number of addresses ParseTargets will expand
+// a target specification into.
+const MaxTargets = 1 << 24
+
+// ParseTargets parses a comma-separated list of CIDR prefixes, "from-to"
+// ranges and single IPv4/IPv6 addresses, removes everything matched by
+// exclude (same syntax, may be empty), and returns the remaining host
+// addresses in ascending order. Prefixes contribute the hosts ParseSet
+// keeps with hostsOnly; excluded prefixes are removed in full.
+func ParseTargets(spec, exclude string) ([]string, error) {
+	targets, err := ParseSet(spec, true)
 	if err != nil {
-		return nil, fmt.Errorf("invalid CIDR notation: %v", err)
+		return nil, err
 	}
-
-	var ips []string
-	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-		ips = append(ips, ip.String())
+	if exclude != "" {
+		excluded, err := ParseSet(exclude, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclusion: %v", err)
+		}
+		targets.RemoveSet(excluded)
 	}
 
-	// Remove network address and broadcast address for typical subnets
-	if len(ips) > 2 {
-		ips = ips[1 : len(ips)-1]
+	_, v6 := targets.Split()
+	if v6.Count().Cmp(big.NewInt(1<<MaxIPv6HostBits)) > 0 {
+		return nil, fmt.Errorf("%s IPv6 addresses is too many to enumerate (max %d)", v6.Count(), 1<<MaxIPv6HostBits)
 	}
 
+	ips, err := targets.Addrs(MaxTargets)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no targets specified")
+	}
 	return ips, nil
 }
 
-// incrementIP increments an IP address by one.
-func incrementIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
+// ParseSet parses a comma-separated list of CIDR prefixes, "from-to"
+// ranges and single addresses into an IPSet. With hostsOnly, prefixes
+// leave out the addresses that are not hosts: the network and broadcast
+// addresses of IPv4 prefixes and the subnet-router anycast address of
+// IPv6 prefixes. IPv4-mapped prefixes count as IPv4.
+func ParseSet(spec string, hostsOnly bool) (*IPSet, error) {
+	set := &IPSet{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, err := parseRangeSpec(part, hostsOnly)
+		if err != nil {
+			return nil, err
+		}
+		set.AddRange(from, to)
+	}
+	return set, nil
+}
+
+// IsIPv6 reports whether ip is a textual IPv6 address (as opposed to an
+// IPv4 or IPv4-mapped address). A zone suffix is allowed.
+func IsIPv6(ip string) bool {
+	parsed := net.ParseIP(stripZone(ip))
+	return parsed != nil && parsed.To4() == nil
+}
+
+// SplitFamilies splits a list of addresses into IPv4 and IPv6 addresses,
+// preserving order. Zoned IPv6 addresses keep their zone. Unparseable
+// entries are dropped.
+func SplitFamilies(ips []string) (v4, v6 []string) {
+	for _, ip := range ips {
+		parsed := net.ParseIP(stripZone(ip))
+		switch {
+		case parsed == nil:
+			continue
+		case parsed.To4() != nil:
+			v4 = append(v4, ip)
+		default:
+			v6 = append(v6, ip)
 		}
 	}
+	return v4, v6
+}
+
+// CompareIP compares two IP addresses as 128-bit numbers and returns -1, 0
+// or +1. IPv4 addresses sort before IPv6 addresses.
+func CompareIP(a, b net.IP) int {
+	a4, b4 := a.To4(), b.To4()
+	switch {
+	case a4 != nil && b4 != nil:
+		return bytes.Compare(a4, b4)
+	case a4 != nil:
+		return -1
+	case b4 != nil:
+		return 1
+	}
+	return bytes.Compare(a.To16(), b.To16())
+}
+
+// LessIP reports whether the textual address a sorts before b. A zone
+// suffix ("fe80::1%eth0") is ignored except as a tie-breaker. Addresses
+// that fail to parse fall back to plain string comparison.
+func LessIP(a, b string) bool {
+	ipA := net.ParseIP(stripZone(a))
+	ipB := net.ParseIP(stripZone(b))
+	if ipA == nil || ipB == nil {
+		return a < b
+	}
+	if c := CompareIP(ipA, ipB); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// stripZone removes an IPv6 zone suffix ("%eth0") from ip.
+func stripZone(ip string) string {
+	if i := strings.IndexByte(ip, '%'); i >= 0 {
+		return ip[:i]
+	}
+	return ip
+}
+
+// InferInitialTTL guesses the TTL a packet was sent with from the TTL it
+// arrived with, assuming the common defaults of 64 (Linux, macOS, Unix),
+// 128 (Windows) and 255 (network equipment, Solaris). It returns the
+// initial TTL and the implied number of hops, or zeros for ttl <= 0.
+func InferInitialTTL(ttl int) (initial, hops int) {
+	switch {
+	case ttl <= 0:
+		return 0, 0
+	case ttl <= 64:
+		initial = 64
+	case ttl <= 128:
+		initial = 128
+	default:
+		initial = 255
+	}
+	return initial, initial - ttl
 }
 
-// IPToUint32 converts an IPv4 address to uint32 for sorting purposes.
-func IPToUint32(ip net.IP) uint32 {
-	ip = ip.To4()
-	if ip == nil {
-		return 0
+// OSFamilyFromTTL returns a rough OS family guess for an initial TTL as
+// returned by InferInitialTTL.
+func OSFamilyFromTTL(initial int) string {
+	switch initial {
+	case 64:
+		return "Linux/Unix"
+	case 128:
+		return "Windows"
+	case 255:
+		return "Network device"
 	}
-	return binary.BigEndian.Uint32(ip)
+	return ""
 }
@@ -0,0 +1,35 @@
+package network
+
+import (
+	"strings"
+	"syscall"
+)
+
+// SetTTL sets the IPv4 TTL (or the IPv6 unicast hop limit when v6 is
+// true) of packets sent on c.
+func SetTTL(c syscall.Conn, v6 bool, ttl int) error {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return controlTTL(raw, v6, ttl)
+}
+
+// TTLControl returns a net.Dialer Control function that sets the TTL of
+// the socket before it connects.
+func TTLControl(ttl int) func(network, address string, c syscall.RawConn) error {
+	return func(network, _ string, c syscall.RawConn) error {
+		return controlTTL(c, strings.HasSuffix(network, "6"), ttl)
+	}
+}
+
+func controlTTL(raw syscall.RawConn, v6 bool, ttl int) error {
+	var sockErr error
+	err := raw.Control(func(fd uintptr) {
+		sockErr = setTTL(fd, v6, ttl)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,243 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Source pins where probes leave from: a local address, a source port
+// and an interface. The zero value lets the kernel choose all three.
+type Source struct {
+	IP        net.IP
+	Port      int
+	Interface string
+}
+
+// ParseSource parses a source address ("10.0.0.5", "10.0.0.5:53",
+// "[fe80::1]:53" or ":53") and an interface name, either of which may be
+// empty. The address must belong to this machine and the interface must
+// exist. networks lists the protocols the source will send ("tcp",
+// "udp"); a socket is bound from the source for each, so that a port held
+// by another program or reserved for root is reported now rather than as
+// filtered ports later.
+func ParseSource(addr, iface string, networks ...string) (Source, error) {
+	var src Source
+	addr = strings.TrimSpace(addr)
+	if addr != "" {
+		host, port := addr, ""
+		if h, p, err := net.SplitHostPort(addr); err == nil {
+			host, port = h, p
+		}
+		if host != "" {
+			src.IP = net.ParseIP(host)
+			if src.IP == nil {
+				return Source{}, fmt.Errorf("invalid source address %q", host)
+			}
+			if !isLocal(src.IP) {
+				return Source{}, fmt.Errorf("%s is not an address of this machine", src.IP)
+			}
+		}
+		if port != "" {
+			n, err := strconv.Atoi(port)
+			if err != nil || n < 1 || n > 65535 {
+				return Source{}, fmt.Errorf("invalid source port %q", port)
+			}
+			src.Port = n
+		}
+	}
+
+	src.Interface = strings.TrimSpace(iface)
+	if src.Interface != "" {
+		if !canBindToDevice {
+			return Source{}, errors.New("binding to an interface is only supported on Linux")
+		}
+		if _, err := net.InterfaceByName(src.Interface); err != nil {
+			return Source{}, fmt.Errorf("interface %s: %v", src.Interface, err)
+		}
+	}
+
+	if src.IP != nil || src.Port != 0 {
+		for _, netw := range networks {
+			if err := src.checkBind(netw); err != nil {
+				return Source{}, fmt.Errorf("cannot send from %s: %v", src, err)
+			}
+		}
+	}
+	return src, nil
+}
+
+// checkBind binds and closes a socket of the network from s, with the
+// same options as probes use.
+func (s Source) checkBind(netw string) error {
+	host := ""
+	if s.IP != nil {
+		host = s.IP.String()
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(s.Port))
+	lc := net.ListenConfig{Control: s.Control}
+	if strings.HasPrefix(netw, "udp") {
+		c, err := lc.ListenPacket(context.Background(), netw, addr)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+	}
+	l, err := lc.Listen(context.Background(), netw, addr)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// IsBindError reports whether err is a dial that failed to bind its
+// source address or port, e.g. because a listener holds the port
+// (EADDRINUSE) or it is below 1024 and maki is not root (EACCES). Such a
+// failure says nothing about the target.
+func IsBindError(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		return false
+	}
+	var sysErr *os.SyscallError
+	return errors.As(err, &sysErr) && sysErr.Syscall == "bind"
+}
+
+// isLocal reports whether ip is assigned to one of this machine's
+// interfaces.
+func isLocal(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return true // let bind report it
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsZero reports whether s leaves every choice to the kernel.
+func (s Source) IsZero() bool {
+	return s.IP == nil && s.Port == 0 && s.Interface == ""
+}
+
+// String describes s, e.g. "10.0.0.5:53 on eth0.20".
+func (s Source) String() string {
+	var out string
+	switch {
+	case s.Port != 0:
+		host := ""
+		if s.IP != nil {
+			host = s.IP.String()
+		}
+		out = net.JoinHostPort(host, strconv.Itoa(s.Port))
+	case s.IP != nil:
+		out = s.IP.String()
+	}
+	if s.Interface != "" {
+		out = strings.TrimSpace(out + " on " + s.Interface)
+	}
+	return out
+}
+
+// LocalIP returns the source address to use toward dst, or nil when none
+// is set for dst's address family.
+func (s Source) LocalIP(dst net.IP) net.IP {
+	if s.IP == nil || (s.IP.To4() == nil) != (dst.To4() == nil) {
+		return nil
+	}
+	return s.IP
+}
+
+// Control is a net.Dialer or net.ListenConfig Control function that binds
+// the socket to the interface and, when a source port is set, lets several
+// sockets share it. TCP sockets sharing a port also reset on close instead
+// of lingering in TIME_WAIT, which would block the next connection from
+// that port to the same target.
+func (s Source) Control(network, _ string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if s.Port != 0 {
+			if sockErr = setReuseAddr(fd); sockErr != nil {
+				return
+			}
+			if strings.HasPrefix(network, "tcp") {
+				if sockErr = setLingerZero(fd); sockErr != nil {
+					return
+				}
+			}
+		}
+		if s.Interface != "" {
+			sockErr = bindToDevice(fd, s.Interface)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// Bind applies s to an open connection: only the interface can be set
+// after the socket exists.
+func (s Source) Bind(c syscall.Conn) error {
+	if s.Interface == "" {
+		return nil
+	}
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = bindToDevice(fd, s.Interface)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// Dialer returns a dialer that connects from s. It satisfies
+// probe.Dialer.
+func (s Source) Dialer(timeout time.Duration) *SourceDialer {
+	return &SourceDialer{Source: s, Timeout: timeout}
+}
+
+// SourceDialer dials TCP and UDP connections from a Source. The source
+// address is only used for destinations of its address family.
+type SourceDialer struct {
+	Source  Source
+	Timeout time.Duration
+}
+
+// DialContext connects to address from d's source.
+func (d *SourceDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	nd := net.Dialer{Timeout: d.Timeout}
+	if d.Source.IsZero() {
+		return nd.DialContext(ctx, network, address)
+	}
+	nd.Control = d.Source.Control
+
+	var ip net.IP
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		if dst := net.ParseIP(host); dst != nil {
+			ip = d.Source.LocalIP(dst)
+		}
+	}
+	if ip != nil || d.Source.Port != 0 {
+		if strings.HasPrefix(network, "udp") {
+			nd.LocalAddr = &net.UDPAddr{IP: ip, Port: d.Source.Port}
+		} else {
+			nd.LocalAddr = &net.TCPAddr{IP: ip, Port: d.Source.Port}
+		}
+	}
+	return nd.DialContext(ctx, network, address)
+}
@@ -0,0 +1,183 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// freePort returns a port that nothing listens on for the network.
+func freePort(t *testing.T, netw string) int {
+	t.Helper()
+	if strings.HasPrefix(netw, "udp") {
+		c, err := net.ListenPacket(netw, "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		return c.LocalAddr().(*net.UDPAddr).Port
+	}
+	l, err := net.Listen(netw, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestParseSource(t *testing.T) {
+	port := freePort(t, "tcp")
+	tests := []struct {
+		addr, iface string
+		want        string // String() of the source, or the error prefix
+	}{
+		{"", "", ""},
+		{"127.0.0.1", "", "127.0.0.1"},
+		{" 127.0.0.1:" + strconv.Itoa(port) + " ", "", "127.0.0.1:" + strconv.Itoa(port)},
+		{":" + strconv.Itoa(port), "", ":" + strconv.Itoa(port)},
+		{"localhost", "", `invalid source address "localhost"`},
+		{"192.0.2.254", "", "192.0.2.254 is not an address of this machine"},
+		{":0", "", `invalid source port "0"`},
+		{"127.0.0.1:65536", "", `invalid source port "65536"`},
+		{":http", "", `invalid source port "http"`},
+		{"", "nosuchif0", "interface nosuchif0"},
+	}
+	if canBindToDevice {
+		tests = append(tests, struct{ addr, iface, want string }{"127.0.0.1", "lo", "127.0.0.1 on lo"})
+	}
+	for _, tt := range tests {
+		src, err := ParseSource(tt.addr, tt.iface, "tcp", "udp")
+		got := src.String()
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.HasPrefix(got, tt.want) || (tt.want == "" && got != "") {
+			t.Errorf("ParseSource(%q, %q) = %q, want %q", tt.addr, tt.iface, got, tt.want)
+		}
+	}
+}
+
+func TestParseSourceBusyPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tcpPort := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	udpPort := strconv.Itoa(c.LocalAddr().(*net.UDPAddr).Port)
+
+	tests := []struct {
+		addr    string
+		network string
+	}{
+		{":" + tcpPort, "tcp"},
+		{"127.0.0.1:" + tcpPort, "tcp"},
+		{":" + udpPort, "udp"},
+	}
+	for _, tt := range tests {
+		_, err := ParseSource(tt.addr, "", tt.network)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) && !strings.Contains(err.Error(), "address already in use") {
+			t.Errorf("ParseSource(%q, %s) error = %v, want address in use", tt.addr, tt.network, err)
+		}
+	}
+
+	// Without the network the port is not checked: ICMP ignores it.
+	if _, err := ParseSource(":"+tcpPort, ""); err != nil {
+		t.Errorf("ParseSource(:%s) without networks: %v", tcpPort, err)
+	}
+}
+
+func TestSourceDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	port := freePort(t, "tcp")
+	d := Source{IP: net.ParseIP("127.0.0.1"), Port: port}.Dialer(0)
+	for i := 0; i < 2; i++ {
+		// The port is reused at once: the first connection is reset, not
+		// left in TIME_WAIT.
+		conn, err := d.DialContext(context.Background(), "tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		if got := conn.LocalAddr().(*net.TCPAddr).Port; got != port {
+			t.Errorf("dial %d: local port %d, want %d", i, got, port)
+		}
+		conn.Close()
+	}
+
+	// An IPv4 source address is not used toward IPv6 targets.
+	if v6, err := net.Listen("tcp", "[::1]:0"); err == nil {
+		defer v6.Close()
+		conn, err := Source{IP: net.ParseIP("127.0.0.1")}.Dialer(0).DialContext(context.Background(), "tcp", v6.Addr().String())
+		if err != nil {
+			t.Errorf("dial IPv6 from an IPv4 source: %v", err)
+		} else {
+			conn.Close()
+		}
+	}
+
+	// A port held by a listener cannot be bound, and the error says so.
+	busy := Source{Port: l.Addr().(*net.TCPAddr).Port}.Dialer(0)
+	_, err = busy.DialContext(context.Background(), "tcp", l.Addr().String())
+	if !IsBindError(err) {
+		t.Errorf("dial from a listening port: error %v is not a bind error", err)
+	}
+}
+
+func TestIsBindError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"address in use", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}, true},
+		{"privileged port", &net.OpError{Op: "dial", Net: "udp", Err: os.NewSyscallError("bind", syscall.EACCES)}, true},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{"connect address in use", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EADDRINUSE)}, false},
+		{"listen", &net.OpError{Op: "listen", Net: "tcp", Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}, false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsBindError(tt.err); got != tt.want {
+			t.Errorf("IsBindError(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSourcePrivilegedPort(t *testing.T) {
+	if os.Geteuid() <= 0 {
+		t.Skip("needs an unprivileged user")
+	}
+	if b, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start"); err == nil && strings.TrimSpace(string(b)) == "0" {
+		t.Skip("unprivileged users may bind any port")
+	}
+	for _, netw := range []string{"tcp", "udp"} {
+		if _, err := ParseSource(":1", "", netw); err == nil {
+			t.Errorf("ParseSource(:1, %s) succeeded without privileges", netw)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package nmap
+
+import (
+	"path/filepath"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/output"
+	"maki/internal/scanner"
+)
+
+// FromReport builds the frontend JSON report from maki's own scan
+// results, so the web viewer can be used without running nmap. Every
+// host found alive by any scan is included.
+func FromReport(scan *output.Report) *Report {
+	report := &Report{
+		Subnet:    scan.Subnet,
+		Timestamp: scan.Timestamp,
+		Command:   "maki",
+	}
+
+	hostnames := scan.Hostnames()
+	byIP := make(map[string]*Host)
+	for _, ip := range scan.UniqueHosts() {
+		report.Hosts = append(report.Hosts, Host{
+			IP:       ip,
+			Hostname: hostnames[ip],
+			Status:   "up",
+			Ports:    []Port{},
+		})
+	}
+	for i := range report.Hosts {
+		byIP[report.Hosts[i].IP] = &report.Hosts[i]
+	}
+
+	for _, data := range scan.Scans {
+		for _, r := range data.Results {
+			host, ok := byIP[r.IP]
+			if !ok {
+				continue
+			}
+			if host.MAC == "" {
+				host.MAC = r.MAC
+			}
+			if host.OS == "" && r.TTL > 0 {
+				initial, _ := network.InferInitialTTL(r.TTL)
+				host.OS = network.OSFamilyFromTTL(initial)
+			}
+			if len(host.Hops) == 0 && len(r.Hops) > 0 {
+				host.Hops = convertHops(r.IP, r.Hops, hostnames)
+			}
+			for _, p := range r.Ports {
+				if p.State == scanner.PortOpen && !hasPort(host.Ports, p) {
+					host.Ports = append(host.Ports, Port{
+						Port:     p.Number,
+						Protocol: p.Protocol,
+						State:    string(p.State),
+						Service:  p.Service,
+						Product:  p.Product,
+						Version:  p.Version,
+						Extra:    p.Banner,
+						HTTP:     convertHTTP(p.HTTP),
+					})
+				}
+			}
+		}
+	}
+
+	return report
+}
+
+// Export writes FromReport(scan) as maki.json into outputDir and returns
+// the file path. The file has the same shape as nmap.json.
+func Export(scan *output.Report, outputDir string) (string, error) {
+	path := filepath.Join(outputDir, "maki.json")
+	if err := writeJSON(FromReport(scan), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// convertHops converts traceroute hops to the JSON shape, dropping the
+// final hop when it is the host itself.
+func convertHops(ip string, hops []scanner.Hop, hostnames map[string]string) []Hop {
+	out := make([]Hop, 0, len(hops))
+	for _, h := range hops {
+		if h.IP == ip {
+			continue
+		}
+		out = append(out, Hop{
+			TTL:      h.TTL,
+			IP:       h.IP,
+			Hostname: hostnames[h.IP],
+			RTT:      float64(h.RTT) / float64(time.Millisecond),
+		})
+	}
+	return out
+}
+
+// convertHTTP converts a web fingerprint to the JSON shape.
+func convertHTTP(h *scanner.HTTPInfo) *HTTP {
+	if h == nil {
+		return nil
+	}
+	return &HTTP{
+		URL:         h.URL,
+		Status:      h.Status,
+		Server:      h.Server,
+		Title:       h.Title,
+		Redirect:    h.Redirect,
+		FaviconHash: h.FaviconHash,
+	}
+}
+
+// hasPort reports whether ports already lists p.
+func hasPort(ports []Port, p scanner.Port) bool {
+	for _, existing := range ports {
+		if existing.Port == p.Number && existing.Protocol == p.Protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeNative fills in hostnames, MACs and hops that nmap did not report
+// from maki's own results.
+func mergeNative(report, native *Report) {
+	byIP := make(map[string]Host, len(native.Hosts))
+	for _, h := range native.Hosts {
+		byIP[h.IP] = h
+	}
+
+	for i := range report.Hosts {
+		host := &report.Hosts[i]
+		n, ok := byIP[host.IP]
+		if !ok {
+			continue
+		}
+		if host.Hostname == "" {
+			host.Hostname = n.Hostname
+		}
+		if host.MAC == "" {
+			host.MAC = n.MAC
+		}
+		if len(host.Hops) == 0 {
+			host.Hops = n.Hops
+		}
+	}
+}
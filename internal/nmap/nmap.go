@@ -9,8 +9,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"maki/internal/network"
 	"maki/internal/output"
 )
 
@@ -23,6 +25,19 @@ type Port struct {
 	Product  string `json:"product,omitempty"`
 	Version  string `json:"version,omitempty"`
 	Extra    string `json:"extra_info,omitempty"`
+
+	// HTTP is maki's web fingerprint of the port; nmap does not set it.
+	HTTP *HTTP `json:"http,omitempty"`
+}
+
+// HTTP is the JSON shape of a web server fingerprint.
+type HTTP struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	Server      string `json:"server,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Redirect    string `json:"redirect,omitempty"`
+	FaviconHash string `json:"favicon_hash,omitempty"`
 }
 
 // Host is the JSON shape exposed to the frontend.
@@ -35,6 +50,19 @@ type Host struct {
 	OS         string `json:"os,omitempty"`
 	OSAccuracy int    `json:"os_accuracy,omitempty"`
 	Ports      []Port `json:"ports"`
+
+	// Hops lists the routers between the scanner and the host, nearest
+	// first; unanswered hops have an empty IP. The host itself is not
+	// included, so directly connected hosts have no hops.
+	Hops []Hop `json:"hops,omitempty"`
+}
+
+// Hop is the JSON shape of one router on the path to a host.
+type Hop struct {
+	TTL      int     `json:"ttl"`
+	IP       string  `json:"ip,omitempty"`
+	Hostname string  `json:"hostname,omitempty"`
+	RTT      float64 `json:"rtt_ms,omitempty"`
 }
 
 // Report is the top-level JSON document written to disk.
@@ -58,6 +86,7 @@ type xmlHost struct {
 	Hostnames xmlHostnames `xml:"hostnames"`
 	Ports     xmlPorts     `xml:"ports"`
 	OS        xmlOS        `xml:"os"`
+	Trace     xmlTrace     `xml:"trace"`
 }
 
 type xmlStatus struct {
@@ -109,10 +138,28 @@ type xmlOSMatch struct {
 	Accuracy int    `xml:"accuracy,attr"`
 }
 
+type xmlTrace struct {
+	Hops []xmlHop `xml:"hop"`
+}
+
+type xmlHop struct {
+	TTL    int     `xml:"ttl,attr"`
+	IPAddr string  `xml:"ipaddr,attr"`
+	RTT    float64 `xml:"rtt,attr"`
+	Host   string  `xml:"host,attr"`
+}
+
 // Run executes `nmap -A -F -iL hostsFile`, writes the XML report and a
 // processed JSON report into outputDir, and returns the parsed report
 // along with the JSON path.
-func Run(hostsFile, outputDir, subnet string) (*Report, string, error) {
+//
+// nmap cannot mix address families in one run, so IPv6 hosts from the
+// list are scanned in a second `nmap -6` pass whose XML goes to
+// nmap6.xml; both passes are merged into the single JSON report.
+//
+// Details maki found itself (hostnames, MACs, traceroute hops) are
+// merged into hosts for which nmap did not report them.
+func Run(hostsFile, outputDir, subnet string, scan *output.Report) (*Report, string, error) {
 	info, err := os.Stat(hostsFile)
 	if err != nil {
 		return nil, "", fmt.Errorf("hosts file not found: %v", err)
@@ -125,35 +172,107 @@ func Run(hostsFile, outputDir, subnet string) (*Report, string, error) {
 		return nil, "", fmt.Errorf("nmap is not installed or not in PATH")
 	}
 
-	xmlPath := filepath.Join(outputDir, "nmap.xml")
+	hostsData, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read hosts file: %v", err)
+	}
+	v4, v6 := network.SplitFamilies(strings.Fields(string(hostsData)))
+
 	jsonPath := filepath.Join(outputDir, "nmap.json")
 
-	cmd := exec.Command("nmap", "-A", "-F", "-iL", hostsFile, "-oX", xmlPath)
+	report := &Report{
+		Subnet:    subnet,
+		Timestamp: time.Now(),
+		Hosts:     make([]Host, 0, len(v4)+len(v6)),
+	}
+
+	var commands []string
+
+	if len(v4) > 0 {
+		args := []string{"-A", "-F"}
+		if len(v6) > 0 {
+			// Mixed list: feed only the IPv4 entries to this pass.
+			args = append(args, "-iL", "-")
+		} else {
+			args = append(args, "-iL", hostsFile)
+		}
+		parsed, err := runNmap(args, v4, filepath.Join(outputDir, "nmap.xml"))
+		if err != nil {
+			return nil, "", err
+		}
+		commands = append(commands, "nmap "+parsed.Args)
+		report.Hosts = append(report.Hosts, convertHosts(parsed.Hosts)...)
+	}
+
+	if len(v6) > 0 {
+		args := []string{"-6", "-A", "-F", "-iL", "-"}
+		parsed, err := runNmap(args, v6, filepath.Join(outputDir, "nmap6.xml"))
+		if err != nil {
+			return nil, "", err
+		}
+		commands = append(commands, "nmap "+parsed.Args)
+		report.Hosts = append(report.Hosts, convertHosts(parsed.Hosts)...)
+	}
+
+	report.Command = strings.Join(commands, " && ")
+
+	if scan != nil {
+		mergeNative(report, FromReport(scan))
+	}
+
+	if err := writeJSON(report, jsonPath); err != nil {
+		return nil, "", err
+	}
+
+	return report, jsonPath, nil
+}
+
+// writeJSON writes report to path as indented JSON.
+func writeJSON(report *Report, path string) error {
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal JSON: %v", err)
+	}
+	if err := os.WriteFile(path, jsonData, 0644); err != nil {
+		return fmt.Errorf("cannot write JSON: %v", err)
+	}
+	_ = output.ChownToInvokingUser(path)
+	return nil
+}
+
+// runNmap runs nmap with args plus `-oX xmlPath` and parses the XML it
+// writes. When args read the target list from stdin (`-iL -`), stdinHosts
+// is piped to nmap one address per line.
+func runNmap(args, stdinHosts []string, xmlPath string) (*xmlNmaprun, error) {
+	args = append(args, "-oX", xmlPath)
+
+	cmd := exec.Command("nmap", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	cmd.Stdin = strings.NewReader(strings.Join(stdinHosts, "\n") + "\n")
 	if err := cmd.Run(); err != nil {
-		return nil, "", fmt.Errorf("nmap failed: %v", err)
+		return nil, fmt.Errorf("nmap failed: %v", err)
 	}
 	_ = output.ChownToInvokingUser(xmlPath)
 
 	xmlData, err := os.ReadFile(xmlPath)
 	if err != nil {
-		return nil, "", fmt.Errorf("cannot read nmap XML: %v", err)
+		return nil, fmt.Errorf("cannot read nmap XML: %v", err)
 	}
 
 	var parsed xmlNmaprun
 	if err := xml.Unmarshal(xmlData, &parsed); err != nil {
-		return nil, "", fmt.Errorf("cannot parse nmap XML: %v", err)
+		return nil, fmt.Errorf("cannot parse nmap XML: %v", err)
 	}
 
-	report := &Report{
-		Subnet:    subnet,
-		Timestamp: time.Now(),
-		Command:   "nmap " + parsed.Args,
-		Hosts:     make([]Host, 0, len(parsed.Hosts)),
-	}
+	return &parsed, nil
+}
 
-	for _, h := range parsed.Hosts {
+// convertHosts maps parsed nmap XML hosts to the frontend JSON shape.
+func convertHosts(xmlHosts []xmlHost) []Host {
+	hosts := make([]Host, 0, len(xmlHosts))
+
+	for _, h := range xmlHosts {
 		host := Host{
 			Status: h.Status.State,
 			Ports:  make([]Port, 0, len(h.Ports.Ports)),
@@ -180,6 +299,17 @@ func Run(hostsFile, outputDir, subnet string) (*Report, string, error) {
 			host.OS = best.Name
 			host.OSAccuracy = best.Accuracy
 		}
+		for _, hop := range h.Trace.Hops {
+			if hop.IPAddr == host.IP {
+				continue
+			}
+			host.Hops = append(host.Hops, Hop{
+				TTL:      hop.TTL,
+				IP:       hop.IPAddr,
+				Hostname: hop.Host,
+				RTT:      hop.RTT,
+			})
+		}
 		for _, p := range h.Ports.Ports {
 			host.Ports = append(host.Ports, Port{
 				Port:     p.PortID,
@@ -191,17 +321,8 @@ func Run(hostsFile, outputDir, subnet string) (*Report, string, error) {
 				Extra:    p.Service.Extra,
 			})
 		}
-		report.Hosts = append(report.Hosts, host)
+		hosts = append(hosts, host)
 	}
 
-	jsonData, err := json.MarshalIndent(report, "", "  ")
-	if err != nil {
-		return nil, "", fmt.Errorf("cannot marshal JSON: %v", err)
-	}
-	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
-		return nil, "", fmt.Errorf("cannot write JSON: %v", err)
-	}
-	_ = output.ChownToInvokingUser(jsonPath)
-
-	return report, jsonPath, nil
+	return hosts
 }
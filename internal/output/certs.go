@@ -0,0 +1,135 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// CertExpiryWarning is how close to its expiry a certificate is reported
+// as expiring soon.
+const CertExpiryWarning = 30 * 24 * time.Hour
+
+// CertIssue is a certificate found on a port that is expired, expiring
+// soon, not yet valid or self-signed.
+type CertIssue struct {
+	IP      string
+	Port    int
+	Cert    *scanner.Certificate
+	Problem string // e.g. "expires in 12 days, self-signed"
+}
+
+// CertificateIssues lists the problem certificates in results as of now,
+// soonest expiry first. A port found by several scans is listed once.
+func CertificateIssues(results []scanner.Result, now time.Time) []CertIssue {
+	var issues []CertIssue
+	for _, cp := range certPorts(results) {
+		var problems []string
+		if p := validityProblem(cp.port.Cert, now); p != "" {
+			problems = append(problems, p)
+		}
+		if cp.port.Cert.SelfSigned {
+			problems = append(problems, "self-signed")
+		}
+		if len(problems) > 0 {
+			issues = append(issues, CertIssue{IP: cp.ip, Port: cp.port.Number, Cert: cp.port.Cert, Problem: strings.Join(problems, ", ")})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Cert.NotAfter.Before(issues[j].Cert.NotAfter)
+	})
+	return issues
+}
+
+// FormatCertificate summarizes a certificate on one line, e.g.
+// "CN=example.com, issuer CN=R11, RSA 2048, expires 2027-01-12".
+func FormatCertificate(c *scanner.Certificate) string {
+	s := c.Subject
+	if len(c.SANs) > 0 {
+		s += fmt.Sprintf(" (SANs: %s)", strings.Join(c.SANs, ", "))
+	}
+	if c.SelfSigned {
+		s += ", self-signed"
+	} else {
+		s += ", issuer " + c.Issuer
+	}
+	return fmt.Sprintf("%s, %s, expires %s", s, c.KeyType, c.NotAfter.Format("2006-01-02"))
+}
+
+// validityProblem describes why cert is not valid at now, or whether it
+// expires within CertExpiryWarning, or returns "".
+func validityProblem(cert *scanner.Certificate, now time.Time) string {
+	switch left := cert.NotAfter.Sub(now); {
+	case now.Before(cert.NotBefore):
+		return "not valid before " + cert.NotBefore.Format("2006-01-02")
+	case left < 0:
+		return fmt.Sprintf("expired %s ago", formatDays(-left))
+	case left < CertExpiryWarning:
+		return fmt.Sprintf("expires in %s", formatDays(left))
+	}
+	return ""
+}
+
+// formatDays formats d in whole days, e.g. "1 day" or "12 days", or in
+// whole hours when it is less than a day, so that a certificate expiring
+// tonight does not read as "0 days".
+func formatDays(d time.Duration) string {
+	n, unit := int(d/(24*time.Hour)), "day"
+	if n == 0 {
+		n, unit = int(d/time.Hour), "hour"
+	}
+	if n == 0 {
+		return "less than an hour"
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// certPorts collects the ports in results with a certificate, once per IP
+// and port, ordered by IP and port.
+func certPorts(results []scanner.Result) []hostPort {
+	return portsWith(results, func(p scanner.Port) bool { return p.Cert != nil })
+}
+
+// writeCertsCSV writes every certificate in results, one per IP and
+// port, with the days left until it expires as of now.
+func writeCertsCSV(path string, results []scanner.Result, hostnames map[string]string, now time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"ip", "hostname", "port", "subject", "sans", "issuer", "not_before", "not_after", "days_left", "key_type", "sha256", "self_signed"})
+	for _, cp := range certPorts(results) {
+		c := cp.port.Cert
+		_ = w.Write([]string{
+			cp.ip,
+			hostnames[cp.ip],
+			strconv.Itoa(cp.port.Number),
+			c.Subject,
+			strings.Join(c.SANs, " "),
+			c.Issuer,
+			c.NotBefore.UTC().Format(time.RFC3339),
+			c.NotAfter.UTC().Format(time.RFC3339),
+			strconv.Itoa(int(c.NotAfter.Sub(now).Hours() / 24)),
+			c.KeyType,
+			c.Fingerprint,
+			strconv.FormatBool(c.SelfSigned),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,179 @@
+package output
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/csv"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"maki/internal/probe/tlsinfo"
+	"maki/internal/scanner"
+)
+
+// testNow is the time the certificate tests check validity at.
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// certFactory creates certificates signed by a test CA, or self-signed.
+type certFactory struct {
+	t      *testing.T
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+func newCertFactory(t *testing.T) *certFactory {
+	f := &certFactory{t: t}
+	f.caKey = f.key()
+	f.ca = f.create("Test CA", testNow.AddDate(-1, 0, 0), testNow.AddDate(5, 0, 0), true)
+	return f
+}
+
+func (f *certFactory) key() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return key
+}
+
+// create returns a certificate for name valid from notBefore to notAfter,
+// self-signed when selfSigned is set and signed by the CA otherwise.
+func (f *certFactory) create(name string, notBefore, notAfter time.Time, selfSigned bool) *x509.Certificate {
+	f.t.Helper()
+	f.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(f.serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		DNSNames:              []string{name},
+		BasicConstraintsValid: true,
+		IsCA:                  f.ca == nil,
+	}
+	key, parent, parentKey := f.caKey, f.ca, f.caKey
+	if f.ca != nil {
+		key = f.key()
+	}
+	if selfSigned {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return cert
+}
+
+// describe returns the summary of a certificate like the TLS probe's.
+func (f *certFactory) describe(name string, notBefore, notAfter time.Time, selfSigned bool) *scanner.Certificate {
+	return tlsinfo.Describe(f.create(name, notBefore, notAfter, selfSigned))
+}
+
+func TestValidityProblem(t *testing.T) {
+	f := newCertFactory(t)
+	issued := testNow.AddDate(0, -3, 0)
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		want      string
+	}{
+		{"valid", issued, testNow.AddDate(1, 0, 0), ""},
+		{"at the warning", issued, testNow.Add(CertExpiryWarning), ""},
+		{"within the warning", issued, testNow.Add(CertExpiryWarning - time.Hour), "expires in 29 days"},
+		{"in 12 days", issued, testNow.Add(12*24*time.Hour + 3*time.Hour), "expires in 12 days"},
+		{"tomorrow", issued, testNow.Add(30 * time.Hour), "expires in 1 day"},
+		{"tonight", issued, testNow.Add(5*time.Hour + 59*time.Minute), "expires in 5 hours"},
+		{"in an hour", issued, testNow.Add(90 * time.Minute), "expires in 1 hour"},
+		{"in minutes", issued, testNow.Add(10 * time.Minute), "expires in less than an hour"},
+		{"just expired", issued, testNow.Add(-10 * time.Minute), "expired less than an hour ago"},
+		{"expired this morning", issued, testNow.Add(-3 * time.Hour), "expired 3 hours ago"},
+		{"expired yesterday", issued, testNow.Add(-25 * time.Hour), "expired 1 day ago"},
+		{"expired long ago", issued.AddDate(-2, 0, 0), testNow.Add(-40 * 24 * time.Hour), "expired 40 days ago"},
+		{"not yet valid", testNow.Add(48 * time.Hour), testNow.AddDate(1, 0, 0), "not valid before 2025-06-03"},
+	}
+	for _, tt := range tests {
+		cert := f.describe("www.example.com", tt.notBefore, tt.notAfter, false)
+		if got := validityProblem(cert, testNow); got != tt.want {
+			t.Errorf("%s: validityProblem() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCertificateIssues(t *testing.T) {
+	f := newCertFactory(t)
+	issued := testNow.AddDate(0, -3, 0)
+	good := f.describe("good.example.com", issued, testNow.AddDate(1, 0, 0), false)
+	nas := f.describe("nas.local", issued, testNow.AddDate(10, 0, 0), true)
+	expired := f.describe("old.local", issued.AddDate(-1, 0, 0), testNow.AddDate(0, 0, -40), true)
+	soon := f.describe("soon.example.com", issued, testNow.AddDate(0, 0, 12), false)
+	future := f.describe("new.example.com", testNow.AddDate(0, 0, 2), testNow.AddDate(1, 0, 0), false)
+
+	results := []scanner.Result{
+		{IP: "10.0.0.5", Ports: []scanner.Port{{Number: 443, Cert: good}, {Number: 5001, Cert: nas}}},
+		{IP: "10.0.0.2", Ports: []scanner.Port{{Number: 8443, Cert: soon}, {Number: 443, Cert: expired}, {Number: 80}}},
+		{IP: "10.0.0.7", Ports: []scanner.Port{{Number: 443, Cert: future}}},
+		// The same port again from another scan.
+		{IP: "10.0.0.2", Ports: []scanner.Port{{Number: 443, Cert: expired}}},
+	}
+	want := []CertIssue{
+		{IP: "10.0.0.2", Port: 443, Cert: expired, Problem: "expired 40 days ago, self-signed"},
+		{IP: "10.0.0.2", Port: 8443, Cert: soon, Problem: "expires in 12 days"},
+		{IP: "10.0.0.7", Port: 443, Cert: future, Problem: "not valid before 2025-06-03"},
+		{IP: "10.0.0.5", Port: 5001, Cert: nas, Problem: "self-signed"},
+	}
+	if got := CertificateIssues(results, testNow); !reflect.DeepEqual(got, want) {
+		t.Errorf("CertificateIssues() =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := CertificateIssues(results[:1], testNow.AddDate(0, -6, 0)); len(got) != 2 {
+		t.Errorf("CertificateIssues() before the certificates were issued = %+v, want both", got)
+	}
+}
+
+func TestWriteCertsCSV(t *testing.T) {
+	f := newCertFactory(t)
+	issued := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	ca := f.describe("www.example.com", issued, time.Date(2025, 6, 13, 0, 0, 0, 0, time.UTC), false)
+	self := f.describe("nas.local", issued, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), true)
+	ca.SANs = append(ca.SANs, "example.com")
+
+	path := filepath.Join(t.TempDir(), "certs.csv")
+	results := []scanner.Result{
+		{IP: "10.0.0.9", Ports: []scanner.Port{{Number: 5001, Cert: self}}},
+		{IP: "10.0.0.2", Ports: []scanner.Port{{Number: 443, Cert: ca}, {Number: 22}}},
+		{IP: "10.0.0.9", Ports: []scanner.Port{{Number: 5001, Cert: self}}},
+	}
+	if err := writeCertsCSV(path, results, map[string]string{"10.0.0.2": "www"}, testNow); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"ip", "hostname", "port", "subject", "sans", "issuer", "not_before", "not_after", "days_left", "key_type", "sha256", "self_signed"},
+		{"10.0.0.2", "www", "443", "CN=www.example.com", "www.example.com example.com", "CN=Test CA", "2025-03-01T00:00:00Z", "2025-06-13T00:00:00Z", "11", "ECDSA P-256", ca.Fingerprint, "false"},
+		{"10.0.0.9", "", "5001", "CN=nas.local", "nas.local", "CN=nas.local", "2025-03-01T00:00:00Z", "2025-05-01T00:00:00Z", "-31", "ECDSA P-256", self.Fingerprint, "true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("certs.csv =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package output
+
+import (
+	"strings"
+
+	"maki/internal/scanner"
+)
+
+// ExposedService is a database or cache that answered without
+// credentials.
+type ExposedService struct {
+	IP       string
+	Port     int
+	Exposure *scanner.Exposure
+}
+
+// ExposedServices lists the services in results that answered without
+// credentials, ordered by IP and port. A port found by several scans is
+// listed once.
+func ExposedServices(results []scanner.Result) []ExposedService {
+	exposed := portsWith(results, func(p scanner.Port) bool {
+		return p.Exposure != nil && p.Exposure.Unauthenticated
+	})
+	var out []ExposedService
+	for _, hp := range exposed {
+		out = append(out, ExposedService{IP: hp.ip, Port: hp.port.Number, Exposure: hp.port.Exposure})
+	}
+	return out
+}
+
+// FormatExposure summarizes an exposure check on one line, e.g.
+// "redis 7.2.4 answers without authentication: 1520 keys" or
+// "mongodb 7.0.5 requires authentication".
+func FormatExposure(e *scanner.Exposure) string {
+	s := strings.TrimSpace(e.Service + " " + e.Version)
+	if !e.Unauthenticated {
+		s += " requires authentication"
+		if e.Detail != "" {
+			s += " (" + e.Detail + ")"
+		}
+		return s
+	}
+	s += " answers without authentication"
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
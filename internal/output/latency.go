@@ -0,0 +1,92 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/scanner"
+)
+
+// LatencySort selects the order of latency monitoring results.
+type LatencySort string
+
+const (
+	SortByIP     LatencySort = "ip"
+	SortByLoss   LatencySort = "loss"
+	SortByAvg    LatencySort = "avg"
+	SortByMax    LatencySort = "max"
+	SortByJitter LatencySort = "jitter"
+)
+
+// SortLatency orders results by the given key, worst first, breaking ties
+// by IP. Results without latency statistics sort last.
+func SortLatency(results []scanner.Result, key LatencySort) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].Latency, results[j].Latency
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil {
+			var x, y float64
+			switch key {
+			case SortByLoss:
+				x, y = a.Loss(), b.Loss()
+			case SortByAvg:
+				x, y = float64(a.Avg), float64(b.Avg)
+			case SortByMax:
+				x, y = float64(a.Max), float64(b.Max)
+			case SortByJitter:
+				x, y = float64(a.Jitter), float64(b.Jitter)
+			}
+			if x != y {
+				return x > y
+			}
+		}
+		return network.LessIP(results[i].IP, results[j].IP)
+	})
+}
+
+// writeLatencyCSV writes the statistics of every host that answered at
+// least one probe, in the order of results.
+func writeLatencyCSV(path string, results []scanner.Result, hostnames map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"ip", "hostname", "sent", "received", "loss_pct", "min_ms", "avg_ms", "max_ms", "jitter_ms"})
+	for _, r := range results {
+		l := r.Latency
+		if l == nil || l.Received == 0 {
+			continue
+		}
+		_ = w.Write([]string{
+			r.IP,
+			hostnames[r.IP],
+			strconv.Itoa(l.Sent),
+			strconv.Itoa(l.Received),
+			strconv.FormatFloat(l.Loss(), 'f', 1, 64),
+			formatMillis(l.Min),
+			formatMillis(l.Avg),
+			formatMillis(l.Max),
+			formatMillis(l.Jitter),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// formatMillis formats d in milliseconds with microsecond precision.
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,78 @@
+package output
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// latencyResults returns hosts with distinct loss, average, maximum and
+// jitter, one that never answered and one without statistics.
+func latencyResults() []scanner.Result {
+	ms := time.Millisecond
+	return []scanner.Result{
+		{IP: "10.0.0.10", Latency: &scanner.Latency{Sent: 10, Received: 10, Min: 1 * ms, Avg: 2 * ms, Max: 9 * ms, Jitter: 3 * ms}},
+		{IP: "10.0.0.9", Latency: &scanner.Latency{Sent: 10, Received: 5, Min: 4 * ms, Avg: 5 * ms, Max: 6 * ms, Jitter: 1 * ms}},
+		{IP: "10.0.0.2"},
+		{IP: "10.0.0.11", Latency: &scanner.Latency{Sent: 10}},
+		{IP: "2001:db8::1", Latency: &scanner.Latency{Sent: 10, Received: 9, Min: 1500 * time.Microsecond, Avg: 2 * ms, Max: 3 * ms, Jitter: 250 * time.Microsecond}},
+		{IP: "10.0.0.3", Latency: &scanner.Latency{Sent: 10, Received: 10, Min: 1 * ms, Avg: 2 * ms, Max: 9 * ms, Jitter: 3 * ms}},
+	}
+}
+
+func TestSortLatency(t *testing.T) {
+	tests := []struct {
+		key  LatencySort
+		want []string
+	}{
+		// The host that never answered has zero statistics but 100% loss.
+		{SortByLoss, []string{"10.0.0.11", "10.0.0.9", "2001:db8::1", "10.0.0.3", "10.0.0.10", "10.0.0.2"}},
+		{SortByAvg, []string{"10.0.0.9", "10.0.0.3", "10.0.0.10", "2001:db8::1", "10.0.0.11", "10.0.0.2"}},
+		{SortByMax, []string{"10.0.0.3", "10.0.0.10", "10.0.0.9", "2001:db8::1", "10.0.0.11", "10.0.0.2"}},
+		{SortByJitter, []string{"10.0.0.3", "10.0.0.10", "10.0.0.9", "2001:db8::1", "10.0.0.11", "10.0.0.2"}},
+		{SortByIP, []string{"10.0.0.3", "10.0.0.9", "10.0.0.10", "10.0.0.11", "2001:db8::1", "10.0.0.2"}},
+	}
+	for _, tt := range tests {
+		results := latencyResults()
+		SortLatency(results, tt.key)
+		var got []string
+		for _, r := range results {
+			got = append(got, r.IP)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SortLatency(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestWriteLatencyCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latency.csv")
+	if err := writeLatencyCSV(path, latencyResults(), map[string]string{"10.0.0.9": "nas"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hosts that never answered are left out; the order is kept.
+	want := [][]string{
+		{"ip", "hostname", "sent", "received", "loss_pct", "min_ms", "avg_ms", "max_ms", "jitter_ms"},
+		{"10.0.0.10", "", "10", "10", "0.0", "1.000", "2.000", "9.000", "3.000"},
+		{"10.0.0.9", "nas", "10", "5", "50.0", "4.000", "5.000", "6.000", "1.000"},
+		{"2001:db8::1", "", "10", "9", "10.0", "1.500", "2.000", "3.000", "0.250"},
+		{"10.0.0.3", "", "10", "10", "0.0", "1.000", "2.000", "9.000", "3.000"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("latency.csv =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -2,9 +2,9 @@
 package output
 
 import (
-	"bytes"
 	"fmt"
 	"net"
+	"net/netip"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"maki/internal/network"
 	"maki/internal/scanner"
 )
 
@@ -20,10 +21,15 @@ import (
 type ScanType string
 
 const (
-	ScanTypeICMP ScanType = "ICMP_SCAN"
-	ScanTypeTCP  ScanType = "TCP_SCAN"
-	ScanTypeARP  ScanType = "ARP_SCAN"
-	ScanTypeDNS  ScanType = "DNS_SCAN"
+	ScanTypeICMP    ScanType = "ICMP_SCAN"
+	ScanTypeTCP     ScanType = "TCP_SCAN"
+	ScanTypeTCPPing ScanType = "TCP_PING"
+	ScanTypeUDP     ScanType = "UDP_SCAN"
+	ScanTypeARP     ScanType = "ARP_SCAN"
+	ScanTypeDNS     ScanType = "DNS_SCAN"
+	ScanTypeNDP     ScanType = "NDP_SCAN"
+	ScanTypeTrace   ScanType = "TRACEROUTE"
+	ScanTypeLatency ScanType = "LATENCY_MONITOR"
 )
 
 // ScanData holds results for a specific scan type.
@@ -37,6 +43,11 @@ type Report struct {
 	Subnet    string
 	Timestamp time.Time
 	Scans     []ScanData
+
+	// HostKeyChanges lists SSH host keys that differ from those a
+	// previous run recorded in the output directory. SaveToFile fills it
+	// in before writing result.txt.
+	HostKeyChanges []HostKeyChange
 }
 
 // NewReport creates a new report for the given subnet.
@@ -65,6 +76,8 @@ func (r *Report) Format() string {
 	sb.WriteString(fmt.Sprintf("Scan time: %s\n", r.Timestamp.Format("2006-01-02 15:04:05")))
 	sb.WriteString(strings.Repeat("-", 50) + "\n\n")
 
+	hostnames := r.Hostnames()
+
 	// Each scan section
 	for _, scan := range r.Scans {
 		sb.WriteString(fmt.Sprintf("%s:\n", scan.Type))
@@ -73,11 +86,21 @@ func (r *Report) Format() string {
 		for _, result := range scan.Results {
 			if result.Alive {
 				aliveCount++
-				// Format: IP address followed by details if available
+				// Format: IP address, hostname if known, then details if available
+				host := result.IP
+				if name := hostnames[result.IP]; name != "" {
+					host = fmt.Sprintf("%s [%s]", result.IP, name)
+				}
 				if result.Details != "" && result.Details != "No response" {
-					sb.WriteString(fmt.Sprintf("%s (%s)\n", result.IP, result.Details))
+					sb.WriteString(fmt.Sprintf("%s (%s)\n", host, result.Details))
 				} else {
-					sb.WriteString(fmt.Sprintf("%s\n", result.IP))
+					sb.WriteString(fmt.Sprintf("%s\n", host))
+				}
+				for _, p := range DescribedPorts(result.Ports) {
+					sb.WriteString(fmt.Sprintf("    %s\n", FormatPort(p)))
+					for _, line := range PortDetails(p) {
+						sb.WriteString(fmt.Sprintf("        %s\n", line))
+					}
 				}
 			}
 		}
@@ -89,6 +112,37 @@ func (r *Report) Format() string {
 		sb.WriteString("\n")
 	}
 
+	// Certificates expiring soon or self-signed, across all scans
+	if certs := certPorts(r.allResults()); len(certs) > 0 {
+		sb.WriteString("CERTIFICATE ISSUES:\n")
+		issues := CertificateIssues(r.allResults(), r.Timestamp)
+		for _, issue := range issues {
+			sb.WriteString(fmt.Sprintf("%s %s: %s\n", net.JoinHostPort(issue.IP, strconv.Itoa(issue.Port)), issue.Problem, issue.Cert.Subject))
+		}
+		if len(issues) == 0 {
+			sb.WriteString(fmt.Sprintf("No expired, expiring or self-signed certificates (%d checked)\n", len(certs)))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Databases and caches answering without credentials, across all scans
+	if exposed := ExposedServices(r.allResults()); len(exposed) > 0 {
+		sb.WriteString("UNAUTHENTICATED SERVICES:\n")
+		for _, e := range exposed {
+			sb.WriteString(fmt.Sprintf("%s %s\n", net.JoinHostPort(e.IP, strconv.Itoa(e.Port)), FormatExposure(e.Exposure)))
+		}
+		sb.WriteString("\n")
+	}
+
+	// SSH host keys that changed since the previous run
+	if len(r.HostKeyChanges) > 0 {
+		sb.WriteString("SSH HOST KEY CHANGES:\n")
+		for _, c := range r.HostKeyChanges {
+			sb.WriteString(fmt.Sprintf("%s %s: %s -> %s\n", c.Host, c.Type, c.OldFingerprint, c.NewFingerprint))
+		}
+		sb.WriteString("\n")
+	}
+
 	// Summary
 	sb.WriteString(strings.Repeat("-", 50) + "\n")
 	sb.WriteString("SUMMARY:\n")
@@ -96,15 +150,45 @@ func (r *Report) Format() string {
 		alive := countAlive(scan.Results)
 		sb.WriteString(fmt.Sprintf("  %s: %d hosts alive\n", scan.Type, alive))
 	}
+	if hosts := r.UniqueHosts(); len(hosts) > 0 {
+		sb.WriteString(fmt.Sprintf("  Alive hosts (%d): %s\n", len(hosts), summarizeHosts(hosts)))
+	}
 
 	return sb.String()
 }
 
+// Hostnames returns the hostname recorded for each IP across all scans
+// in the report.
+func (r *Report) Hostnames() map[string]string {
+	names := make(map[string]string)
+	for _, scan := range r.Scans {
+		for _, result := range scan.Results {
+			if result.Hostname != "" {
+				names[result.IP] = result.Hostname
+			}
+		}
+	}
+	return names
+}
+
+// allResults returns the results of every scan in the report.
+func (r *Report) allResults() []scanner.Result {
+	var all []scanner.Result
+	for _, scan := range r.Scans {
+		all = append(all, scan.Results...)
+	}
+	return all
+}
+
 // UniqueHosts returns a sorted, deduplicated list of IPs that were
-// found alive across all scans in the report.
+// found alive across all scans in the report. Reverse DNS results are
+// not counted: a PTR record does not prove the host is up.
 func (r *Report) UniqueHosts() []string {
 	seen := make(map[string]struct{})
 	for _, scan := range r.Scans {
+		if scan.Type == ScanTypeDNS {
+			continue
+		}
 		for _, result := range scan.Results {
 			if result.Alive {
 				seen[result.IP] = struct{}{}
@@ -118,12 +202,7 @@ func (r *Report) UniqueHosts() []string {
 	}
 
 	sort.Slice(hosts, func(i, j int) bool {
-		ipI := net.ParseIP(hosts[i])
-		ipJ := net.ParseIP(hosts[j])
-		if ipI == nil || ipJ == nil {
-			return hosts[i] < hosts[j]
-		}
-		return bytes.Compare(ipI.To16(), ipJ.To16()) < 0
+		return network.LessIP(hosts[i], hosts[j])
 	})
 
 	return hosts
@@ -165,6 +244,16 @@ func (r *Report) SaveToFile(dirPath string) (string, error) {
 		}
 	}
 
+	// Compare SSH host keys with those recorded by a previous run, so
+	// that changes are reported in result.txt.
+	all := r.allResults()
+	knownHostsPath := filepath.Join(dirPath, "known_hosts")
+	known, err := readKnownHosts(knownHostsPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot read known hosts file: %v", err)
+	}
+	r.HostKeyChanges = hostKeyChanges(all, known)
+
 	// Create the result file
 	filePath := filepath.Join(dirPath, "result.txt")
 
@@ -191,6 +280,59 @@ func (r *Report) SaveToFile(dirPath string) (string, error) {
 		_ = os.Chown(hostsPath, uid, gid)
 	}
 
+	// Write latency.csv when the report holds monitoring results.
+	for _, scan := range r.Scans {
+		if scan.Type != ScanTypeLatency {
+			continue
+		}
+		latencyPath := filepath.Join(dirPath, "latency.csv")
+		if err := writeLatencyCSV(latencyPath, scan.Results, r.Hostnames()); err != nil {
+			return "", fmt.Errorf("cannot write latency file: %v", err)
+		}
+		if hasSudoOwner {
+			_ = os.Chown(latencyPath, uid, gid)
+		}
+		break
+	}
+
+	// Write certs.csv when the TLS probe found any certificates.
+	if len(certPorts(all)) > 0 {
+		certsPath := filepath.Join(dirPath, "certs.csv")
+		if err := writeCertsCSV(certsPath, all, r.Hostnames(), r.Timestamp); err != nil {
+			return "", fmt.Errorf("cannot write certificates file: %v", err)
+		}
+		if hasSudoOwner {
+			_ = os.Chown(certsPath, uid, gid)
+		}
+	}
+
+	// Write tls.csv when the TLS audit ran on any port.
+	if len(tlsPorts(all)) > 0 {
+		tlsPath := filepath.Join(dirPath, "tls.csv")
+		if err := writeTLSCSV(tlsPath, all, r.Hostnames()); err != nil {
+			return "", fmt.Errorf("cannot write TLS audit file: %v", err)
+		}
+		if hasSudoOwner {
+			_ = os.Chown(tlsPath, uid, gid)
+		}
+	}
+
+	// Write ssh.csv and update known_hosts when the SSH probe found any
+	// servers.
+	if len(sshPorts(all)) > 0 {
+		sshPath := filepath.Join(dirPath, "ssh.csv")
+		if err := writeSSHCSV(sshPath, all, r.Hostnames()); err != nil {
+			return "", fmt.Errorf("cannot write SSH file: %v", err)
+		}
+		if err := writeKnownHosts(knownHostsPath, all, known); err != nil {
+			return "", fmt.Errorf("cannot write known hosts file: %v", err)
+		}
+		if hasSudoOwner {
+			_ = os.Chown(sshPath, uid, gid)
+			_ = os.Chown(knownHostsPath, uid, gid)
+		}
+	}
+
 	return filePath, nil
 }
 
@@ -253,6 +395,132 @@ func missingPathComponents(p string) []string {
 	return missing
 }
 
+// summarizeHosts aggregates a list of addresses into the minimal set of
+// CIDR prefixes, e.g. "192.168.1.1, 192.168.1.2/31, 192.168.1.4/30".
+func summarizeHosts(hosts []string) string {
+	set := &network.IPSet{}
+	var unparsed []string
+	for _, h := range hosts {
+		ip, err := netip.ParseAddr(h)
+		if err != nil {
+			unparsed = append(unparsed, h)
+			continue
+		}
+		set.Add(ip.WithZone(""))
+	}
+
+	summary := set.Summary()
+	if len(unparsed) > 0 {
+		if summary != "" {
+			summary += ", "
+		}
+		summary += strings.Join(unparsed, ", ")
+	}
+	return summary
+}
+
+// DescribedPorts returns the open ports for which a service, banner,
+// certificate, TLS or SSH audit, exposure check or web fingerprint is
+// known.
+func DescribedPorts(ports []scanner.Port) []scanner.Port {
+	var described []scanner.Port
+	for _, p := range ports {
+		if p.State == scanner.PortOpen && (p.Service != "" || p.Product != "" || p.Banner != "" || p.Cert != nil || p.TLS != nil || p.Exposure != nil || p.HTTP != nil || p.SSH != nil) {
+			described = append(described, p)
+		}
+	}
+	return described
+}
+
+// hostPort is a port and the host it was found on.
+type hostPort struct {
+	ip   string
+	port scanner.Port
+}
+
+// portsWith collects the ports in results for which keep reports true,
+// once per IP and port, ordered by IP and port. A port found by several
+// scans is taken from the first.
+func portsWith(results []scanner.Result, keep func(scanner.Port) bool) []hostPort {
+	seen := make(map[string]bool)
+	var out []hostPort
+	for _, r := range results {
+		for _, p := range r.Ports {
+			key := net.JoinHostPort(r.IP, strconv.Itoa(p.Number))
+			if !keep(p) || seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, hostPort{ip: r.IP, port: p})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].ip != out[j].ip {
+			return network.LessIP(out[i].ip, out[j].ip)
+		}
+		return out[i].port.Number < out[j].port.Number
+	})
+	return out
+}
+
+// FormatPort formats a port with its service, product, version and
+// banner, e.g. "22/tcp ssh (OpenSSH 9.6p1): SSH-2.0-OpenSSH_9.6p1".
+func FormatPort(p scanner.Port) string {
+	s := fmt.Sprintf("%d/%s", p.Number, p.Protocol)
+	if p.Service != "" {
+		s += " " + p.Service
+	}
+	if product := strings.TrimSpace(p.Product + " " + p.Version); product != "" {
+		s += " (" + product + ")"
+	}
+	if p.Banner != "" {
+		s += ": " + p.Banner
+	}
+	return s
+}
+
+// PortDetails returns the lines describing what the port probes found
+// beyond the service and banner: certificate, TLS audit, exposure check,
+// web fingerprint and SSH audit.
+func PortDetails(p scanner.Port) []string {
+	var lines []string
+	if p.Cert != nil {
+		lines = append(lines, "cert: "+FormatCertificate(p.Cert))
+	}
+	if p.TLS != nil {
+		lines = append(lines, FormatTLS(p.TLS)...)
+	}
+	if p.Exposure != nil {
+		lines = append(lines, "exposure: "+FormatExposure(p.Exposure))
+	}
+	if p.HTTP != nil {
+		lines = append(lines, "http: "+FormatHTTP(p.HTTP))
+	}
+	if p.SSH != nil {
+		lines = append(lines, FormatSSH(p.SSH)...)
+	}
+	return lines
+}
+
+// FormatHTTP summarizes a web fingerprint on one line, e.g.
+// `200 "RouterOS" server nginx, favicon -1840324437`.
+func FormatHTTP(h *scanner.HTTPInfo) string {
+	s := strconv.Itoa(h.Status)
+	if h.Title != "" {
+		s += fmt.Sprintf(" %q", h.Title)
+	}
+	if h.Server != "" {
+		s += ", server " + h.Server
+	}
+	if h.Redirect != "" {
+		s += ", redirect to " + h.Redirect
+	}
+	if h.FaviconHash != "" {
+		s += ", favicon " + h.FaviconHash
+	}
+	return s
+}
+
 // countAlive counts the number of alive hosts in results.
 func countAlive(results []scanner.Result) int {
 	count := 0
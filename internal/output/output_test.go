@@ -0,0 +1,37 @@
+package output
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"maki/internal/scanner"
+)
+
+func TestPortsWith(t *testing.T) {
+	tls := &scanner.TLSInfo{}
+	results := []scanner.Result{
+		{IP: "10.0.0.10", Ports: []scanner.Port{{Number: 443, TLS: tls}, {Number: 80}}},
+		{IP: "2001:db8::1", Ports: []scanner.Port{{Number: 443, TLS: tls}}},
+		{IP: "10.0.0.9", Ports: []scanner.Port{{Number: 8443, TLS: tls}, {Number: 443, TLS: tls}}},
+		// The same port again from another scan, without an audit this
+		// time, and then with one.
+		{IP: "10.0.0.10", Ports: []scanner.Port{{Number: 443}, {Number: 443, TLS: tls, Banner: "later"}}},
+	}
+
+	var got []string
+	for _, hp := range tlsPorts(results) {
+		if hp.port.Banner != "" {
+			t.Errorf("%s port %d taken from a later scan", hp.ip, hp.port.Number)
+		}
+		got = append(got, net.JoinHostPort(hp.ip, strconv.Itoa(hp.port.Number)))
+	}
+	want := []string{"10.0.0.9:443", "10.0.0.9:8443", "10.0.0.10:443", "[2001:db8::1]:443"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tlsPorts() = %v, want %v", got, want)
+	}
+	if got := portsWith(results, func(scanner.Port) bool { return false }); got != nil {
+		t.Errorf("portsWith() keeping nothing = %v", got)
+	}
+}
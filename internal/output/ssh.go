@@ -0,0 +1,191 @@
+package output
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"maki/internal/scanner"
+)
+
+// HostKeyChange is an SSH host key that differs from the one recorded
+// for the same host and key type by a previous run.
+type HostKeyChange struct {
+	Host           string // known_hosts name, e.g. "10.0.0.5" or "[10.0.0.5]:2222"
+	Type           string // e.g. "ssh-ed25519"
+	OldFingerprint string
+	NewFingerprint string
+}
+
+// FormatSSH summarizes an SSH server on several lines: its host keys and
+// the weak algorithms it offers.
+func FormatSSH(s *scanner.SSHInfo) []string {
+	var lines []string
+	for _, k := range s.HostKeys {
+		lines = append(lines, fmt.Sprintf("ssh key: %s %d %s", k.Type, k.Bits, k.Fingerprint))
+	}
+	if len(s.Weak) > 0 {
+		lines = append(lines, "ssh weak: "+strings.Join(s.Weak, ", "))
+	}
+	return lines
+}
+
+// sshPorts collects the ports in results with an SSH audit, once per IP
+// and port, ordered by IP and port.
+func sshPorts(results []scanner.Result) []hostPort {
+	return portsWith(results, func(p scanner.Port) bool { return p.SSH != nil })
+}
+
+// writeSSHCSV writes the audit of every SSH server in results.
+func writeSSHCSV(path string, results []scanner.Result, hostnames map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"ip", "hostname", "port", "version", "host_keys", "kex", "host_key_algorithms", "ciphers", "macs", "weak"})
+	for _, sp := range sshPorts(results) {
+		s := sp.port.SSH
+		keys := make([]string, len(s.HostKeys))
+		for i, k := range s.HostKeys {
+			keys[i] = fmt.Sprintf("%s %d %s", k.Type, k.Bits, k.Fingerprint)
+		}
+		_ = w.Write([]string{
+			sp.ip,
+			hostnames[sp.ip],
+			strconv.Itoa(sp.port.Number),
+			s.Version,
+			strings.Join(keys, "; "),
+			strings.Join(s.KEX, " "),
+			strings.Join(s.HostKeyAlgorithms, " "),
+			strings.Join(s.Ciphers, " "),
+			strings.Join(s.MACs, " "),
+			strings.Join(s.Weak, "; "),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// knownHostsName returns the host as known_hosts names it: the bare IP
+// for port 22, "[ip]:port" otherwise.
+func knownHostsName(ip string, port int) string {
+	if port == 22 {
+		return ip
+	}
+	return fmt.Sprintf("[%s]:%d", ip, port)
+}
+
+// knownHosts maps a known_hosts host name to its keys by key type, the
+// keys being base64 public key blobs.
+type knownHosts map[string]map[string]string
+
+// readKnownHosts reads a known_hosts file. A missing file is empty;
+// hashed names and markers such as @revoked are ignored.
+func readKnownHosts(path string) (knownHosts, error) {
+	hosts := make(knownHosts)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") || strings.HasPrefix(fields[0], "|") {
+			continue
+		}
+		for _, name := range strings.Split(fields[0], ",") {
+			if hosts[name] == nil {
+				hosts[name] = make(map[string]string)
+			}
+			hosts[name][fields[1]] = fields[2]
+		}
+	}
+	return hosts, sc.Err()
+}
+
+// hostKeyChanges compares the host keys in results with those in known.
+func hostKeyChanges(results []scanner.Result, known knownHosts) []HostKeyChange {
+	var changes []HostKeyChange
+	for _, sp := range sshPorts(results) {
+		name := knownHostsName(sp.ip, sp.port.Number)
+		for _, k := range sp.port.SSH.HostKeys {
+			old, ok := known[name][k.Type]
+			if ok && old != k.Key {
+				changes = append(changes, HostKeyChange{
+					Host:           name,
+					Type:           k.Type,
+					OldFingerprint: keyFingerprint(old),
+					NewFingerprint: k.Fingerprint,
+				})
+			}
+		}
+	}
+	return changes
+}
+
+// writeKnownHosts records the host keys in results in a known_hosts file.
+// Keys are merged per key type: entries for hosts that were not scanned
+// this time, and for key types a scanned host did not hand over, are kept.
+func writeKnownHosts(path string, results []scanner.Result, known knownHosts) error {
+	for _, sp := range sshPorts(results) {
+		if len(sp.port.SSH.HostKeys) == 0 {
+			continue
+		}
+		name := knownHostsName(sp.ip, sp.port.Number)
+		if known[name] == nil {
+			known[name] = make(map[string]string)
+		}
+		for _, k := range sp.port.SSH.HostKeys {
+			known[name][k.Type] = k.Key
+		}
+	}
+
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("# SSH host keys recorded by maki; use with ssh -o UserKnownHostsFile=<this file>\n")
+	for _, name := range names {
+		types := make([]string, 0, len(known[name]))
+		for t := range known[name] {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			sb.WriteString(fmt.Sprintf("%s %s %s\n", name, t, known[name][t]))
+		}
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// keyFingerprint returns the SHA256 fingerprint of a base64 public key
+// blob, as ssh-keygen -l prints it.
+func keyFingerprint(key string) string {
+	blob, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "invalid key"
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
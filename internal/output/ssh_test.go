@@ -0,0 +1,68 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"maki/internal/scanner"
+)
+
+// sshResult is a scan result for one SSH server offering keys, given as
+// type and base64 blob pairs.
+func sshResult(ip string, port int, keys ...string) scanner.Result {
+	info := &scanner.SSHInfo{}
+	for i := 0; i+1 < len(keys); i += 2 {
+		info.HostKeys = append(info.HostKeys, scanner.SSHHostKey{Type: keys[i], Key: keys[i+1], Fingerprint: keyFingerprint(keys[i+1])})
+	}
+	return scanner.Result{IP: ip, Ports: []scanner.Port{{Number: port, Protocol: "tcp", SSH: info}}}
+}
+
+func TestWriteKnownHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	old := "# comment\n" +
+		"10.0.0.5 ssh-ed25519 AAAAold\n" +
+		"10.0.0.5 ssh-rsa AAAArsa\n" +
+		"10.0.0.9,[10.0.0.9]:2222 ssh-ed25519 AAAAother\n" +
+		"|1|hashed= ssh-ed25519 AAAAhashed\n"
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	known, err := readKnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []scanner.Result{
+		// Only the Ed25519 key was fetched, and it changed.
+		sshResult("10.0.0.5", 22, "ssh-ed25519", "AAAAnew"),
+		sshResult("2001:db8::1", 2222, "ecdsa-sha2-nistp256", "AAAAecdsa"),
+		sshResult("10.0.0.7", 22),
+	}
+	changes := hostKeyChanges(results, known)
+	want := []HostKeyChange{{Host: "10.0.0.5", Type: "ssh-ed25519", OldFingerprint: keyFingerprint("AAAAold"), NewFingerprint: keyFingerprint("AAAAnew")}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("hostKeyChanges() = %+v, want %+v", changes, want)
+	}
+
+	if err := writeKnownHosts(path, results, known); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")[1:]
+	wantLines := []string{
+		"10.0.0.5 ssh-ed25519 AAAAnew",
+		"10.0.0.5 ssh-rsa AAAArsa",
+		"10.0.0.9 ssh-ed25519 AAAAother",
+		"[10.0.0.9]:2222 ssh-ed25519 AAAAother",
+		"[2001:db8::1]:2222 ecdsa-sha2-nistp256 AAAAecdsa",
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("known_hosts =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(wantLines, "\n"))
+	}
+}
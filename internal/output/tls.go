@@ -0,0 +1,71 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"maki/internal/scanner"
+)
+
+// FormatTLS summarizes a TLS audit: the accepted protocol versions with
+// their number of cipher suites, or the suite when there is only one, and
+// the deprecated ones.
+func FormatTLS(t *scanner.TLSInfo) []string {
+	versions := make([]string, len(t.Versions))
+	for i, v := range t.Versions {
+		versions[i] = fmt.Sprintf("%s (%d suites)", v.Name, len(v.Suites))
+		if len(v.Suites) == 1 {
+			versions[i] = fmt.Sprintf("%s (%s)", v.Name, v.Suites[0])
+		}
+	}
+	lines := []string{"tls: " + strings.Join(versions, ", ")}
+	if len(t.Weak) > 0 {
+		lines = append(lines, "tls weak: "+strings.Join(t.Weak, ", "))
+	}
+	return lines
+}
+
+// tlsPorts collects the ports in results with a TLS audit, once per IP
+// and port, ordered by IP and port.
+func tlsPorts(results []scanner.Result) []hostPort {
+	return portsWith(results, func(p scanner.Port) bool { return p.TLS != nil })
+}
+
+// writeTLSCSV writes every accepted protocol version and cipher suite in
+// results, one row each, marking the deprecated ones.
+func writeTLSCSV(path string, results []scanner.Result, hostnames map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"ip", "hostname", "port", "protocol", "cipher_suite", "weak"})
+	for _, tp := range tlsPorts(results) {
+		weak := make(map[string]bool)
+		for _, wk := range tp.port.TLS.Weak {
+			weak[wk] = true
+		}
+		for _, v := range tp.port.TLS.Versions {
+			for _, suite := range v.Suites {
+				_ = w.Write([]string{
+					tp.ip,
+					hostnames[tp.ip],
+					strconv.Itoa(tp.port.Number),
+					v.Name,
+					suite,
+					strconv.FormatBool(weak["protocol "+v.Name] || weak["cipher "+suite]),
+				})
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+package packet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ARP operations (RFC 826).
+const (
+	ARPRequest = 1
+	ARPReply   = 2
+)
+
+// arpLen is the size of an ARP message for IPv4 over Ethernet.
+const arpLen = 28
+
+// ARP is an ARP message mapping IPv4 addresses to Ethernet addresses.
+type ARP struct {
+	Op        uint16
+	SenderMAC net.HardwareAddr
+	SenderIP  net.IP
+	TargetMAC net.HardwareAddr
+	TargetIP  net.IP
+}
+
+// Marshal encodes the message without the Ethernet header. A nil TargetMAC,
+// as in requests, is sent as zeros.
+func (a *ARP) Marshal() []byte {
+	b := make([]byte, arpLen)
+	binary.BigEndian.PutUint16(b[0:], 1)      // hardware type: Ethernet
+	binary.BigEndian.PutUint16(b[2:], 0x0800) // protocol type: IPv4
+	b[4], b[5] = 6, 4
+	binary.BigEndian.PutUint16(b[6:], a.Op)
+	copy(b[8:14], a.SenderMAC)
+	copy(b[14:18], a.SenderIP.To4())
+	copy(b[18:24], a.TargetMAC)
+	copy(b[24:28], a.TargetIP.To4())
+	return b
+}
+
+// ParseARP decodes an ARP message for IPv4 over Ethernet at the start of
+// b, which must not include the Ethernet header.
+func ParseARP(b []byte) (*ARP, error) {
+	if len(b) < arpLen {
+		return nil, fmt.Errorf("arp: %w", ErrTruncated)
+	}
+	if binary.BigEndian.Uint16(b[0:]) != 1 || binary.BigEndian.Uint16(b[2:]) != 0x0800 || b[4] != 6 || b[5] != 4 {
+		return nil, errors.New("arp: not IPv4 over Ethernet")
+	}
+	return &ARP{
+		Op:        binary.BigEndian.Uint16(b[6:]),
+		SenderMAC: append(net.HardwareAddr(nil), b[8:14]...),
+		SenderIP:  append(net.IP(nil), b[14:18]...),
+		TargetMAC: append(net.HardwareAddr(nil), b[18:24]...),
+		TargetIP:  append(net.IP(nil), b[24:28]...),
+	}, nil
+}
@@ -0,0 +1,103 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func mustMAC(t *testing.T, s string) net.HardwareAddr {
+	t.Helper()
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mac
+}
+
+func TestARPRoundTrip(t *testing.T) {
+	tests := []ARP{
+		{Op: ARPReply, SenderMAC: mustMAC(t, "00:1a:2b:3c:4d:5e"), SenderIP: net.IP{192, 168, 1, 1}, TargetMAC: mustMAC(t, "02:42:ac:11:00:02"), TargetIP: net.IP{192, 168, 1, 23}},
+		{Op: ARPRequest, SenderMAC: mustMAC(t, "02:42:ac:11:00:02"), SenderIP: net.ParseIP("10.0.0.2"), TargetIP: net.ParseIP("10.0.0.1")},
+	}
+	for _, a := range tests {
+		b := a.Marshal()
+		if len(b) != arpLen {
+			t.Fatalf("Marshal() = %d bytes, want %d", len(b), arpLen)
+		}
+		got, err := ParseARP(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := a
+		want.SenderIP, want.TargetIP = a.SenderIP.To4(), a.TargetIP.To4()
+		if want.TargetMAC == nil {
+			want.TargetMAC = make(net.HardwareAddr, 6)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("round trip = %+v, want %+v", *got, want)
+		}
+	}
+}
+
+func TestARPMarshalRequest(t *testing.T) {
+	a := ARP{Op: ARPRequest, SenderMAC: mustMAC(t, "02:42:ac:11:00:02"), SenderIP: net.ParseIP("192.168.1.23"), TargetIP: net.ParseIP("192.168.1.1")}
+	want := []byte{
+		0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01,
+		0x02, 0x42, 0xac, 0x11, 0x00, 0x02, 192, 168, 1, 23,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 192, 168, 1, 1,
+	}
+	if got := a.Marshal(); !bytes.Equal(got, want) {
+		t.Errorf("Marshal() =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestParseARPCaptured(t *testing.T) {
+	// A reply as captured from a home router, Ethernet header removed,
+	// with the padding up to the minimum frame size still attached.
+	b := []byte{
+		0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x02,
+		0xc8, 0x3a, 0x35, 0x0b, 0x7e, 0x21, 0xc0, 0xa8, 0x01, 0x01,
+		0x3c, 0x22, 0xfb, 0x94, 0x1d, 0x07, 0xc0, 0xa8, 0x01, 0x17,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	got, err := ParseARP(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Op != ARPReply || got.SenderMAC.String() != "c8:3a:35:0b:7e:21" || got.SenderIP.String() != "192.168.1.1" ||
+		got.TargetMAC.String() != "3c:22:fb:94:1d:07" || got.TargetIP.String() != "192.168.1.23" {
+		t.Errorf("ParseARP() = %+v", got)
+	}
+	// The result must not share the receive buffer, which is reused.
+	b[8], b[14] = 0xff, 0xff
+	if got.SenderMAC.String() != "c8:3a:35:0b:7e:21" || got.SenderIP.String() != "192.168.1.1" {
+		t.Errorf("ParseARP() result changed with the buffer: %+v", got)
+	}
+}
+
+func TestParseARPErrors(t *testing.T) {
+	valid := (&ARP{Op: ARPReply, SenderIP: net.IP{10, 0, 0, 1}, TargetIP: net.IP{10, 0, 0, 2}}).Marshal()
+	if _, err := ParseARP(valid[:arpLen-1]); !errors.Is(err, ErrTruncated) {
+		t.Errorf("ParseARP() of %d bytes = %v, want ErrTruncated", arpLen-1, err)
+	}
+	for _, tt := range []struct {
+		name  string
+		index int
+		value byte
+	}{
+		{"IEEE 802 hardware", 1, 6},
+		{"IPv6 protocol", 2, 0x86},
+		{"hardware length", 4, 8},
+		{"protocol length", 5, 16},
+	} {
+		b := append([]byte(nil), valid...)
+		b[tt.index] = tt.value
+		if _, err := ParseARP(b); err == nil {
+			t.Errorf("%s: ParseARP() succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ICMP message types used by maki.
+const (
+	ICMPv4EchoReply   = 0
+	ICMPv4EchoRequest = 8
+
+	ICMPv4TimestampRequest   = 13
+	ICMPv4TimestampReply     = 14
+	ICMPv4InfoRequest        = 15
+	ICMPv4InfoReply          = 16
+	ICMPv4AddressMaskRequest = 17
+	ICMPv4AddressMaskReply   = 18
+
+	ICMPv6EchoRequest          = 128
+	ICMPv6EchoReply            = 129
+	ICMPv6NeighborSolicitation = 135
+	ICMPv6NeighborAdvert       = 136
+)
+
+// ICMPEcho is an ICMP or ICMPv6 echo request/reply message. The ICMPv4
+// timestamp, information and address mask queries share its layout, with
+// their fields carried in Data.
+type ICMPEcho struct {
+	Type uint8
+	Code uint8
+	ID   uint16
+	Seq  uint16
+	Data []byte
+}
+
+// Marshal encodes the message with an ICMPv4 checksum. For ICMPv6 the
+// kernel overwrites the checksum with one covering the IPv6 pseudo-header.
+func (e *ICMPEcho) Marshal() []byte {
+	b := make([]byte, 8+len(e.Data))
+	b[0] = e.Type
+	b[1] = e.Code
+	binary.BigEndian.PutUint16(b[4:], e.ID)
+	binary.BigEndian.PutUint16(b[6:], e.Seq)
+	copy(b[8:], e.Data)
+	binary.BigEndian.PutUint16(b[2:], Checksum(b))
+	return b
+}
+
+// ParseICMPEcho decodes an echo message from b, which must start at the
+// ICMP header. It does not validate the type.
+func ParseICMPEcho(b []byte) (*ICMPEcho, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("icmp echo: %w", ErrTruncated)
+	}
+	return &ICMPEcho{
+		Type: b[0],
+		Code: b[1],
+		ID:   binary.BigEndian.Uint16(b[4:]),
+		Seq:  binary.BigEndian.Uint16(b[6:]),
+		Data: b[8:],
+	}, nil
+}
+
+// TimestampData returns the body of a timestamp request: the originate
+// timestamp, in milliseconds since midnight UTC, followed by zeroed
+// receive and transmit timestamps (RFC 792).
+func TimestampData(now time.Time) []byte {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint32(b, uint32(now.Sub(midnight).Milliseconds()))
+	return b
+}
+
+// AddressMaskData returns the body of an address mask request: a zeroed
+// mask for the host to fill in (RFC 950).
+func AddressMaskData() []byte {
+	return make([]byte, 4)
+}
+
+// ParseAddressMask returns the mask carried by an address mask reply
+// body.
+func ParseAddressMask(data []byte) (net.IPMask, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("icmp address mask: %w", ErrTruncated)
+	}
+	return net.IPv4Mask(data[0], data[1], data[2], data[3]), nil
+}
+
+// ICMP error message types used by maki.
+const (
+	ICMPv4DestUnreachable = 3
+	ICMPv4TimeExceeded    = 11
+
+	ICMPv6DestUnreachable = 1
+	ICMPv6TimeExceeded    = 3
+)
+
+// IP protocol numbers of the datagrams quoted in ICMP errors.
+const (
+	ProtoICMP   = 1
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
+)
+
+// ICMPError is a decoded ICMP/ICMPv6 error message (destination
+// unreachable, time exceeded) together with the parts of the original
+// datagram it quotes that are needed to match it to a probe.
+type ICMPError struct {
+	Type uint8
+	Code uint8
+
+	// Protocol, Dst and the port/identifier fields describe the quoted
+	// datagram that triggered the error.
+	Protocol uint8
+	Src      net.IP
+	Dst      net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	EchoID   uint16
+	EchoSeq  uint16
+}
+
+// IsTimeExceeded reports whether the error is a TTL/hop limit expiry.
+func (e *ICMPError) IsTimeExceeded() bool {
+	if e.Dst.To4() != nil {
+		return e.Type == ICMPv4TimeExceeded
+	}
+	return e.Type == ICMPv6TimeExceeded
+}
+
+// IsPortUnreachable reports whether the error is a port unreachable.
+func (e *ICMPError) IsPortUnreachable() bool {
+	if e.Dst.To4() != nil {
+		return e.Type == ICMPv4DestUnreachable && e.Code == 3
+	}
+	return e.Type == ICMPv6DestUnreachable && e.Code == 4
+}
+
+// IsUnreachable reports whether the error is any destination unreachable.
+func (e *ICMPError) IsUnreachable() bool {
+	if e.Dst.To4() != nil {
+		return e.Type == ICMPv4DestUnreachable
+	}
+	return e.Type == ICMPv6DestUnreachable
+}
+
+// ParseICMPError decodes an ICMP (v6 false) or ICMPv6 (v6 true) error
+// message starting at the ICMP header. It fails for non-error types.
+func ParseICMPError(b []byte, v6 bool) (*ICMPError, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("icmp error: %w", ErrTruncated)
+	}
+
+	e := &ICMPError{Type: b[0], Code: b[1]}
+	quoted := b[8:]
+
+	var payload []byte
+	if v6 {
+		if e.Type != ICMPv6DestUnreachable && e.Type != ICMPv6TimeExceeded {
+			return nil, fmt.Errorf("icmp error: not an error message (type %d)", e.Type)
+		}
+		if len(quoted) < 40 {
+			return nil, fmt.Errorf("icmp error: quoted header: %w", ErrTruncated)
+		}
+		e.Protocol = quoted[6]
+		e.Src = net.IP(append([]byte(nil), quoted[8:24]...))
+		e.Dst = net.IP(append([]byte(nil), quoted[24:40]...))
+		payload = quoted[40:]
+	} else {
+		if e.Type != ICMPv4DestUnreachable && e.Type != ICMPv4TimeExceeded {
+			return nil, fmt.Errorf("icmp error: not an error message (type %d)", e.Type)
+		}
+		if len(quoted) < 20 {
+			return nil, fmt.Errorf("icmp error: quoted header: %w", ErrTruncated)
+		}
+		ihl := int(quoted[0]&0x0f) * 4
+		if ihl < 20 || len(quoted) < ihl {
+			return nil, fmt.Errorf("icmp error: quoted header: %w", ErrTruncated)
+		}
+		e.Protocol = quoted[9]
+		e.Src = net.IPv4(quoted[12], quoted[13], quoted[14], quoted[15]).To4()
+		e.Dst = net.IPv4(quoted[16], quoted[17], quoted[18], quoted[19]).To4()
+		payload = quoted[ihl:]
+	}
+
+	// Every ICMP error quotes at least the first 8 bytes of the payload.
+	if len(payload) >= 8 {
+		switch e.Protocol {
+		case ProtoTCP, ProtoUDP:
+			e.SrcPort = binary.BigEndian.Uint16(payload[0:])
+			e.DstPort = binary.BigEndian.Uint16(payload[2:])
+		case ProtoICMP, ProtoICMPv6:
+			e.EchoID = binary.BigEndian.Uint16(payload[4:])
+			e.EchoSeq = binary.BigEndian.Uint16(payload[6:])
+		}
+	}
+
+	return e, nil
+}
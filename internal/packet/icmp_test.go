@@ -0,0 +1,52 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseICMPEcho(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want *ICMPEcho
+	}{
+		{"reply", []byte{ICMPv4EchoReply, 0, 0x12, 0x34, 0xbe, 0xef, 0x00, 0x07, 'm', 'a', 'k', 'i'}, &ICMPEcho{Type: ICMPv4EchoReply, ID: 0xbeef, Seq: 7, Data: []byte("maki")}},
+		{"no data", []byte{ICMPv6EchoReply, 0, 0, 0, 0x00, 0x01, 0xff, 0xff}, &ICMPEcho{Type: ICMPv6EchoReply, ID: 1, Seq: 0xffff, Data: []byte{}}},
+		{"timestamp reply", append([]byte{ICMPv4TimestampReply, 0, 0, 0, 0, 2, 0, 3}, make([]byte, 12)...), &ICMPEcho{Type: ICMPv4TimestampReply, ID: 2, Seq: 3, Data: make([]byte, 12)}},
+		{"truncated", []byte{ICMPv4EchoReply, 0, 0, 0, 0, 1, 0}, nil},
+		{"empty", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseICMPEcho(tt.b)
+		if tt.want == nil {
+			if !errors.Is(err, ErrTruncated) {
+				t.Errorf("%s: ParseICMPEcho() = %+v, %v; want ErrTruncated", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Type != tt.want.Type || got.Code != tt.want.Code || got.ID != tt.want.ID || got.Seq != tt.want.Seq || !bytes.Equal(got.Data, tt.want.Data) {
+			t.Errorf("%s: ParseICMPEcho() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestICMPEchoMarshal(t *testing.T) {
+	e := ICMPEcho{Type: ICMPv4EchoRequest, ID: 0xbeef, Seq: 7, Data: []byte("maki")}
+	b := e.Marshal()
+	if want := []byte{8, 0, 0x60, 0x3e, 0xbe, 0xef, 0x00, 0x07, 'm', 'a', 'k', 'i'}; !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = % x, want % x", b, want)
+	}
+	if Checksum(b) != 0 {
+		t.Error("checksum does not verify")
+	}
+	got, err := ParseICMPEcho(b)
+	if err != nil || got.ID != e.ID || got.Seq != e.Seq || !bytes.Equal(got.Data, e.Data) {
+		t.Errorf("round trip = %+v, %v; want %+v", got, err, e)
+	}
+}
@@ -0,0 +1,68 @@
+package packet
+
+import (
+	"fmt"
+	"net"
+)
+
+// NDP option types (RFC 4861 section 4.6).
+const (
+	ndpOptSourceLinkAddr = 1
+	ndpOptTargetLinkAddr = 2
+)
+
+// SolicitedNodeMulticast returns the solicited-node multicast address
+// (ff02::1:ffXX:XXXX) for ip.
+func SolicitedNodeMulticast(ip net.IP) net.IP {
+	ip = ip.To16()
+	return net.IP{
+		0xff, 0x02, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0x01, 0xff, ip[13], ip[14], ip[15],
+	}
+}
+
+// NeighborSolicitation builds an ICMPv6 Neighbor Solicitation for target,
+// advertising srcMAC as the sender's link-layer address. The checksum is
+// left zero for the kernel to fill in.
+func NeighborSolicitation(target net.IP, srcMAC net.HardwareAddr) []byte {
+	b := make([]byte, 24, 24+8)
+	b[0] = ICMPv6NeighborSolicitation
+	copy(b[8:24], target.To16())
+
+	if len(srcMAC) == 6 {
+		b = append(b, ndpOptSourceLinkAddr, 1)
+		b = append(b, srcMAC...)
+	}
+	return b
+}
+
+// ParseNeighborAdvertisement decodes an ICMPv6 Neighbor Advertisement and
+// returns the target address and, when the Target Link-Layer Address
+// option is present, its MAC address.
+func ParseNeighborAdvertisement(b []byte) (net.IP, net.HardwareAddr, error) {
+	if len(b) < 24 {
+		return nil, nil, fmt.Errorf("neighbor advertisement: %w", ErrTruncated)
+	}
+	if b[0] != ICMPv6NeighborAdvert {
+		return nil, nil, fmt.Errorf("neighbor advertisement: unexpected type %d", b[0])
+	}
+
+	target := make(net.IP, net.IPv6len)
+	copy(target, b[8:24])
+
+	var mac net.HardwareAddr
+	opts := b[24:]
+	for len(opts) >= 2 {
+		optLen := int(opts[1]) * 8
+		if optLen == 0 || optLen > len(opts) {
+			break
+		}
+		if opts[0] == ndpOptTargetLinkAddr && optLen >= 8 {
+			mac = make(net.HardwareAddr, 6)
+			copy(mac, opts[2:8])
+		}
+		opts = opts[optLen:]
+	}
+
+	return target, mac, nil
+}
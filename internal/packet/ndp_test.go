@@ -0,0 +1,74 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+// advert builds a Neighbor Advertisement for 2001:db8::1 followed by
+// opts.
+func advert(opts ...byte) []byte {
+	b := []byte{ICMPv6NeighborAdvert, 0, 0, 0, 0x60, 0, 0, 0}
+	b = append(b, net.ParseIP("2001:db8::1")...)
+	return append(b, opts...)
+}
+
+func TestParseNeighborAdvertisement(t *testing.T) {
+	targetLL := []byte{ndpOptTargetLinkAddr, 1, 0x02, 0x42, 0xac, 0x11, 0x00, 0x03}
+	tests := []struct {
+		name      string
+		b         []byte
+		mac       string
+		truncated bool
+		fails     bool
+	}{
+		{name: "target link-layer address", b: advert(targetLL...), mac: "02:42:ac:11:00:03"},
+		{name: "no options", b: advert()},
+		// A nonce option (14) and a source link-layer address come
+		// before the one wanted.
+		{name: "after other options", b: advert(append([]byte{14, 1, 1, 2, 3, 4, 5, 6, ndpOptSourceLinkAddr, 1, 9, 9, 9, 9, 9, 9}, targetLL...)...), mac: "02:42:ac:11:00:03"},
+		{name: "only source link-layer address", b: advert(ndpOptSourceLinkAddr, 1, 9, 9, 9, 9, 9, 9)},
+		// A zero length would loop forever; it ends the walk.
+		{name: "zero-length option", b: advert(append([]byte{14, 0, 0, 0, 0, 0, 0, 0}, targetLL...)...)},
+		{name: "option longer than the message", b: advert(ndpOptTargetLinkAddr, 2, 0x02, 0x42, 0xac, 0x11, 0x00, 0x03)},
+		{name: "trailing byte", b: advert(append(targetLL, 0)...), mac: "02:42:ac:11:00:03"},
+		{name: "truncated", b: advert()[:23], truncated: true, fails: true},
+		{name: "solicitation", b: append([]byte{ICMPv6NeighborSolicitation}, advert()[1:]...), fails: true},
+	}
+	for _, tt := range tests {
+		target, mac, err := ParseNeighborAdvertisement(tt.b)
+		if (err != nil) != tt.fails || errors.Is(err, ErrTruncated) != tt.truncated {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !target.Equal(net.ParseIP("2001:db8::1")) {
+			t.Errorf("%s: target = %v, want 2001:db8::1", tt.name, target)
+		}
+		if got := mac.String(); got != tt.mac {
+			t.Errorf("%s: MAC = %q, want %q", tt.name, got, tt.mac)
+		}
+	}
+}
+
+func TestNeighborSolicitation(t *testing.T) {
+	target := net.ParseIP("2001:db8::12:3456")
+	if got, want := SolicitedNodeMulticast(target), net.ParseIP("ff02::1:ff12:3456"); !got.Equal(want) {
+		t.Errorf("SolicitedNodeMulticast() = %v, want %v", got, want)
+	}
+
+	mac := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	b := NeighborSolicitation(target, mac)
+	want := append([]byte{ICMPv6NeighborSolicitation, 0, 0, 0, 0, 0, 0, 0}, target...)
+	want = append(want, ndpOptSourceLinkAddr, 1, 0x02, 0x42, 0xac, 0x11, 0x00, 0x02)
+	if !bytes.Equal(b, want) {
+		t.Errorf("NeighborSolicitation() =\n% x\nwant\n% x", b, want)
+	}
+	if b := NeighborSolicitation(target, nil); len(b) != 24 {
+		t.Errorf("NeighborSolicitation() without a MAC = %d bytes, want 24", len(b))
+	}
+}
@@ -0,0 +1,24 @@
+// Package packet encodes and decodes the raw protocol messages that maki's
+// native scanners send and receive on raw sockets.
+package packet
+
+import "errors"
+
+// ErrTruncated is returned when a buffer is too short to hold the message
+// being parsed.
+var ErrTruncated = errors.New("packet truncated")
+
+// Checksum computes the Internet checksum (RFC 1071) of b.
+func Checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,89 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// TCP header flags.
+const (
+	TCPFlagFIN = 0x01
+	TCPFlagSYN = 0x02
+	TCPFlagRST = 0x04
+	TCPFlagPSH = 0x08
+	TCPFlagACK = 0x10
+)
+
+// tcpMSS is the maximum segment size advertised in SYNs, as a regular
+// Ethernet-attached host would.
+const tcpMSS = 1460
+
+// TCPSegment is a TCP header without payload, as sent and received by the
+// SYN scanner.
+type TCPSegment struct {
+	SrcPort uint16
+	DstPort uint16
+	Seq     uint32
+	Ack     uint32
+	Flags   uint8
+	Window  uint16
+}
+
+// Marshal encodes the segment with its checksum, which covers a
+// pseudo-header built from the source and destination addresses. SYNs
+// carry an MSS option.
+func (t *TCPSegment) Marshal(src, dst net.IP) []byte {
+	size := 20
+	if t.Flags&TCPFlagSYN != 0 {
+		size += 4
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint16(b[0:], t.SrcPort)
+	binary.BigEndian.PutUint16(b[2:], t.DstPort)
+	binary.BigEndian.PutUint32(b[4:], t.Seq)
+	binary.BigEndian.PutUint32(b[8:], t.Ack)
+	b[12] = uint8(size/4) << 4
+	b[13] = t.Flags
+	binary.BigEndian.PutUint16(b[14:], t.Window)
+	if size > 20 {
+		b[20], b[21] = 2, 4 // kind MSS, length 4
+		binary.BigEndian.PutUint16(b[22:], tcpMSS)
+	}
+	binary.BigEndian.PutUint16(b[16:], Checksum(append(pseudoHeader(src, dst, ProtoTCP, len(b)), b...)))
+	return b
+}
+
+// ParseTCPSegment decodes the TCP header at the start of b.
+func ParseTCPSegment(b []byte) (*TCPSegment, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("tcp: %w", ErrTruncated)
+	}
+	return &TCPSegment{
+		SrcPort: binary.BigEndian.Uint16(b[0:]),
+		DstPort: binary.BigEndian.Uint16(b[2:]),
+		Seq:     binary.BigEndian.Uint32(b[4:]),
+		Ack:     binary.BigEndian.Uint32(b[8:]),
+		Flags:   b[13],
+		Window:  binary.BigEndian.Uint16(b[14:]),
+	}, nil
+}
+
+// pseudoHeader returns the IPv4 or IPv6 pseudo-header that transport
+// checksums cover (RFC 793, RFC 8200 section 8.1).
+func pseudoHeader(src, dst net.IP, proto uint8, length int) []byte {
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		b := make([]byte, 12)
+		copy(b[0:], src4)
+		copy(b[4:], dst4)
+		b[9] = proto
+		binary.BigEndian.PutUint16(b[10:], uint16(length))
+		return b
+	}
+	b := make([]byte, 40)
+	copy(b[0:], src.To16())
+	copy(b[16:], dst.To16())
+	binary.BigEndian.PutUint32(b[32:], uint32(length))
+	b[39] = proto
+	return b
+}
@@ -0,0 +1,89 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestTCPSegmentMarshal(t *testing.T) {
+	v4src, v4dst := net.ParseIP("192.168.1.2"), net.ParseIP("192.168.1.1")
+	v6src, v6dst := net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::1")
+	tests := []struct {
+		name     string
+		seg      TCPSegment
+		src, dst net.IP
+		size     int
+		checksum uint16
+	}{
+		{"SYN", TCPSegment{SrcPort: 54321, DstPort: 80, Seq: 0x01020304, Flags: TCPFlagSYN, Window: 64240}, v4src, v4dst, 24, 0x415a},
+		{"RST", TCPSegment{SrcPort: 54321, DstPort: 80, Seq: 0x01020305, Flags: TCPFlagRST}, v4src, v4dst, 20, 0x5404},
+		{"IPv6 SYN-ACK", TCPSegment{SrcPort: 80, DstPort: 54321, Seq: 0xdeadbeef, Ack: 0x01020305, Flags: TCPFlagSYN | TCPFlagACK, Window: 65535}, v6src, v6dst, 24, 0xc67b},
+	}
+	for _, tt := range tests {
+		b := tt.seg.Marshal(tt.src, tt.dst)
+		if len(b) != tt.size || int(b[12]>>4)*4 != tt.size {
+			t.Errorf("%s: %d bytes with data offset %d, want %d", tt.name, len(b), int(b[12]>>4)*4, tt.size)
+			continue
+		}
+		if got := binary.BigEndian.Uint16(b[16:]); got != tt.checksum {
+			t.Errorf("%s: checksum = %#04x, want %#04x", tt.name, got, tt.checksum)
+		}
+		// A receiver summing the pseudo-header and the segment gets zero.
+		if got := Checksum(append(pseudoHeader(tt.src, tt.dst, ProtoTCP, len(b)), b...)); got != 0 {
+			t.Errorf("%s: checksum does not verify: %#04x", tt.name, got)
+		}
+		if tt.size == 24 && !bytes.Equal(b[20:], []byte{2, 4, 0x05, 0xb4}) {
+			t.Errorf("%s: options = % x, want MSS 1460", tt.name, b[20:])
+		}
+
+		got, err := ParseTCPSegment(b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if *got != tt.seg {
+			t.Errorf("%s: round trip = %+v, want %+v", tt.name, *got, tt.seg)
+		}
+	}
+}
+
+func TestTCPSegmentMarshalBytes(t *testing.T) {
+	seg := TCPSegment{SrcPort: 54321, DstPort: 80, Seq: 0x01020304, Flags: TCPFlagSYN, Window: 64240}
+	want := []byte{
+		0xd4, 0x31, 0x00, 0x50, // ports
+		0x01, 0x02, 0x03, 0x04, // sequence number
+		0x00, 0x00, 0x00, 0x00, // acknowledgment number
+		0x60, 0x02, 0xfa, 0xf0, // data offset 6, SYN, window 64240
+		0x41, 0x5a, 0x00, 0x00, // checksum, urgent pointer
+		0x02, 0x04, 0x05, 0xb4, // MSS 1460
+	}
+	if got := seg.Marshal(net.ParseIP("192.168.1.2"), net.ParseIP("192.168.1.1")); !bytes.Equal(got, want) {
+		t.Errorf("Marshal() =\n% x\nwant\n% x", got, want)
+	}
+	// IPv4-mapped addresses use the IPv4 pseudo-header.
+	if got := seg.Marshal(net.ParseIP("::ffff:192.168.1.2"), net.ParseIP("::ffff:192.168.1.1")); !bytes.Equal(got, want) {
+		t.Errorf("Marshal() with IPv4-mapped addresses =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestParseTCPSegment(t *testing.T) {
+	// A SYN-ACK with options beyond the fixed header: they are skipped.
+	b := []byte{
+		0x00, 0x50, 0xd4, 0x31,
+		0xde, 0xad, 0xbe, 0xef,
+		0x01, 0x02, 0x03, 0x05,
+		0x80, 0x12, 0xff, 0xff,
+		0x00, 0x00, 0x00, 0x00,
+		0x02, 0x04, 0x05, 0xb4, 0x01, 0x01, 0x04, 0x02, 0x01, 0x03, 0x03, 0x07,
+	}
+	want := TCPSegment{SrcPort: 80, DstPort: 54321, Seq: 0xdeadbeef, Ack: 0x01020305, Flags: TCPFlagSYN | TCPFlagACK, Window: 65535}
+	got, err := ParseTCPSegment(b)
+	if err != nil || *got != want {
+		t.Errorf("ParseTCPSegment() = %+v, %v; want %+v", got, err, want)
+	}
+	if _, err := ParseTCPSegment(b[:19]); !errors.Is(err, ErrTruncated) {
+		t.Errorf("ParseTCPSegment() of 19 bytes = %v, want ErrTruncated", err)
+	}
+}
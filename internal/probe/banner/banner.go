@@ -0,0 +1,179 @@
+// Package banner reads the greeting that servers of "server speaks first"
+// protocols (SSH, FTP, SMTP, POP3, IMAP, Telnet, MySQL) send on connect,
+// and identifies the service from it.
+package banner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"maki/internal/probe"
+)
+
+const (
+	// maxRead bounds how much of the greeting is read.
+	maxRead = 1024
+
+	// maxBanner bounds the length of the stored banner.
+	maxBanner = 160
+)
+
+// Banner is what a service said when a client connected.
+type Banner struct {
+	Service string // e.g. "ssh", "smtp"; empty when not recognized
+	Text    string // sanitized first line of the greeting
+}
+
+// Grab connects to address and reads the server's greeting for up to
+// timeout. Telnet option negotiation is refused so that the login prompt
+// that follows it is read as well. It returns an error when the
+// connection fails or the server sends nothing.
+func Grab(ctx context.Context, d probe.Dialer, address string, timeout time.Duration) (Banner, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return Banner{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var (
+		data   []byte
+		telnet bool
+		buf    = make([]byte, maxRead)
+	)
+	for len(data) < maxRead {
+		n, err := conn.Read(buf[:maxRead-len(data)])
+		if n > 0 {
+			text, replies := stripTelnet(buf[:n])
+			if len(replies) > 0 {
+				telnet = true
+				_, _ = conn.Write(replies)
+			}
+			data = append(data, text...)
+		}
+		if err != nil || bytes.ContainsAny(data, "\r\n") || isMySQL(data) {
+			break
+		}
+	}
+
+	b := identify(data)
+	if telnet {
+		b.Service = "telnet"
+	}
+	if b.Text == "" && b.Service == "" {
+		return Banner{}, errors.New("no banner")
+	}
+	return b, nil
+}
+
+// identify recognizes the service from its greeting.
+func identify(data []byte) Banner {
+	if isMySQL(data) {
+		return Banner{Service: "mysql", Text: mysqlBanner(data)}
+	}
+
+	line, _, _ := strings.Cut(strings.TrimLeft(string(data), "\r\n"), "\n")
+	text := probe.Sanitize(line, maxBanner)
+	upper := strings.ToUpper(line)
+
+	var service string
+	switch {
+	case strings.HasPrefix(line, "SSH-"):
+		service = "ssh"
+	case strings.HasPrefix(line, "+OK"):
+		service = "pop3"
+	case strings.HasPrefix(line, "* OK"), strings.HasPrefix(line, "* PREAUTH"):
+		service = "imap"
+	case strings.HasPrefix(line, "220"):
+		switch {
+		case strings.Contains(upper, "FTP"):
+			service = "ftp"
+		case strings.Contains(upper, "SMTP"), strings.Contains(upper, "MAIL"):
+			service = "smtp"
+		}
+	}
+	return Banner{Service: service, Text: text}
+}
+
+// isMySQL reports whether data starts with a MySQL handshake (protocol
+// 10) or error packet.
+func isMySQL(data []byte) bool {
+	if len(data) < 5 {
+		return false
+	}
+	length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	return data[3] == 0 && (data[4] == 0x0a || data[4] == 0xff) && length > 1 && length <= 0xffff
+}
+
+// mysqlBanner formats a MySQL handshake as "MySQL 8.0.36", or an error
+// packet (for example "host not allowed") as its message.
+func mysqlBanner(data []byte) string {
+	payload := data[4:]
+	if payload[0] == 0xff {
+		if len(payload) < 3 {
+			return "MySQL error"
+		}
+		msg := payload[3:]
+		if len(msg) > 0 && msg[0] == '#' && len(msg) >= 6 {
+			msg = msg[6:] // SQL state marker
+		}
+		return probe.Sanitize("MySQL error: "+string(msg), maxBanner)
+	}
+	version, _, _ := bytes.Cut(payload[1:], []byte{0})
+	return probe.Sanitize("MySQL "+string(version), maxBanner)
+}
+
+// Telnet command bytes (RFC 854).
+const (
+	telnetIAC  = 255
+	telnetDont = 254
+	telnetDo   = 253
+	telnetWont = 252
+	telnetWill = 251
+	telnetSB   = 250
+	telnetSE   = 240
+)
+
+// stripTelnet removes telnet commands from data and returns the remaining
+// text with the replies that refuse every option the server offered or
+// requested.
+func stripTelnet(data []byte) (text, replies []byte) {
+	for i := 0; i < len(data); i++ {
+		if data[i] != telnetIAC || i+1 >= len(data) {
+			text = append(text, data[i])
+			continue
+		}
+		cmd := data[i+1]
+		switch {
+		case cmd == telnetIAC:
+			text = append(text, telnetIAC)
+			i++
+		case cmd >= telnetWill && cmd <= telnetDont && i+2 < len(data):
+			// Answering WONT/DONT would invite negotiation loops.
+			switch cmd {
+			case telnetWill:
+				replies = append(replies, telnetIAC, telnetDont, data[i+2])
+			case telnetDo:
+				replies = append(replies, telnetIAC, telnetWont, data[i+2])
+			}
+			i += 2
+		case cmd == telnetSB:
+			end := bytes.Index(data[i:], []byte{telnetIAC, telnetSE})
+			if end < 0 {
+				return text, replies
+			}
+			i += end + 1
+		default:
+			i++
+		}
+	}
+	return text, replies
+}
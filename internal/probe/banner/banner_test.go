@@ -0,0 +1,161 @@
+package banner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStripTelnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		text    string
+		replies string
+	}{
+		{"plain", "login: ", "login: ", ""},
+		{"escaped IAC", "a\xff\xffb", "a\xffb", ""},
+		{"WILL and DO refused", "\xff\xfb\x01\xff\xfd\x18login: ", "login: ", "\xff\xfe\x01\xff\xfc\x18"},
+		{"WONT and DONT not answered", "\xff\xfc\x01\xff\xfe\x03x", "x", ""},
+		{"subnegotiation", "\xff\xfa\x18\x01\xff\xf0ok", "ok", ""},
+		{"IAC inside subnegotiation", "\xff\xfa\x18\xff\xff\x01\xff\xf0ok", "ok", ""},
+		{"unterminated subnegotiation", "ab\xff\xfa\x18\x01", "ab", ""},
+		{"unterminated after an option", "\xff\xfd\x18\xff\xfa\x18", "", "\xff\xfc\x18"},
+		{"other command", "\xff\xf9ok", "ok", ""},
+	}
+	for _, tt := range tests {
+		text, replies := stripTelnet([]byte(tt.data))
+		if string(text) != tt.text || string(replies) != tt.replies {
+			t.Errorf("%s: stripTelnet(%q) = %q, %q; want %q, %q", tt.name, tt.data, text, replies, tt.text, tt.replies)
+		}
+	}
+}
+
+// mysqlPacket frames payload as MySQL packet 0.
+func mysqlPacket(payload string) string {
+	n := len(payload)
+	return string([]byte{byte(n), byte(n >> 8), byte(n >> 16), 0}) + payload
+}
+
+func TestIdentify(t *testing.T) {
+	handshake := mysqlPacket("\x0a8.0.36-0ubuntu0.22.04.1\x00\x08\x00\x00\x00\x3d\x21\x5c\x7a\x0a\x01\x6f\x1c\x00\xff\xff")
+	tests := []struct {
+		data string
+		want Banner
+	}{
+		{"SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n", Banner{"ssh", "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13"}},
+		{"\r\n220 (vsFTPd 3.0.5)\r\n", Banner{"ftp", "220 (vsFTPd 3.0.5)"}},
+		{"220 mx.example.com ESMTP Postfix\r\n", Banner{"smtp", "220 mx.example.com ESMTP Postfix"}},
+		{"220 relay mail service ready\r\n", Banner{"smtp", "220 relay mail service ready"}},
+		{"220 ready\r\n", Banner{"", "220 ready"}},
+		{"+OK Dovecot ready.\r\n", Banner{"pop3", "+OK Dovecot ready."}},
+		{"* OK [CAPABILITY IMAP4rev1] ready\r\n", Banner{"imap", "* OK [CAPABILITY IMAP4rev1] ready"}},
+		{"* PREAUTH ready\r\n", Banner{"imap", "* PREAUTH ready"}},
+		{"first\nsecond\n", Banner{"", "first"}},
+		{"\x1b[2Jcaf\xc3\xa9\r\n", Banner{"", ".[2Jcaf.."}},
+		{strings.Repeat("x", 300), Banner{"", strings.Repeat("x", maxBanner)}},
+		{handshake, Banner{"mysql", "MySQL 8.0.36-0ubuntu0.22.04.1"}},
+		{mysqlPacket("\xffj\x04#HY000Host '10.0.0.9' is not allowed to connect to this MySQL server"), Banner{"mysql", "MySQL error: Host '10.0.0.9' is not allowed to connect to this MySQL server"}},
+		{mysqlPacket("\xffj\x04Host '10.0.0.9' is blocked"), Banner{"mysql", "MySQL error: Host '10.0.0.9' is blocked"}},
+		{mysqlPacket("\xff\x10\x04#HY0"), Banner{"mysql", "MySQL error: #HY0"}},
+		{mysqlPacket("\xff\x10"), Banner{"mysql", "MySQL error"}},
+		// Only packet 0 is a greeting; anything else is text.
+		{"\x0a\x00\x00\x01\x0a", Banner{"", "..."}},
+	}
+	for _, tt := range tests {
+		if got := identify([]byte(tt.data)); got != tt.want {
+			t.Errorf("identify(%q) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
+
+// greeter accepts one connection at a time and writes each chunk with
+// a pause in between, so that the client sees them in separate reads. It
+// sends what the client writes on replies.
+func greeter(t *testing.T, chunks []string, replies chan<- []byte) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				if replies != nil {
+					go func() {
+						b, _ := io.ReadAll(conn)
+						replies <- b
+					}()
+				}
+				for _, c := range chunks {
+					if _, err := io.WriteString(conn, c); err != nil {
+						return
+					}
+					time.Sleep(50 * time.Millisecond)
+				}
+				if replies != nil {
+					// Hold the connection until the client closes it.
+					time.Sleep(time.Second)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func grab(t *testing.T, address string) (Banner, error) {
+	t.Helper()
+	return Grab(context.Background(), &net.Dialer{}, address, 2*time.Second)
+}
+
+func TestGrabSplitGreeting(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		want   Banner
+	}{
+		{[]string{"SSH-2.0-Open", "SSH_9.6\r\n"}, Banner{"ssh", "SSH-2.0-OpenSSH_9.6"}},
+		{[]string{"2", "20 (vsFTPd", " 3.0.5)\r\n"}, Banner{"ftp", "220 (vsFTPd 3.0.5)"}},
+		// The handshake has no line ending: Grab must stop once the
+		// packet header is in, not wait for the timeout.
+		{[]string{"J\x00", "\x00\x00\x0a5.7.44\x00\x08\x00"}, Banner{"mysql", "MySQL 5.7.44"}},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		got, err := grab(t, greeter(t, tt.chunks, nil))
+		if err != nil || got != tt.want {
+			t.Errorf("Grab() of %q = %+v, %v; want %+v", tt.chunks, got, err, tt.want)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("Grab() of %q waited for the timeout", tt.chunks)
+		}
+	}
+}
+
+func TestGrabTelnet(t *testing.T) {
+	replies := make(chan []byte, 1)
+	addr := greeter(t, []string{"\xff\xfd\x18\xff\xfb\x01", "\xff\xfd\x1f", "Ubuntu 24.04 LTS\r\nlogin: "}, replies)
+	got, err := grab(t, addr)
+	if want := (Banner{"telnet", "Ubuntu 24.04 LTS"}); err != nil || got != want {
+		t.Errorf("Grab() = %+v, %v; want %+v", got, err, want)
+	}
+	if got, want := <-replies, []byte("\xff\xfc\x18\xff\xfe\x01\xff\xfc\x1f"); !bytes.Equal(got, want) {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+}
+
+func TestGrabSilent(t *testing.T) {
+	if got, err := grab(t, greeter(t, []string{"\r\n"}, nil)); err == nil {
+		t.Errorf("Grab() of a blank greeting = %+v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package exposure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// maxRootPage bounds how much of the Elasticsearch root page is read.
+const maxRootPage = 64 << 10
+
+// checkElasticsearch requests the root page, which describes the node
+// and cluster unless security is enabled, in which case it is refused
+// with 401. OpenSearch answers the same way.
+func checkElasticsearch(ctx context.Context, d probe.Dialer, address string) (*scanner.Exposure, error) {
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: d.DialContext, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &scanner.Exposure{}, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var root struct {
+		ClusterName string `json:"cluster_name"`
+		Version     struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRootPage)).Decode(&root); err != nil || root.Version.Number == "" {
+		return nil, errors.New("not an Elasticsearch root page")
+	}
+	e := &scanner.Exposure{
+		Version:         root.Version.Number,
+		Unauthenticated: true,
+		Detail:          "cluster " + root.ClusterName,
+	}
+	if strings.EqualFold(root.Version.Distribution, "opensearch") {
+		e.Detail = "OpenSearch, " + e.Detail
+	}
+	return e, nil
+}
@@ -0,0 +1,84 @@
+// Package exposure checks whether Redis, Memcached, MongoDB and
+// Elasticsearch servers answer without credentials. Every check sends
+// the same read-only queries an administrator would use to look at the
+// server (INFO, version and stats, buildInfo and listDatabases, GET /)
+// and never writes or reads stored data.
+package exposure
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// maxDetail bounds the length of the stored version and detail.
+const maxDetail = 120
+
+// checks maps a service to its check. The context carries the timeout.
+var checks = map[string]func(ctx context.Context, d probe.Dialer, address string) (*scanner.Exposure, error){
+	"redis":         checkRedis,
+	"memcached":     checkMemcached,
+	"mongodb":       checkMongoDB,
+	"elasticsearch": checkElasticsearch,
+}
+
+// defaultPorts are the ports checked when no service was identified.
+var defaultPorts = map[int]string{
+	6379:  "redis",
+	11211: "memcached",
+	27017: "mongodb",
+	9200:  "elasticsearch",
+}
+
+// Service returns the service to check on port, given the service name
+// found by other probes, or "" when there is none. Known names win;
+// unnamed ports and Elasticsearch's HTTP port fall back to the default
+// port of each service.
+func Service(name string, port int) string {
+	if _, ok := checks[name]; ok {
+		return name
+	}
+	if name == "" || name == "http" {
+		return defaultPorts[port]
+	}
+	return ""
+}
+
+// Check queries the service at address without credentials. It returns
+// an error when the connection fails or the server does not speak the
+// service's protocol, and an Exposure otherwise, whether credentials were
+// asked for or not.
+func Check(ctx context.Context, d probe.Dialer, address, service string, timeout time.Duration) (*scanner.Exposure, error) {
+	check, ok := checks[service]
+	if !ok {
+		return nil, fmt.Errorf("no exposure check for %q", service)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	e, err := check(ctx, d, address)
+	if err != nil {
+		return nil, err
+	}
+	e.Service = service
+	e.Version = probe.Sanitize(e.Version, maxDetail)
+	e.Detail = probe.Sanitize(e.Detail, maxDetail)
+	return e, nil
+}
+
+// dial connects to address with the context's deadline applied to the
+// connection.
+func dial(ctx context.Context, d probe.Dialer, address string) (net.Conn, error) {
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
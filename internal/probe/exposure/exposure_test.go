@@ -0,0 +1,384 @@
+package exposure
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// fakeServer serves each connection accepted on a local port with handle
+// and returns the port's address.
+func fakeServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// lineServer answers every line the client sends with the reply replies
+// maps it to, and hangs up on lines it does not know.
+func lineServer(t *testing.T, replies map[string]string) string {
+	return fakeServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			reply, ok := replies[strings.TrimRight(line, "\r\n")]
+			if !ok {
+				return
+			}
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// check runs the service's check against address.
+func check(t *testing.T, address, service string) (*scanner.Exposure, error) {
+	t.Helper()
+	return Check(context.Background(), &net.Dialer{}, address, service, 2*time.Second)
+}
+
+// redisServer answers the RESP-encoded INFO command with reply.
+func redisServer(t *testing.T, reply string) string {
+	return fakeServer(t, func(conn net.Conn) {
+		req := make([]byte, len("*1\r\n$4\r\nINFO\r\n"))
+		if _, err := io.ReadFull(conn, req); err != nil || string(req) != "*1\r\n$4\r\nINFO\r\n" {
+			return
+		}
+		_, _ = io.WriteString(conn, reply)
+	})
+}
+
+func TestRedis(t *testing.T) {
+	info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n# Keyspace\r\ndb0:keys=12,expires=0,avg_ttl=0\r\ndb3:keys=30,expires=1,avg_ttl=0\r\n"
+
+	tests := []struct {
+		name  string
+		reply string
+		want  *scanner.Exposure
+	}{
+		{"open", fmt.Sprintf("$%d\r\n%s\r\n", len(info), info), &scanner.Exposure{Service: "redis", Version: "7.2.4", Unauthenticated: true, Detail: "42 keys"}},
+		{"empty keyspace", "$22\r\nredis_version:6.0.16\r\n\r\n", &scanner.Exposure{Service: "redis", Version: "6.0.16", Unauthenticated: true, Detail: "0 keys"}},
+		{"password", "-NOAUTH Authentication required.\r\n", &scanner.Exposure{Service: "redis"}},
+		{"acl", "-NOPERM this user has no permissions to run the 'info' command\r\n", &scanner.Exposure{Service: "redis"}},
+		{"protected mode", "-DENIED Redis is running in protected mode\r\n", &scanner.Exposure{Service: "redis", Detail: "protected mode"}},
+		{"not redis", "SSH-2.0-OpenSSH_9.6\r\n", nil},
+		{"huge length", "$999999999\r\n", nil},
+		{"truncated", "$100\r\nredis_version:7.2.4\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := check(t, redisServer(t, tt.reply), "redis")
+			assertExposure(t, got, err, tt.want)
+		})
+	}
+}
+
+func TestMemcached(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies map[string]string
+		want    *scanner.Exposure
+	}{
+		{
+			"open",
+			map[string]string{
+				"version": "VERSION 1.6.21\r\n",
+				"stats":   "STAT pid 1\r\nSTAT uptime 50\r\nSTAT curr_items 42\r\nSTAT total_items 90\r\nEND\r\n",
+			},
+			&scanner.Exposure{Service: "memcached", Version: "1.6.21", Unauthenticated: true, Detail: "42 items"},
+		},
+		{"stats refused", map[string]string{"version": "VERSION 1.4.15\r\n"}, &scanner.Exposure{Service: "memcached", Version: "1.4.15", Unauthenticated: true}},
+		{"auth file", map[string]string{"version": "CLIENT_ERROR unauthenticated\r\n"}, &scanner.Exposure{Service: "memcached"}},
+		{"not memcached", map[string]string{"version": "ERROR\r\n"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := check(t, lineServer(t, tt.replies), "memcached")
+			assertExposure(t, got, err, tt.want)
+		})
+	}
+}
+
+// bsonEncode encodes a document for the fake MongoDB server. Values may
+// be string, bool, int32, int64, float64, [12]byte (ObjectId), a nested
+// bsonDoc or an array []interface{}.
+func bsonEncode(d bsonDoc) []byte {
+	b := make([]byte, 4)
+	for _, e := range d {
+		key := append([]byte(e.key), 0)
+		switch v := e.value.(type) {
+		case string:
+			b = append(append(b, 0x02), key...)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(v)+1))
+			b = append(append(b, v...), 0)
+		case bool:
+			b = append(append(b, 0x08), key...)
+			if v {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		case int32:
+			b = append(append(b, 0x10), key...)
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		case int64:
+			b = append(append(b, 0x12), key...)
+			b = binary.LittleEndian.AppendUint64(b, uint64(v))
+		case float64:
+			b = append(append(b, 0x01), key...)
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		case [12]byte:
+			b = append(append(b, 0x07), key...)
+			b = append(b, v[:]...)
+		case bsonDoc:
+			b = append(append(b, 0x03), key...)
+			b = append(b, bsonEncode(v)...)
+		case []interface{}:
+			var arr bsonDoc
+			for i, item := range v {
+				arr = append(arr, struct {
+					key   string
+					value interface{}
+				}{fmt.Sprint(i), item})
+			}
+			b = append(append(b, 0x04), key...)
+			b = append(b, bsonEncode(arr)...)
+		default:
+			panic(fmt.Sprintf("bsonEncode: unsupported %T", v))
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+// mongoServer answers the first OP_MSG with buildInfo and the second with
+// listDatabases.
+func mongoServer(t *testing.T, buildInfo, listDatabases bsonDoc) string {
+	return fakeServer(t, func(conn net.Conn) {
+		for i, reply := range []bsonDoc{buildInfo, listDatabases} {
+			var header [16]byte
+			if _, err := io.ReadFull(conn, header[:]); err != nil {
+				return
+			}
+			length := binary.LittleEndian.Uint32(header[0:])
+			body := make([]byte, length-16)
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			cmd, _, err := parseBSON(body[5:])
+			if err != nil {
+				return
+			}
+			if _, ok := cmd[[]string{"buildInfo", "listDatabases"}[i]]; !ok {
+				return
+			}
+
+			out := binary.LittleEndian.AppendUint32(nil, 0)
+			out = append(out, 0)
+			out = append(out, bsonEncode(reply)...)
+			msg := make([]byte, 16, 16+len(out))
+			binary.LittleEndian.PutUint32(msg[0:], uint32(16+len(out)))
+			binary.LittleEndian.PutUint32(msg[8:], binary.LittleEndian.Uint32(header[4:])) // responseTo
+			binary.LittleEndian.PutUint32(msg[12:], opMsg)
+			if _, err := conn.Write(append(msg, out...)); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestMongoDB(t *testing.T) {
+	buildInfo := bsonDoc{
+		{"version", "7.0.5"},
+		{"gitVersion", "7809d71e84e314b497f282ea8aa06d7ded3eb205"},
+		{"versionArray", []interface{}{int32(7), int32(0), int32(5), int32(0)}},
+		{"openssl", bsonDoc{{"running", "OpenSSL 3.0.2"}, {"compiled", "OpenSSL 3.0.2"}}},
+		{"maxBsonObjectSize", int32(16777216)},
+		{"ok", float64(1)},
+	}
+	db := func(name string, size int64) bsonDoc {
+		return bsonDoc{{"name", name}, {"sizeOnDisk", size}, {"empty", false}}
+	}
+	databases := []interface{}{db("admin", 40960), db("config", 12288), db("local", 73728), db("shop", 1<<20), db("users", 8192), db("logs", 4096)}
+
+	tests := []struct {
+		name  string
+		reply bsonDoc
+		want  *scanner.Exposure
+	}{
+		{
+			"open",
+			bsonDoc{{"databases", databases}, {"totalSize", float64(1 << 21)}, {"ok", float64(1)}},
+			&scanner.Exposure{Service: "mongodb", Version: "7.0.5", Unauthenticated: true, Detail: "6 databases: admin, config, local, shop, users, ..."},
+		},
+		{
+			"open, few databases",
+			bsonDoc{{"databases", []interface{}{bsonDoc{{"_id", [12]byte{1, 2, 3}}, {"name", "app"}}}}, {"ok", int32(1)}},
+			&scanner.Exposure{Service: "mongodb", Version: "7.0.5", Unauthenticated: true, Detail: "1 databases: app"},
+		},
+		{
+			"auth required",
+			bsonDoc{{"ok", float64(0)}, {"errmsg", "command listDatabases requires authentication"}, {"code", int32(13)}, {"codeName", "Unauthorized"}},
+			&scanner.Exposure{Service: "mongodb", Version: "7.0.5"},
+		},
+		{
+			"other failure",
+			bsonDoc{{"ok", float64(0)}, {"errmsg", "not primary"}, {"code", int32(10107)}},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := check(t, mongoServer(t, buildInfo, tt.reply), "mongodb")
+			assertExposure(t, got, err, tt.want)
+		})
+	}
+}
+
+func TestParseBSON(t *testing.T) {
+	full := bsonEncode(bsonDoc{
+		{"s", "text"},
+		{"n", int64(-5)},
+		{"nested", bsonDoc{{"inner", bsonDoc{{"x", int32(3)}}}}},
+		{"list", []interface{}{"a", bsonDoc{{"b", true}}, []interface{}{float64(1.5)}}},
+	})
+
+	doc, rest, err := parseBSON(append(full, 0xAA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 1 || rest[0] != 0xAA {
+		t.Errorf("rest = %x, want aa", rest)
+	}
+	if doc["s"] != "text" || doc["n"] != float64(-5) {
+		t.Errorf("scalars = %v, %v", doc["s"], doc["n"])
+	}
+	inner, _ := doc["nested"].(map[string]interface{})["inner"].(map[string]interface{})
+	if inner["x"] != float64(3) {
+		t.Errorf("nested.inner.x = %v, want 3", inner["x"])
+	}
+	list, _ := doc["list"].([]interface{})
+	if len(list) != 3 || list[0] != "a" {
+		t.Fatalf("list = %v", list)
+	}
+	if b := list[1].(map[string]interface{})["b"]; b != true {
+		t.Errorf("list[1].b = %v, want true", b)
+	}
+	if f := list[2].([]interface{}); len(f) != 1 || f[0] != 1.5 {
+		t.Errorf("list[2] = %v, want [1.5]", f)
+	}
+
+	for _, n := range []int{0, 4, 10, len(full) - 1} {
+		if _, _, err := parseBSON(full[:n]); err == nil {
+			t.Errorf("parseBSON(%d of %d bytes) succeeded", n, len(full))
+		}
+	}
+	huge := append([]byte(nil), full...)
+	binary.LittleEndian.PutUint32(huge[4+len("\x02s\x00"):], 1<<30) // string length
+	if _, _, err := parseBSON(huge); err == nil {
+		t.Error("parseBSON with oversized string succeeded")
+	}
+}
+
+func TestElasticsearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   *scanner.Exposure
+	}{
+		{
+			"open",
+			http.StatusOK,
+			`{"name":"node-1","cluster_name":"logs","version":{"number":"8.12.0","build_flavor":"default"},"tagline":"You Know, for Search"}`,
+			&scanner.Exposure{Service: "elasticsearch", Version: "8.12.0", Unauthenticated: true, Detail: "cluster logs"},
+		},
+		{
+			"opensearch",
+			http.StatusOK,
+			`{"cluster_name":"search","version":{"distribution":"opensearch","number":"2.11.1"}}`,
+			&scanner.Exposure{Service: "elasticsearch", Version: "2.11.1", Unauthenticated: true, Detail: "OpenSearch, cluster search"},
+		},
+		{"security enabled", http.StatusUnauthorized, `{"error":"missing authentication credentials"}`, &scanner.Exposure{Service: "elasticsearch"}},
+		{"other web server", http.StatusOK, `<html>hello</html>`, nil},
+		{"not found", http.StatusNotFound, ``, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			got, err := check(t, strings.TrimPrefix(srv.URL, "http://"), "elasticsearch")
+			assertExposure(t, got, err, tt.want)
+		})
+	}
+}
+
+func TestService(t *testing.T) {
+	tests := []struct {
+		name string
+		port int
+		want string
+	}{
+		{"redis", 7000, "redis"},
+		{"", 6379, "redis"},
+		{"http", 9200, "elasticsearch"},
+		{"http", 80, ""},
+		{"ssh", 27017, ""},
+		{"", 22, ""},
+	}
+	for _, tt := range tests {
+		if got := Service(tt.name, tt.port); got != tt.want {
+			t.Errorf("Service(%q, %d) = %q, want %q", tt.name, tt.port, got, tt.want)
+		}
+	}
+}
+
+// assertExposure compares the result of a check with want, nil meaning
+// the check must fail.
+func assertExposure(t *testing.T, got *scanner.Exposure, err error, want *scanner.Exposure) {
+	t.Helper()
+	switch {
+	case want == nil && err == nil:
+		t.Errorf("got %+v, want an error", *got)
+	case want != nil && err != nil:
+		t.Errorf("got error %v, want %+v", err, *want)
+	case want != nil && *got != *want:
+		t.Errorf("got %+v, want %+v", *got, *want)
+	}
+}
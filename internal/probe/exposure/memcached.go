@@ -0,0 +1,65 @@
+package exposure
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// maxStats bounds the number of lines of the stats reply that are read.
+const maxStats = 200
+
+// checkMemcached sends version and stats over the text protocol, which
+// has no authentication unless the server requires an auth file, in
+// which case it answers with CLIENT_ERROR.
+func checkMemcached(ctx context.Context, d probe.Dialer, address string) (*scanner.Exposure, error) {
+	conn, err := dial(ctx, d, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("version\r\n")); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "CLIENT_ERROR") {
+		return &scanner.Exposure{}, nil
+	}
+	version, ok := strings.CutPrefix(line, "VERSION ")
+	if !ok {
+		return nil, errors.New("not a Memcached reply")
+	}
+	e := &scanner.Exposure{Version: version, Unauthenticated: true}
+
+	// The item count is a bonus; a server that stops answering still
+	// counts as exposed.
+	if _, err := conn.Write([]byte("stats\r\n")); err != nil {
+		return e, nil
+	}
+	for i := 0; i < maxStats; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "END" {
+			break
+		}
+		// STAT curr_items 42
+		if items, ok := strings.CutPrefix(line, "STAT curr_items "); ok {
+			e.Detail = fmt.Sprintf("%s items", items)
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,248 @@
+package exposure
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+const (
+	// opMsg is the OP_MSG wire protocol opcode (MongoDB 3.6 and later).
+	opMsg = 2013
+
+	// maxMessage bounds the size of a MongoDB reply.
+	maxMessage = 1 << 20
+
+	// maxDatabaseNames is how many database names the detail lists.
+	maxDatabaseNames = 5
+
+	// errUnauthorized is MongoDB's error code for a command that needs
+	// authentication.
+	errUnauthorized = 13
+)
+
+// checkMongoDB runs buildInfo, which MongoDB answers before
+// authentication, for the version, then listDatabases, which it answers
+// only after.
+func checkMongoDB(ctx context.Context, d probe.Dialer, address string) (*scanner.Exposure, error) {
+	conn, err := dial(ctx, d, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	info, err := runCommand(conn, 1, bsonDoc{{"buildInfo", int32(1)}, {"$db", "admin"}})
+	if err != nil {
+		return nil, err
+	}
+	e := &scanner.Exposure{}
+	e.Version, _ = info["version"].(string)
+
+	reply, err := runCommand(conn, 2, bsonDoc{{"listDatabases", int32(1)}, {"nameOnly", true}, {"$db", "admin"}})
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := reply["ok"].(float64); ok != 1 {
+		code, _ := reply["code"].(float64)
+		msg, _ := reply["errmsg"].(string)
+		if code == errUnauthorized || strings.Contains(msg, "auth") {
+			return e, nil
+		}
+		return nil, fmt.Errorf("listDatabases failed: %s", msg)
+	}
+
+	e.Unauthenticated = true
+	databases, _ := reply["databases"].([]interface{})
+	var names []string
+	for _, db := range databases {
+		if doc, ok := db.(map[string]interface{}); ok {
+			if name, ok := doc["name"].(string); ok && len(names) < maxDatabaseNames {
+				names = append(names, name)
+			}
+		}
+	}
+	e.Detail = fmt.Sprintf("%d databases", len(databases))
+	if len(names) > 0 {
+		e.Detail += ": " + strings.Join(names, ", ")
+		if len(databases) > len(names) {
+			e.Detail += ", ..."
+		}
+	}
+	return e, nil
+}
+
+// runCommand sends cmd in an OP_MSG message and returns the reply
+// document.
+func runCommand(conn net.Conn, requestID int32, cmd bsonDoc) (map[string]interface{}, error) {
+	body := binary.LittleEndian.AppendUint32(nil, 0) // flag bits
+	body = append(body, 0)                           // section kind 0: body
+	body = append(body, cmd.marshal()...)
+
+	msg := make([]byte, 16, 16+len(body))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(msg[4:], uint32(requestID))
+	binary.LittleEndian.PutUint32(msg[12:], opMsg)
+	msg = append(msg, body...)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var header [16]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[0:])
+	if binary.LittleEndian.Uint32(header[12:]) != opMsg || length < 16+5 || length > maxMessage {
+		return nil, errors.New("not a MongoDB reply")
+	}
+	reply := make([]byte, length-16)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[4] != 0 {
+		return nil, errors.New("unexpected MongoDB reply section")
+	}
+	doc, _, err := parseBSON(reply[5:])
+	return doc, err
+}
+
+// bsonDoc is an ordered BSON document to encode; values may be int32,
+// bool or string.
+type bsonDoc []struct {
+	key   string
+	value interface{}
+}
+
+// marshal encodes d as BSON.
+func (d bsonDoc) marshal() []byte {
+	b := make([]byte, 4)
+	for _, e := range d {
+		switch v := e.value.(type) {
+		case int32:
+			b = append(b, 0x10)
+			b = append(append(b, e.key...), 0)
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		case bool:
+			b = append(b, 0x08)
+			b = append(append(b, e.key...), 0)
+			if v {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		case string:
+			b = append(b, 0x02)
+			b = append(append(b, e.key...), 0)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(v)+1))
+			b = append(append(b, v...), 0)
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+// parseBSON decodes the BSON document at the start of b into a map and
+// returns the bytes after it. Numbers become float64, embedded documents
+// maps and arrays slices; other values are skipped.
+func parseBSON(b []byte) (map[string]interface{}, []byte, error) {
+	if len(b) < 5 {
+		return nil, nil, errors.New("truncated BSON document")
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	if size < 5 || size > len(b) {
+		return nil, nil, errors.New("invalid BSON document size")
+	}
+	rest, after := b[4:size-1], b[size:]
+
+	doc := make(map[string]interface{})
+	for len(rest) > 0 {
+		kind := rest[0]
+		end := bytes.IndexByte(rest[1:], 0)
+		if end < 0 {
+			return nil, nil, errors.New("truncated BSON key")
+		}
+		key := string(rest[1 : 1+end])
+		rest = rest[2+end:]
+
+		var n int
+		switch kind {
+		case 0x06, 0x0A, 0x7F, 0xFF: // undefined, null, max key, min key
+			n = 0
+		case 0x08: // bool
+			n = 1
+		case 0x10: // int32
+			n = 4
+		case 0x01, 0x09, 0x11, 0x12: // double, datetime, timestamp, int64
+			n = 8
+		case 0x07: // ObjectId
+			n = 12
+		case 0x13: // decimal128
+			n = 16
+		case 0x02, 0x0D, 0x0E, 0x05: // string, JavaScript code, symbol, binary
+			if len(rest) < 4 {
+				return nil, nil, errors.New("truncated BSON value")
+			}
+			n = 4 + int(binary.LittleEndian.Uint32(rest))
+			if kind == 0x05 {
+				n++ // subtype
+			}
+		case 0x03, 0x04: // document, array
+			sub, tail, err := parseBSON(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			if kind == 0x04 {
+				doc[key] = bsonArray(sub)
+			} else {
+				doc[key] = sub
+			}
+			rest = tail
+			continue
+		default:
+			return nil, nil, fmt.Errorf("unsupported BSON type 0x%02x", kind)
+		}
+		if n < 0 || n > len(rest) {
+			return nil, nil, errors.New("truncated BSON value")
+		}
+
+		switch v := rest[:n]; kind {
+		case 0x01:
+			doc[key] = math.Float64frombits(binary.LittleEndian.Uint64(v))
+		case 0x02:
+			if n >= 5 {
+				doc[key] = string(v[4 : n-1])
+			}
+		case 0x08:
+			doc[key] = v[0] != 0
+		case 0x10:
+			doc[key] = float64(int32(binary.LittleEndian.Uint32(v)))
+		case 0x12:
+			doc[key] = float64(int64(binary.LittleEndian.Uint64(v)))
+		}
+		rest = rest[n:]
+	}
+	return doc, after, nil
+}
+
+// bsonArray converts a BSON array, a document keyed "0", "1", ..., to a
+// slice.
+func bsonArray(doc map[string]interface{}) []interface{} {
+	out := make([]interface{}, 0, len(doc))
+	for i := 0; ; i++ {
+		v, ok := doc[fmt.Sprint(i)]
+		if !ok {
+			return out
+		}
+		out = append(out, v)
+	}
+}
@@ -0,0 +1,75 @@
+package exposure
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// maxInfo bounds the size of the Redis INFO reply that is read.
+const maxInfo = 256 << 10
+
+// checkRedis sends INFO, which is answered only once a client is
+// authenticated, and reads the server version and key count from it.
+func checkRedis(ctx context.Context, d probe.Dialer, address string) (*scanner.Exposure, error) {
+	conn, err := dial(ctx, d, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("*1\r\n$4\r\nINFO\r\n")); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	switch {
+	case strings.HasPrefix(line, "-NOAUTH"), strings.HasPrefix(line, "-WRONGPASS"), strings.HasPrefix(line, "-NOPERM"):
+		return &scanner.Exposure{}, nil
+	case strings.HasPrefix(line, "-DENIED"):
+		// protected mode: no password set, but remote clients refused
+		return &scanner.Exposure{Detail: "protected mode"}, nil
+	case !strings.HasPrefix(line, "$"):
+		return nil, errors.New("not a Redis reply")
+	}
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > maxInfo {
+		return nil, fmt.Errorf("invalid Redis reply length %q", line[1:])
+	}
+	info := make([]byte, size)
+	if _, err := io.ReadFull(r, info); err != nil {
+		return nil, err
+	}
+
+	e := &scanner.Exposure{Unauthenticated: true}
+	keys := 0
+	for _, field := range strings.Split(string(info), "\n") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), ":")
+		switch {
+		case name == "redis_version":
+			e.Version = value
+		case strings.HasPrefix(name, "db"):
+			// db0:keys=12,expires=0,avg_ttl=0
+			for _, kv := range strings.Split(value, ",") {
+				if n, ok := strings.CutPrefix(kv, "keys="); ok {
+					count, _ := strconv.Atoi(n)
+					keys += count
+				}
+			}
+		}
+	}
+	e.Detail = fmt.Sprintf("%d keys", keys)
+	return e, nil
+}
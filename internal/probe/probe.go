@@ -0,0 +1,32 @@
+// Package probe holds what the service probes (banner grabbing, TLS, HTTP,
+// SSH, ...) share: how they open connections and how they clean up what
+// servers send back.
+package probe
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// Dialer opens connections for probes. *net.Dialer satisfies it; scanners
+// can substitute one that binds a source address or goes through a proxy.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Sanitize makes untrusted text safe to print and store: surrounding
+// whitespace is trimmed, control and non-ASCII bytes become '.', and the
+// result is cut to max bytes.
+func Sanitize(s string, max int) string {
+	b := []byte(strings.TrimSpace(s))
+	if len(b) > max {
+		b = b[:max]
+	}
+	for i, c := range b {
+		if c < 0x20 || c > 0x7e {
+			b[i] = '.'
+		}
+	}
+	return string(b)
+}
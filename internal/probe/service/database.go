@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultProbes is the built-in probe database.
+//
+//go:embed service-probes.txt
+var defaultProbes string
+
+var (
+	defaultOnce sync.Once
+	defaultDB   *Database
+)
+
+// Default returns the built-in probe database.
+func Default() *Database {
+	defaultOnce.Do(func() {
+		db, err := Parse(strings.NewReader(defaultProbes))
+		if err != nil {
+			panic("service: built-in probe database: " + err.Error())
+		}
+		defaultDB = db
+	})
+	return defaultDB
+}
+
+// Database is a list of probes with the patterns that match their
+// responses.
+type Database struct {
+	probes []*serviceProbe
+}
+
+// serviceProbe is one payload to send and the matches for its response.
+type serviceProbe struct {
+	name    string
+	payload []byte
+	ports   map[int]bool
+	matches []match
+}
+
+// match recognizes a service in a response and says how to describe it.
+type match struct {
+	service string
+	re      *regexp.Regexp
+	soft    bool
+
+	// product, version and info are templates with $1-$9 references.
+	product, version, info string
+}
+
+// Parse reads a probe database in the format of service-probes.txt.
+func Parse(r io.Reader) (*Database, error) {
+	db := &Database{}
+	var current *serviceProbe
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		directive, rest, _ := strings.Cut(text, " ")
+		rest = strings.TrimSpace(rest)
+
+		var err error
+		switch directive {
+		case "Probe":
+			current, err = parseProbe(rest)
+			if err == nil {
+				db.probes = append(db.probes, current)
+			}
+		case "ports":
+			if current == nil {
+				err = fmt.Errorf("ports before the first Probe")
+				break
+			}
+			current.ports, err = parsePortList(rest)
+		case "match", "softmatch":
+			if current == nil {
+				err = fmt.Errorf("%s before the first Probe", directive)
+				break
+			}
+			var m match
+			m, err = parseMatch(rest, directive == "softmatch")
+			if err == nil {
+				current.matches = append(current.matches, m)
+			}
+		default:
+			err = fmt.Errorf("unknown directive %q", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// parseProbe parses "TCP <name> q|<payload>|".
+func parseProbe(s string) (*serviceProbe, error) {
+	fields := strings.SplitN(s, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("want Probe TCP <name> q|<payload>|")
+	}
+	if fields[0] != "TCP" {
+		return nil, fmt.Errorf("unsupported protocol %q", fields[0])
+	}
+	if !strings.HasPrefix(fields[2], "q") {
+		return nil, fmt.Errorf("payload must start with q")
+	}
+	raw, rest, err := delimited(fields[2][1:])
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("unexpected %q after payload", rest)
+	}
+	payload, err := unescape(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &serviceProbe{name: fields[1], payload: payload}, nil
+}
+
+// parseMatch parses "<service> m|<regex>|[flags] [p/../] [v/../] [i/../]".
+func parseMatch(s string, soft bool) (match, error) {
+	service, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	if service == "" || !strings.HasPrefix(rest, "m") {
+		return match{}, fmt.Errorf("want <service> m|<regex>|")
+	}
+	pattern, rest, err := delimited(rest[1:])
+	if err != nil {
+		return match{}, err
+	}
+
+	flags := ""
+	for rest != "" && rest[0] != ' ' {
+		switch rest[0] {
+		case 'i', 's':
+			flags += rest[:1]
+		default:
+			return match{}, fmt.Errorf("unknown regex flag %q", rest[0])
+		}
+		rest = rest[1:]
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return match{}, err
+	}
+
+	m := match{service: service, re: re, soft: soft}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		field := rest[0]
+		var value string
+		value, rest, err = delimited(rest[1:])
+		if err != nil {
+			return match{}, err
+		}
+		switch field {
+		case 'p':
+			m.product = value
+		case 'v':
+			m.version = value
+		case 'i':
+			m.info = value
+		default:
+			return match{}, fmt.Errorf("unknown field %q", field)
+		}
+	}
+	return m, nil
+}
+
+// delimited splits "|value|rest" at the closing delimiter, which is
+// whatever character s starts with.
+func delimited(s string) (value, rest string, err error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing delimiter")
+	}
+	end := strings.IndexByte(s[1:], s[0])
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated %q", s)
+	}
+	return s[1 : end+1], s[end+2:], nil
+}
+
+// unescape decodes the escapes allowed in probe payloads.
+func unescape(s string) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return nil, fmt.Errorf("trailing backslash")
+		}
+		i++
+		switch s[i] {
+		case 'r':
+			out = append(out, '\r')
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case '0':
+			out = append(out, 0)
+		case '\\':
+			out = append(out, '\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, fmt.Errorf("short \\x escape")
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("bad \\x escape %q", s[i+1:i+3])
+			}
+			out = append(out, byte(b))
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown escape \\%c", s[i])
+		}
+	}
+	return out, nil
+}
+
+// parsePortList parses a comma-separated list of ports and ranges.
+func parsePortList(s string) (map[int]bool, error) {
+	ports := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			hi = lo
+		}
+		first, err1 := strconv.Atoi(lo)
+		last, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || first < 1 || last > 65535 || first > last {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		for p := first; p <= last; p++ {
+			ports[p] = true
+		}
+	}
+	return ports, nil
+}
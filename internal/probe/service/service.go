@@ -0,0 +1,187 @@
+// Package service identifies the service and its version behind an open
+// TCP port without nmap: it sends the probes of a probe database in turn
+// and matches the responses against regular expressions that extract the
+// product, version and extra information, like nmap's -sV.
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"maki/internal/probe"
+)
+
+const (
+	// maxResponse bounds how much of a response is read.
+	maxResponse = 4096
+
+	// settle is how long to keep reading once a response has started, so
+	// that responses arriving in several segments are matched whole.
+	settle = 200 * time.Millisecond
+
+	// maxField bounds the length of the product, version and info.
+	maxField = 80
+)
+
+// Match describes the service found on a port. Product, Version and Info
+// are empty when the database does not extract them.
+type Match struct {
+	Service string // e.g. "ssh", "http"
+	Product string // e.g. "OpenSSH"
+	Version string // e.g. "9.6p1"
+	Info    string // extra information, e.g. "Ubuntu-3ubuntu13"
+}
+
+// Detect probes the service at address, a host:port on the given port,
+// and returns what the first matching probe recognized. Each probe takes
+// its own connection and up to timeout. It reports false when nothing
+// matched, and stops early when the port can no longer be connected to.
+func (db *Database) Detect(ctx context.Context, d probe.Dialer, address string, port int, timeout time.Duration) (Match, bool) {
+	var soft Match
+	for _, p := range db.order(port) {
+		if soft.Service != "" && !p.hasService(soft.Service) {
+			continue
+		}
+		resp, err := exchange(ctx, d, address, p.payload, timeout)
+		if err != nil {
+			break
+		}
+		if len(resp) == 0 {
+			continue
+		}
+		m, hard, ok := p.match(resp)
+		switch {
+		case !ok:
+			continue
+		case hard:
+			return m, true
+		case soft.Service == "":
+			soft = m
+		}
+	}
+	return soft, soft.Service != ""
+}
+
+// order returns the probes to try on port: the NULL probe first, then
+// those listing the port, then the rest.
+func (db *Database) order(port int) []*serviceProbe {
+	var first, listed, rest []*serviceProbe
+	for _, p := range db.probes {
+		switch {
+		case len(p.payload) == 0:
+			first = append(first, p)
+		case p.ports[port]:
+			listed = append(listed, p)
+		default:
+			rest = append(rest, p)
+		}
+	}
+	return append(append(first, listed...), rest...)
+}
+
+// hasService reports whether any of the probe's matches is for service.
+func (p *serviceProbe) hasService(service string) bool {
+	for _, m := range p.matches {
+		if m.service == service {
+			return true
+		}
+	}
+	return false
+}
+
+// match runs the probe's matches against resp in order and returns the
+// first that matches, and whether it is a hard match.
+func (p *serviceProbe) match(resp []byte) (Match, bool, bool) {
+	// One rune per byte, so that \xHH in the patterns matches byte HH
+	// instead of the UTF-8 encoding of U+00HH.
+	text := latin1(resp)
+	for _, m := range p.matches {
+		groups := m.re.FindStringSubmatch(text)
+		if groups == nil {
+			continue
+		}
+		return Match{
+			Service: m.service,
+			Product: expand(m.product, groups),
+			Version: expand(m.version, groups),
+			Info:    expand(m.info, groups),
+		}, !m.soft, true
+	}
+	return Match{}, false, false
+}
+
+// expand replaces $1-$9 in template with the capture groups and cleans up
+// the result for display.
+func expand(template string, groups []string) string {
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] == '$' && i+1 < len(template) && template[i+1] >= '1' && template[i+1] <= '9' {
+			n, _ := strconv.Atoi(template[i+1 : i+2])
+			if n < len(groups) {
+				b.WriteString(groups[n])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(template[i])
+	}
+	return probe.Sanitize(b.String(), maxField)
+}
+
+// latin1 maps every byte of b to the rune of the same value.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// exchange connects to address, sends payload, and reads the response
+// until the connection is closed, maxResponse bytes arrived, the response
+// settled, or timeout. It returns an error only when the connection
+// cannot be made.
+func exchange(ctx context.Context, d probe.Dialer, address string, payload []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			return nil, nil
+		}
+	}
+
+	var (
+		data []byte
+		buf  = make([]byte, maxResponse)
+	)
+	for len(data) < maxResponse {
+		n, err := conn.Read(buf[:maxResponse-len(data)])
+		data = append(data, buf[:n]...)
+		if err != nil {
+			break
+		}
+		if n > 0 {
+			_ = conn.SetReadDeadline(minTime(deadline, time.Now().Add(settle)))
+		}
+	}
+	return data, nil
+}
+
+// minTime returns the earlier of a and b.
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
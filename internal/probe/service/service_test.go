@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// probeNamed returns the probe of db with the given name.
+func probeNamed(t *testing.T, db *Database, name string) *serviceProbe {
+	t.Helper()
+	for _, p := range db.probes {
+		if p.name == name {
+			return p
+		}
+	}
+	t.Fatalf("no probe %s", name)
+	return nil
+}
+
+func TestDefaultParses(t *testing.T) {
+	db, err := Parse(strings.NewReader(defaultProbes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.probes) == 0 || len(db.probes[0].payload) != 0 {
+		t.Fatalf("the built-in database does not start with the NULL probe")
+	}
+	for _, p := range db.probes {
+		if len(p.matches) == 0 {
+			t.Errorf("probe %s has no matches", p.name)
+		}
+	}
+	if Default() == nil {
+		t.Error("Default() = nil")
+	}
+}
+
+func TestDefaultMatches(t *testing.T) {
+	db := Default()
+	tests := []struct {
+		probe string
+		resp  string
+		want  Match
+		hard  bool
+	}{
+		{"NULL", "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n", Match{"ssh", "OpenSSH", "9.6p1", "Ubuntu-3ubuntu13"}, true},
+		{"NULL", "SSH-2.0-OpenSSH_8.0\r\n", Match{"ssh", "OpenSSH", "8.0", ""}, true},
+		{"NULL", "SSH-2.0-dropbear_2022.83\r\n", Match{"ssh", "Dropbear sshd", "2022.83", ""}, true},
+		{"NULL", "SSH-2.0-Go\r\n", Match{"ssh", "Go", "", "protocol 2.0"}, true},
+		{"NULL", "220 (vsFTPd 3.0.5)\r\n", Match{"ftp", "vsftpd", "3.0.5", ""}, true},
+		{"NULL", "220 ProFTPD 1.3.8 Server (Debian) [::ffff:10.0.0.5]\r\n", Match{"ftp", "ProFTPD", "1.3.8", ""}, true},
+		{"NULL", "220-FileZilla Server 1.8.1\r\n", Match{"ftp", "FileZilla ftpd", "1.8.1", ""}, true},
+		{"NULL", "220 Welcome to the NAS FTP server\r\n", Match{Service: "ftp"}, false},
+		{"NULL", "220 mail.example.com ESMTP Postfix (Ubuntu)\r\n", Match{Service: "smtp", Product: "Postfix smtpd"}, true},
+		{"NULL", "220 mx.example.com ESMTP Exim 4.96 Mon, 01 Jan 2024 00:00:00 +0000\r\n", Match{"smtp", "Exim smtpd", "4.96", ""}, true},
+		{"NULL", "220 relay.example.com Mail gateway ready\r\n", Match{Service: "smtp"}, false},
+		{"NULL", "+OK Dovecot (Ubuntu) ready.\r\n", Match{Service: "pop3", Product: "Dovecot pop3d"}, true},
+		{"NULL", "+OK POP3 server ready <1896.697170952@dbc.mtview.ca.us>\r\n", Match{Service: "pop3"}, false},
+		{"NULL", "* OK [CAPABILITY IMAP4rev1 SASL-IR LOGIN-REFERRALS ID ENABLE IDLE LITERAL+ STARTTLS AUTH=PLAIN] Dovecot (Ubuntu) ready.\r\n", Match{Service: "imap", Product: "Dovecot imapd"}, true},
+		{"NULL", "J\x00\x00\x00\x0a8.0.36-0ubuntu0.22.04.1\x00\x08\x00\x00\x00\x3d\x21\x5c\x7a\x0a\x01\x6f\x1c\x00\xff\xff\xff\x02\x00\xff\xdf", Match{"mysql", "MySQL", "8.0.36-0ubuntu0.22.04.1", ""}, true},
+		{"NULL", "Z\x00\x00\x00\x0a5.5.5-10.11.6-MariaDB-0+deb12u1\x00\x1f\x00\x00\x00", Match{"mysql", "MariaDB", "10.11.6", ""}, true},
+		{"NULL", "\x0a\x00\x00\x00\x0a11.2.2-MariaDB\x00", Match{"mysql", "MariaDB", "11.2.2", ""}, true},
+		{"NULL", "E\x00\x00\x00\xffj\x04Host '10.0.0.9' is not allowed to connect to this MySQL server", Match{Service: "mysql", Product: "MySQL", Info: "unauthorized"}, true},
+		{"NULL", "RFB 003.008\n", Match{Service: "vnc", Product: "VNC", Info: "protocol 3.8"}, true},
+		{"NULL", "\xff\xfd\x18\xff\xfd\x20", Match{Service: "telnet"}, false},
+		{"GetRequest", "HTTP/1.1 200 OK\r\nDate: Mon, 01 Jan 2024 00:00:00 GMT\r\nServer: nginx/1.25.3\r\n\r\n", Match{"http", "nginx", "1.25.3", ""}, true},
+		{"GetRequest", "HTTP/1.1 301 Moved Permanently\r\nserver: nginx/1.25.3\r\n\r\n", Match{"http", "nginx", "1.25.3", ""}, true},
+		{"GetRequest", "HTTP/1.1 404 Not Found\r\nSERVER: nginx\r\n\r\n", Match{Service: "http", Product: "nginx"}, true},
+		{"GetRequest", "HTTP/1.1 200 OK\r\nServer: Apache/2.4.58 (Ubuntu)\r\nContent-Length: 0\r\n\r\n", Match{"http", "Apache httpd", "2.4.58", "Ubuntu"}, true},
+		{"GetRequest", "HTTP/1.0 200 OK\r\nServer: SimpleHTTP/0.6 Python/3.12.3\r\n\r\n", Match{"http", "SimpleHTTPServer", "0.6", "Python 3.12.3"}, true},
+		{"GetRequest", "HTTP/1.1 200 OK\r\nserver: uvicorn\r\n\r\n", Match{Service: "http", Product: "uvicorn"}, true},
+		{"GetRequest", "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<html>Server: fake</html>", Match{Service: "http"}, false},
+		{"GetRequest", "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n", Match{Service: "http"}, false},
+		{"GetRequest", "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\n  \"name\" : \"node-1\",\n  \"cluster_name\" : \"elasticsearch\",\n  \"version\" : {\n    \"number\" : \"8.12.2\"\n  }\n}\n", Match{"http", "Elasticsearch REST API", "8.12.2", ""}, true},
+		{"RedisPing", "+PONG\r\n", Match{Service: "redis", Product: "Redis key-value store"}, true},
+		{"RedisPing", "-NOAUTH Authentication required.\r\n", Match{Service: "redis", Product: "Redis key-value store", Info: "authentication required"}, true},
+		{"Memcached", "VERSION 1.6.21\r\n", Match{"memcached", "Memcached", "1.6.21", ""}, true},
+		{"PostgreSQL", "N", Match{Service: "postgresql", Product: "PostgreSQL DB"}, true},
+	}
+	for _, tt := range tests {
+		got, hard, ok := probeNamed(t, db, tt.probe).match([]byte(tt.resp))
+		if !ok || got != tt.want || hard != tt.hard {
+			t.Errorf("%s match of %q = %+v, hard %v, ok %v; want %+v, hard %v", tt.probe, tt.resp, got, hard, ok, tt.want, tt.hard)
+		}
+	}
+
+	for _, resp := range []string{"", "hello\r\n", "SSH-", "HTTP/2 200\r\n"} {
+		for _, p := range db.probes {
+			if m, _, ok := p.match([]byte(resp)); ok {
+				t.Errorf("%s matched %q as %+v", p.name, resp, m)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	db, err := Parse(strings.NewReader(`# comment
+
+Probe TCP NULL q||
+match ssh m|^SSH-([\d.]+)-(\S+)|i p/$2/ i/protocol $1/
+Probe TCP Hello q|HELO\x20x\r\n\0\\|
+ports 25, 587,2525-2527
+softmatch smtp m%^2\d\d|^5\d\d%s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.probes) != 2 {
+		t.Fatalf("Parse() = %d probes, want 2", len(db.probes))
+	}
+	hello := db.probes[1]
+	if want := []byte("HELO x\r\n\x00\\"); hello.name != "Hello" || !bytes.Equal(hello.payload, want) {
+		t.Errorf("probe = %s %q, want Hello %q", hello.name, hello.payload, want)
+	}
+	if want := map[int]bool{25: true, 587: true, 2525: true, 2526: true, 2527: true}; !reflect.DeepEqual(hello.ports, want) {
+		t.Errorf("ports = %v, want %v", hello.ports, want)
+	}
+	m := db.probes[0].matches[0]
+	if m.service != "ssh" || m.soft || m.product != "$2" || m.info != "protocol $1" || !m.re.MatchString("ssh-2.0-x") {
+		t.Errorf("match = %+v", m)
+	}
+	if sm := hello.matches[0]; !sm.soft || !sm.re.MatchString("554 no") {
+		t.Errorf("softmatch = %+v", sm)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, db := range []string{
+		"ports 80",
+		"match ssh m|x|",
+		"Probe UDP x q||",
+		"Probe TCP x",
+		"Probe TCP x |abc|",
+		"Probe TCP x q|abc",
+		"Probe TCP x q|abc| junk",
+		`Probe TCP x q|\q|`,
+		`Probe TCP x q|\x4|`,
+		`Probe TCP x q|\xzz|`,
+		`Probe TCP x q|a\|`,
+		"Probe TCP x q||\nports 0",
+		"Probe TCP x q||\nports 90-80",
+		"Probe TCP x q||\nports 65530-70000",
+		"Probe TCP x q||\nports http",
+		"Probe TCP x q||\nmatch ssh m|x|z",
+		"Probe TCP x q||\nmatch ssh m|(|",
+		"Probe TCP x q||\nmatch ssh m|x| d/1/",
+		"Probe TCP x q||\nmatch ssh m|x| p/unterminated",
+		"Probe TCP x q||\nmatch ssh",
+		"Probe TCP x q||\nmatch m|x|",
+		"Probe TCP x q||\nrarity 3",
+	} {
+		if _, err := Parse(strings.NewReader(db)); err == nil {
+			t.Errorf("Parse(%q) succeeded", db)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	groups := []string{"all", "one", "", "three"}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"", ""},
+		{"$1", "one"},
+		{"$1 $3", "one three"},
+		{"$2", ""},
+		{"v$9", "v"},
+		{"$0 $", "$0 $"},
+		{" $1\t", "one"},
+		{"x\x01y", "x.y"},
+		{strings.Repeat("a", 100), strings.Repeat("a", maxField)},
+	}
+	for _, tt := range tests {
+		if got := expand(tt.template, groups); got != tt.want {
+			t.Errorf("expand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestLatin1(t *testing.T) {
+	db, err := Parse(strings.NewReader("Probe TCP NULL q||\nmatch x m|^\\xff\\xfb(.)$|s p/$1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// \xff\xfb must match the bytes, not the UTF-8 encoding of ÿû.
+	if _, _, ok := db.probes[0].match([]byte("\xff\xfbA")); !ok {
+		t.Error("\\xff\\xfb did not match the raw bytes")
+	}
+	if _, _, ok := db.probes[0].match([]byte("ÿûA")); ok {
+		t.Error("\\xff\\xfb matched UTF-8 text")
+	}
+}
+
+// fakeService accepts connections, sends greeting at once, answers each
+// payload it knows from replies, and records every payload received.
+type fakeService struct {
+	l        net.Listener
+	greeting string
+	replies  map[string]string
+
+	mu       sync.Mutex
+	received []string
+}
+
+func newFakeService(t *testing.T, greeting string, replies map[string]string) *fakeService {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeService{l: l, greeting: greeting, replies: replies}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeService) serve(c net.Conn) {
+	defer c.Close()
+	_, _ = c.Write([]byte(f.greeting))
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 512)
+	n, _ := c.Read(buf)
+	if n == 0 {
+		return
+	}
+	f.mu.Lock()
+	f.received = append(f.received, string(buf[:n]))
+	f.mu.Unlock()
+	_, _ = c.Write([]byte(f.replies[string(buf[:n])]))
+}
+
+func (f *fakeService) port() int {
+	return f.l.Addr().(*net.TCPAddr).Port
+}
+
+func TestDetect(t *testing.T) {
+	f := newFakeService(t, "220 fake ftp ready\r\n", map[string]string{
+		"LISTED\r\n":   "214 fooftpd 1.2\r\n",
+		"UNLISTED\r\n": "214 barftpd 9.9\r\n",
+	})
+	// The NULL probe only softmatches. OTHER has no ftp matches and must
+	// be skipped; LISTED lists the port and must be tried before
+	// UNLISTED, which comes first in the file.
+	db, err := Parse(strings.NewReader(fmt.Sprintf(`Probe TCP NULL q||
+softmatch ftp m|^220 .*ftp|
+Probe TCP Other q|OTHER\r\n|
+match smtp m|.|s p/wrong/
+Probe TCP Unlisted q|UNLISTED\r\n|
+match ftp m|214 (\w+) ([\d.]+)| p/$1/ v/$2/
+Probe TCP Listed q|LISTED\r\n|
+ports %d
+match ftp m|214 (\w+) ([\d.]+)| p/$1/ v/$2/
+`, f.port())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := db.Detect(context.Background(), &net.Dialer{}, f.l.Addr().String(), f.port(), time.Second)
+	if want := (Match{Service: "ftp", Product: "fooftpd", Version: "1.2"}); !ok || got != want {
+		t.Errorf("Detect() = %+v, %v; want %+v", got, ok, want)
+	}
+	f.mu.Lock()
+	received := f.received
+	f.mu.Unlock()
+	if want := []string{"LISTED\r\n"}; !reflect.DeepEqual(received, want) {
+		t.Errorf("payloads sent = %q, want %q", received, want)
+	}
+}
+
+func TestDetectSoftOnly(t *testing.T) {
+	f := newFakeService(t, "220 fake ftp ready\r\n", nil)
+	got, ok := Default().Detect(context.Background(), &net.Dialer{}, f.l.Addr().String(), f.port(), time.Second)
+	if want := (Match{Service: "ftp"}); !ok || got != want {
+		t.Errorf("Detect() = %+v, %v; want %+v", got, ok, want)
+	}
+}
+
+func TestDetectClosedPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	start := time.Now()
+	if got, ok := Default().Detect(context.Background(), &net.Dialer{}, addr, 80, time.Second); ok {
+		t.Errorf("Detect() on a closed port = %+v", got)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Detect() kept probing a closed port")
+	}
+}
@@ -0,0 +1,307 @@
+// Package sshinfo audits SSH servers without logging in: the version
+// exchange gives the server's identification, its KEXINIT message the
+// offered key exchange, host key, cipher and MAC algorithms, and one key
+// exchange per host key type the host keys themselves. Keys are collected,
+// not verified, and no session keys are ever derived.
+package sshinfo
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// clientVersion is the identification string maki sends.
+const clientVersion = "SSH-2.0-maki"
+
+// maxVersion bounds the length of the server's identification.
+const maxVersion = 160
+
+// kexAlgorithms are the key exchanges maki can start, in preference
+// order. Only the client's public value is needed to obtain the host key,
+// so every one of them is cheap.
+var kexAlgorithms = []string{
+	"curve25519-sha256",
+	"curve25519-sha256@libssh.org",
+	"ecdh-sha2-nistp256",
+	"ecdh-sha2-nistp384",
+	"ecdh-sha2-nistp521",
+	"diffie-hellman-group14-sha256",
+	"diffie-hellman-group14-sha1",
+	"diffie-hellman-group1-sha1",
+}
+
+// Grab audits the SSH server at address. The first connection reads the
+// server's identification and algorithm lists; then one connection per
+// host key type (Ed25519, ECDSA, RSA, DSA) fetches that key. Each
+// connection takes up to timeout.
+func Grab(ctx context.Context, d probe.Dialer, address string, timeout time.Duration) (*scanner.SSHInfo, error) {
+	version, server, _, err := handshake(ctx, d, address, timeout, "")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &scanner.SSHInfo{
+		Version:           version,
+		KEX:               server.kex,
+		HostKeyAlgorithms: server.hostKey,
+		Ciphers:           union(server.ciphersC2S, server.ciphersS2C),
+		MACs:              union(server.macsC2S, server.macsS2C),
+	}
+
+	for _, alg := range hostKeyAlgorithms(server.hostKey) {
+		_, _, blob, err := handshake(ctx, d, address, timeout, alg)
+		if err != nil {
+			continue
+		}
+		if key, ok := describeKey(blob); ok {
+			info.HostKeys = append(info.HostKeys, key)
+		}
+	}
+	info.Weak = weaknesses(info)
+	return info, nil
+}
+
+// handshake exchanges versions and KEXINIT messages with the server. With
+// hostKeyAlg set, it goes on to start a key exchange that offers only that
+// host key algorithm and returns the host key blob from the reply.
+func handshake(ctx context.Context, d probe.Dialer, address string, timeout time.Duration, hostKeyAlg string) (string, *kexInit, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte(clientVersion + "\r\n")); err != nil {
+		return "", nil, nil, err
+	}
+	r := bufio.NewReader(conn)
+	version, err := readVersion(r)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	payload, err := readPacket(r)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	server, err := parseKexInit(payload)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if hostKeyAlg == "" {
+		return version, server, nil, nil
+	}
+
+	kex := firstCommon(kexAlgorithms, server.kex)
+	if kex == "" {
+		return "", nil, nil, errors.New("no supported key exchange")
+	}
+	client := &kexInit{
+		kex:         []string{kex},
+		hostKey:     []string{hostKeyAlg},
+		ciphersC2S:  server.ciphersC2S,
+		ciphersS2C:  server.ciphersS2C,
+		macsC2S:     server.macsC2S,
+		macsS2C:     server.macsS2C,
+		compressC2S: server.compressC2S,
+		compressS2C: server.compressS2C,
+	}
+	start, err := kexInitMessage(kex)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if err := writePacket(conn, client.marshal()); err != nil {
+		return "", nil, nil, err
+	}
+	if err := writePacket(conn, start); err != nil {
+		return "", nil, nil, err
+	}
+
+	reply, err := readPacket(r)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if reply[0] != msgKexDHReply {
+		return "", nil, nil, fmt.Errorf("unexpected message %d", reply[0])
+	}
+	blob, _, ok := readString(reply[1:])
+	if !ok {
+		return "", nil, nil, errors.New("truncated key exchange reply")
+	}
+	return version, server, blob, nil
+}
+
+// readVersion reads the server's identification string, skipping the
+// lines servers may send before it.
+func readVersion(r *bufio.Reader) (string, error) {
+	for i := 0; i < 20; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			return probe.Sanitize(line, maxVersion), nil
+		}
+	}
+	return "", errors.New("no SSH identification")
+}
+
+// kexInitMessage builds the client's first key exchange message: a fresh
+// ephemeral public value for the algorithm.
+func kexInitMessage(kex string) ([]byte, error) {
+	var curve ecdh.Curve
+	switch kex {
+	case "curve25519-sha256", "curve25519-sha256@libssh.org":
+		curve = ecdh.X25519()
+	case "ecdh-sha2-nistp256":
+		curve = ecdh.P256()
+	case "ecdh-sha2-nistp384":
+		curve = ecdh.P384()
+	case "ecdh-sha2-nistp521":
+		curve = ecdh.P521()
+	case "diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1":
+		return dhInit(oakleyGroup14)
+	case "diffie-hellman-group1-sha1":
+		return dhInit(oakleyGroup2)
+	default:
+		return nil, fmt.Errorf("unsupported key exchange %s", kex)
+	}
+	key, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return appendString([]byte{msgKexDHInit}, key.PublicKey().Bytes()), nil
+}
+
+// MODP groups 2 (RFC 2409) and 14 (RFC 3526), generator 2.
+var (
+	oakleyGroup2  = mustPrime("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381FFFFFFFFFFFFFFFF")
+	oakleyGroup14 = mustPrime("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF")
+)
+
+// mustPrime parses a hexadecimal group prime.
+func mustPrime(s string) *big.Int {
+	p, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("sshinfo: bad prime")
+	}
+	return p
+}
+
+// dhInit builds a KEXDH_INIT message with e = 2^x mod p.
+func dhInit(p *big.Int) ([]byte, error) {
+	x, err := rand.Int(rand.Reader, new(big.Int).Sub(p, big.NewInt(2)))
+	if err != nil {
+		return nil, err
+	}
+	x.Add(x, big.NewInt(1))
+	e := new(big.Int).Exp(big.NewInt(2), x, p)
+	return appendMpint([]byte{msgKexDHInit}, e), nil
+}
+
+// hostKeyAlgorithms picks one signature algorithm per host key type from
+// the server's list, in the server's order. RSA keys are offered under
+// several names (rsa-sha2-512, rsa-sha2-256, ssh-rsa) that share one key.
+// Certificate and security key algorithms are skipped.
+func hostKeyAlgorithms(offered []string) []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, alg := range offered {
+		keyType := hostKeyType(alg)
+		if keyType == "" || seen[keyType] {
+			continue
+		}
+		seen[keyType] = true
+		algs = append(algs, alg)
+	}
+	return algs
+}
+
+// hostKeyType returns the key type a host key algorithm signs with, or ""
+// for algorithms maki does not collect.
+func hostKeyType(alg string) string {
+	switch alg {
+	case "rsa-sha2-512", "rsa-sha2-256", "ssh-rsa":
+		return "ssh-rsa"
+	case "ssh-ed25519", "ssh-dss", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		return alg
+	}
+	return ""
+}
+
+// describeKey parses a public key blob.
+func describeKey(blob []byte) (scanner.SSHHostKey, bool) {
+	keyType, rest, ok := readString(blob)
+	if !ok {
+		return scanner.SSHHostKey{}, false
+	}
+	sum := sha256.Sum256(blob)
+	key := scanner.SSHHostKey{
+		Type:        string(keyType),
+		Key:         base64.StdEncoding.EncodeToString(blob),
+		Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+	}
+	switch key.Type {
+	case "ssh-rsa":
+		// e, then the modulus n
+		if _, rest, ok = readString(rest); ok {
+			if n, _, ok := readString(rest); ok {
+				key.Bits = new(big.Int).SetBytes(n).BitLen()
+			}
+		}
+	case "ssh-dss":
+		if p, _, ok := readString(rest); ok {
+			key.Bits = new(big.Int).SetBytes(p).BitLen()
+		}
+	case "ssh-ed25519":
+		key.Bits = 256
+	case "ecdsa-sha2-nistp256":
+		key.Bits = 256
+	case "ecdsa-sha2-nistp384":
+		key.Bits = 384
+	case "ecdsa-sha2-nistp521":
+		key.Bits = 521
+	}
+	return key, true
+}
+
+// firstCommon returns the first of want that offered contains.
+func firstCommon(want, offered []string) string {
+	for _, w := range want {
+		for _, o := range offered {
+			if w == o {
+				return w
+			}
+		}
+	}
+	return ""
+}
+
+// union returns the names in a followed by those only in b.
+func union(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, name := range b {
+		if firstCommon([]string{name}, a) == "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package sshinfo
+
+import (
+	"fmt"
+	"strings"
+
+	"maki/internal/scanner"
+)
+
+// minRSABits is the smallest RSA or DSA host key not flagged as weak.
+const minRSABits = 2048
+
+// weakKEX are key exchanges built on SHA-1 or groups under 2048 bits.
+var weakKEX = map[string]bool{
+	"diffie-hellman-group1-sha1":         true,
+	"diffie-hellman-group14-sha1":        true,
+	"diffie-hellman-group-exchange-sha1": true,
+	"rsa1024-sha1":                       true,
+}
+
+// weakHostKeyAlgorithms sign with SHA-1 or DSA.
+var weakHostKeyAlgorithms = map[string]bool{
+	"ssh-rsa":                      true,
+	"ssh-dss":                      true,
+	"ssh-rsa-cert-v01@openssh.com": true,
+	"ssh-dss-cert-v01@openssh.com": true,
+}
+
+// weakCipher reports whether a cipher is broken or uses CBC mode.
+func weakCipher(name string) bool {
+	return name == "none" ||
+		strings.HasSuffix(name, "-cbc") ||
+		strings.HasSuffix(name, "-cbc@lysator.liu.se") ||
+		strings.HasPrefix(name, "arcfour") ||
+		strings.HasPrefix(name, "des")
+}
+
+// weakMAC reports whether a MAC uses MD5 or SHA-1, or is truncated to 96
+// or 64 bits.
+func weakMAC(name string) bool {
+	return name == "none" ||
+		strings.Contains(name, "md5") ||
+		strings.HasPrefix(name, "hmac-sha1") ||
+		strings.HasPrefix(name, "umac-64") ||
+		strings.Contains(name, "-96")
+}
+
+// weaknesses lists what info offers that is considered weak.
+func weaknesses(info *scanner.SSHInfo) []string {
+	var weak []string
+	for _, name := range info.KEX {
+		if weakKEX[name] {
+			weak = append(weak, "kex "+name)
+		}
+	}
+	for _, name := range info.HostKeyAlgorithms {
+		if weakHostKeyAlgorithms[name] {
+			weak = append(weak, "host key algorithm "+name)
+		}
+	}
+	for _, key := range info.HostKeys {
+		if (key.Type == "ssh-rsa" || key.Type == "ssh-dss") && key.Bits < minRSABits {
+			weak = append(weak, fmt.Sprintf("host key %s %d bits", key.Type, key.Bits))
+		}
+	}
+	for _, name := range info.Ciphers {
+		if weakCipher(name) {
+			weak = append(weak, "cipher "+name)
+		}
+	}
+	for _, name := range info.MACs {
+		if weakMAC(name) {
+			weak = append(weak, "mac "+name)
+		}
+	}
+	if strings.HasPrefix(info.Version, "SSH-1.") {
+		weak = append(weak, "protocol "+strings.SplitN(info.Version, "-", 3)[1])
+	}
+	return weak
+}
@@ -0,0 +1,151 @@
+package sshinfo
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// SSH message numbers (RFC 4253, RFC 5656).
+const (
+	msgDisconnect = 1
+	msgIgnore     = 2
+	msgDebug      = 4
+	msgKexInit    = 20
+	msgKexDHInit  = 30 // also KEX_ECDH_INIT
+	msgKexDHReply = 31 // also KEX_ECDH_REPLY
+)
+
+// maxPacket bounds the packets accepted before encryption starts.
+const maxPacket = 35000
+
+// kexInit holds the name-lists of a KEXINIT message.
+type kexInit struct {
+	kex         []string
+	hostKey     []string
+	ciphersC2S  []string
+	ciphersS2C  []string
+	macsC2S     []string
+	macsS2C     []string
+	compressC2S []string
+	compressS2C []string
+}
+
+// marshal encodes k as a KEXINIT payload with a random cookie.
+func (k *kexInit) marshal() []byte {
+	b := []byte{msgKexInit}
+	cookie := make([]byte, 16)
+	_, _ = rand.Read(cookie)
+	b = append(b, cookie...)
+	for _, list := range [][]string{k.kex, k.hostKey, k.ciphersC2S, k.ciphersS2C, k.macsC2S, k.macsS2C, k.compressC2S, k.compressS2C, nil, nil} {
+		b = appendString(b, []byte(strings.Join(list, ",")))
+	}
+	b = append(b, 0)             // first_kex_packet_follows
+	return append(b, 0, 0, 0, 0) // reserved
+}
+
+// parseKexInit decodes a KEXINIT payload.
+func parseKexInit(payload []byte) (*kexInit, error) {
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return nil, errors.New("not a KEXINIT message")
+	}
+	rest := payload[17:]
+	lists := make([][]string, 8)
+	for i := range lists {
+		var s []byte
+		var ok bool
+		if s, rest, ok = readString(rest); !ok {
+			return nil, errors.New("truncated KEXINIT")
+		}
+		if len(s) > 0 {
+			lists[i] = strings.Split(string(s), ",")
+		}
+	}
+	return &kexInit{
+		kex: lists[0], hostKey: lists[1],
+		ciphersC2S: lists[2], ciphersS2C: lists[3],
+		macsC2S: lists[4], macsS2C: lists[5],
+		compressC2S: lists[6], compressS2C: lists[7],
+	}, nil
+}
+
+// readPacket reads one unencrypted binary packet and returns its payload,
+// skipping IGNORE and DEBUG messages.
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		padding := uint32(header[4])
+		if length < padding+2 || length > maxPacket {
+			return nil, fmt.Errorf("invalid packet length %d", length)
+		}
+		body := make([]byte, length-1)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+		payload := body[:length-1-padding]
+		switch payload[0] {
+		case msgIgnore, msgDebug:
+			continue
+		case msgDisconnect:
+			reason := "disconnected"
+			if len(payload) >= 5 {
+				if s, _, ok := readString(payload[5:]); ok {
+					reason = "disconnected: " + string(s)
+				}
+			}
+			return nil, errors.New(reason)
+		}
+		return payload, nil
+	}
+}
+
+// writePacket writes payload as an unencrypted binary packet.
+func writePacket(w io.Writer, payload []byte) error {
+	padding := 8 - (5+len(payload))%8
+	if padding < 4 {
+		padding += 8
+	}
+	packet := make([]byte, 5, 5+len(payload)+padding)
+	binary.BigEndian.PutUint32(packet, uint32(1+len(payload)+padding))
+	packet[4] = byte(padding)
+	packet = append(packet, payload...)
+	packet = append(packet, make([]byte, padding)...)
+	_, err := w.Write(packet)
+	return err
+}
+
+// appendString appends s as an SSH string.
+func appendString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// appendMpint appends n as an SSH mpint; n must not be negative.
+func appendMpint(b []byte, n *big.Int) []byte {
+	data := n.Bytes()
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		data = append([]byte{0}, data...)
+	}
+	return appendString(b, data)
+}
+
+// readString reads an SSH string from b.
+func readString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
@@ -0,0 +1,155 @@
+package sshinfo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// packet frames payload as a binary packet with the given padding length.
+func packet(payload []byte, padding int) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padding))
+	b = append(b, byte(padding))
+	b = append(b, payload...)
+	return append(b, make([]byte, padding)...)
+}
+
+func TestReadPacket(t *testing.T) {
+	kexinit := (&kexInit{kex: []string{"curve25519-sha256"}}).marshal()
+	disconnect := appendString([]byte{msgDisconnect, 0, 0, 0, 2}, []byte("too many connections"))
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  []byte
+		err   string
+	}{
+		{"payload", packet(kexinit, 4), kexinit, ""},
+		{"no padding", packet([]byte{msgKexDHReply}, 0), []byte{msgKexDHReply}, ""},
+		{"written by writePacket", func() []byte {
+			var b bytes.Buffer
+			_ = writePacket(&b, kexinit)
+			return b.Bytes()
+		}(), kexinit, ""},
+		{"ignore and debug skipped", append(append(packet([]byte{msgIgnore, 1, 2}, 4), packet([]byte{msgDebug}, 6)...), packet(kexinit, 4)...), kexinit, ""},
+		{"disconnect", packet(disconnect, 4), nil, "disconnected: too many connections"},
+		{"disconnect without reason", packet([]byte{msgDisconnect, 0, 0}, 4), nil, "disconnected"},
+		{"empty", nil, nil, io.EOF.Error()},
+		{"truncated header", packet(kexinit, 4)[:3], nil, io.ErrUnexpectedEOF.Error()},
+		{"truncated body", packet(kexinit, 4)[:40], nil, io.ErrUnexpectedEOF.Error()},
+		{"truncated after ignore", packet([]byte{msgIgnore}, 4), nil, io.EOF.Error()},
+		{"oversized", append(binary.BigEndian.AppendUint32(nil, maxPacket+1), 4), nil, "invalid packet length 35001"},
+		{"huge", []byte{0xff, 0xff, 0xff, 0xff, 4}, nil, "invalid packet length 4294967295"},
+		{"zero length", []byte{0, 0, 0, 0, 0}, nil, "invalid packet length 0"},
+		{"padding covers payload", []byte{0, 0, 0, 5, 4, 20, 0, 0, 0}, nil, "invalid packet length 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPacket(bufio.NewReader(bytes.NewReader(tt.input)))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("readPacket() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readPacket(): %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("readPacket() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKexInit(t *testing.T) {
+	want := &kexInit{
+		kex:         []string{"curve25519-sha256", "diffie-hellman-group14-sha256"},
+		hostKey:     []string{"rsa-sha2-512", "ssh-ed25519"},
+		ciphersC2S:  []string{"aes128-ctr"},
+		ciphersS2C:  []string{"aes256-ctr", "aes128-ctr"},
+		macsC2S:     []string{"hmac-sha2-256"},
+		macsS2C:     []string{"hmac-sha2-256"},
+		compressC2S: []string{"none"},
+	}
+	payload := want.marshal()
+	got, err := parseKexInit(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKexInit() = %+v, want %+v", got, want)
+	}
+
+	// Cut the payload inside each of the eight name-lists in turn.
+	var cuts []int
+	off := 17
+	for i := 0; i < 8; i++ {
+		n := int(binary.BigEndian.Uint32(payload[off:]))
+		cuts = append(cuts, off+2)
+		if n > 0 {
+			cuts = append(cuts, off+4+n/2)
+		}
+		off += 4 + n
+	}
+	for _, cut := range cuts {
+		if _, err := parseKexInit(payload[:cut]); err == nil || err.Error() != "truncated KEXINIT" {
+			t.Errorf("parseKexInit() of %d of %d bytes: error = %v", cut, len(payload), err)
+		}
+	}
+
+	for name, bad := range map[string][]byte{
+		"empty":         nil,
+		"no cookie":     payload[:10],
+		"wrong type":    append([]byte{msgKexDHReply}, payload[1:]...),
+		"huge length":   append(append([]byte(nil), payload[:17]...), 0xff, 0xff, 0xff, 0xff, 'a'),
+		"only a cookie": payload[:17],
+	} {
+		if _, err := parseKexInit(bad); err == nil {
+			t.Errorf("parseKexInit(%s) succeeded", name)
+		}
+	}
+}
+
+func TestReadString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		s    string
+		rest string
+		ok   bool
+	}{
+		{"string", []byte("\x00\x00\x00\x03abcdef"), "abc", "def", true},
+		{"exact", []byte("\x00\x00\x00\x03abc"), "abc", "", true},
+		{"empty string", []byte("\x00\x00\x00\x00x"), "", "x", true},
+		{"short data", []byte("\x00\x00\x00\x04abc"), "", "", false},
+		{"huge length", []byte("\xff\xff\xff\xffabc"), "", "", false},
+		{"short length", []byte("\x00\x00\x03"), "", "", false},
+		{"nil", nil, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, rest, ok := readString(tt.in)
+			if ok != tt.ok || string(s) != tt.s || string(rest) != tt.rest {
+				t.Errorf("readString() = %q, %q, %v; want %q, %q, %v", s, rest, ok, tt.s, tt.rest, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	got, err := readVersion(bufio.NewReader(strings.NewReader("banner line\r\nSSH-2.0-OpenSSH_9.6\r\n")))
+	if err != nil || got != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("readVersion() = %q, %v", got, err)
+	}
+	if _, err := readVersion(bufio.NewReader(strings.NewReader(strings.Repeat("noise\n", 30)))); err == nil {
+		t.Error("readVersion() accepted a server without an identification")
+	}
+	if _, err := readVersion(bufio.NewReader(strings.NewReader("SSH-2.0-cut"))); err == nil {
+		t.Error("readVersion() accepted an unterminated identification")
+	}
+}
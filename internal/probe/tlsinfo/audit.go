@@ -0,0 +1,127 @@
+package tlsinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"time"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// auditVersions are the protocol versions Audit tries, highest first.
+// SSL 3.0 is not listed: crypto/tls cannot speak it.
+var auditVersions = []uint16{tls.VersionTLS13, tls.VersionTLS12, tls.VersionTLS11, tls.VersionTLS10}
+
+// Audit enumerates the protocol versions and cipher suites the TLS server
+// at address accepts. For TLS 1.0 to 1.2, every suite crypto/tls knows is
+// offered, and the one the server picks is removed before the next
+// handshake, until the server refuses the rest. TLS 1.3 suites cannot be
+// chosen by the client in crypto/tls, so only the one negotiated is
+// recorded. Each handshake takes up to timeout.
+func Audit(ctx context.Context, d probe.Dialer, address string, timeout time.Duration) (*scanner.TLSInfo, error) {
+	// One handshake open to every version first, so that ports not
+	// speaking TLS cost a single attempt.
+	if _, err := handshake(ctx, d, address, timeout, tls.VersionTLS10, tls.VersionTLS13, allSuites(tls.VersionTLS12)); err != nil {
+		return nil, err
+	}
+
+	info := &scanner.TLSInfo{}
+	for _, version := range auditVersions {
+		var suites []string
+		offered := allSuites(version)
+		for ctx.Err() == nil {
+			state, err := handshake(ctx, d, address, timeout, version, version, offered)
+			if err != nil {
+				break
+			}
+			suites = append(suites, tls.CipherSuiteName(state.CipherSuite))
+			if version == tls.VersionTLS13 {
+				break
+			}
+			offered = without(offered, state.CipherSuite)
+			if len(offered) == 0 {
+				break
+			}
+		}
+		if len(suites) > 0 {
+			info.Versions = append(info.Versions, scanner.TLSVersion{Name: tls.VersionName(version), Suites: suites})
+		}
+	}
+	info.Weak = weaknesses(info)
+	return info, ctx.Err()
+}
+
+// handshake performs one TLS handshake limited to the versions from min
+// to max and the given suites.
+func handshake(ctx context.Context, d probe.Dialer, address string, timeout time.Duration, min, max uint16, suites []uint16) (tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	raw, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer raw.Close()
+
+	conn := tls.Client(raw, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         min,
+		MaxVersion:         max,
+		CipherSuites:       suites,
+	})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return conn.ConnectionState(), nil
+}
+
+// allSuites returns the IDs of every TLS 1.0-1.2 cipher suite crypto/tls
+// implements, secure or not, that can be used with version.
+func allSuites(version uint16) []uint16 {
+	var ids []uint16
+	for _, list := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, cs := range list {
+			for _, v := range cs.SupportedVersions {
+				if v == version {
+					ids = append(ids, cs.ID)
+					break
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// without returns ids minus id.
+func without(ids []uint16, id uint16) []uint16 {
+	out := make([]uint16, 0, len(ids))
+	for _, x := range ids {
+		if x != id {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// weaknesses lists the deprecated protocol versions (TLS 1.0 and 1.1,
+// RFC 8996) and cipher suites (RC4, RFC 7465, and 3DES) info accepts.
+func weaknesses(info *scanner.TLSInfo) []string {
+	var weak []string
+	for _, v := range info.Versions {
+		if v.Name == "TLS 1.0" || v.Name == "TLS 1.1" {
+			weak = append(weak, "protocol "+v.Name)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, v := range info.Versions {
+		for _, suite := range v.Suites {
+			if (strings.Contains(suite, "_RC4_") || strings.Contains(suite, "_3DES_")) && !seen[suite] {
+				seen[suite] = true
+				weak = append(weak, "cipher "+suite)
+			}
+		}
+	}
+	return weak
+}
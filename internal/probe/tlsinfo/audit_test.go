@@ -0,0 +1,114 @@
+package tlsinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// tlsServer starts an HTTPS server accepting only the versions from min
+// to max and, below TLS 1.3, the given suites, and returns its address.
+func tlsServer(t *testing.T, min, max uint16, suites ...uint16) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{MinVersion: min, MaxVersion: max, CipherSuites: suites}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String()
+}
+
+// audit audits address and sorts each version's suites, as the order
+// they are found in depends on the server's preferences.
+func audit(t *testing.T, address string) *scanner.TLSInfo {
+	t.Helper()
+	info, err := Audit(context.Background(), &net.Dialer{}, address, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range info.Versions {
+		sort.Strings(v.Suites)
+	}
+	return info
+}
+
+func TestAuditLegacy(t *testing.T) {
+	addr := tlsServer(t, tls.VersionTLS10, tls.VersionTLS12,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA)
+
+	cbc := []string{"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA", "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"}
+	want := &scanner.TLSInfo{
+		Versions: []scanner.TLSVersion{
+			{Name: "TLS 1.2", Suites: []string{"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA", "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			{Name: "TLS 1.1", Suites: cbc},
+			{Name: "TLS 1.0", Suites: cbc},
+		},
+		Weak: []string{"protocol TLS 1.1", "protocol TLS 1.0", "cipher TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA"},
+	}
+	if got := audit(t, addr); !reflect.DeepEqual(got, want) {
+		t.Errorf("Audit() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestAuditModern(t *testing.T) {
+	addr := tlsServer(t, tls.VersionTLS12, tls.VersionTLS13,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256)
+
+	got := audit(t, addr)
+	if len(got.Versions) != 2 || got.Versions[0].Name != "TLS 1.3" || got.Versions[1].Name != "TLS 1.2" {
+		t.Fatalf("Audit() versions = %+v, want TLS 1.3 and TLS 1.2", got.Versions)
+	}
+	// TLS 1.3 suites cannot be offered selectively: only the negotiated
+	// one is recorded.
+	if len(got.Versions[0].Suites) != 1 {
+		t.Errorf("TLS 1.3 suites = %v, want the negotiated one", got.Versions[0].Suites)
+	}
+	if want := []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}; !reflect.DeepEqual(got.Versions[1].Suites, want) {
+		t.Errorf("TLS 1.2 suites = %v, want %v", got.Versions[1].Suites, want)
+	}
+	if got.Weak != nil {
+		t.Errorf("Weak = %v, want none", got.Weak)
+	}
+}
+
+func TestAuditNotTLS(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	if info, err := Audit(context.Background(), &net.Dialer{}, srv.Listener.Addr().String(), 2*time.Second); err == nil {
+		t.Errorf("Audit() of plain HTTP = %+v", info)
+	}
+}
+
+func TestWithout(t *testing.T) {
+	ids := []uint16{1, 2, 3, 2}
+	if got := without(ids, 2); !reflect.DeepEqual(got, []uint16{1, 3}) {
+		t.Errorf("without(%v, 2) = %v", ids, got)
+	}
+	if got := without(ids, 9); !reflect.DeepEqual(got, ids) {
+		t.Errorf("without(%v, 9) = %v", ids, got)
+	}
+	if !reflect.DeepEqual(ids, []uint16{1, 2, 3, 2}) {
+		t.Errorf("without() modified its argument: %v", ids)
+	}
+}
+
+func TestWeaknesses(t *testing.T) {
+	info := &scanner.TLSInfo{Versions: []scanner.TLSVersion{
+		{Name: "TLS 1.2", Suites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_RC4_128_SHA"}},
+		{Name: "TLS 1.0", Suites: []string{"TLS_ECDHE_RSA_WITH_RC4_128_SHA", "TLS_RSA_WITH_3DES_EDE_CBC_SHA"}},
+	}}
+	want := []string{"protocol TLS 1.0", "cipher TLS_ECDHE_RSA_WITH_RC4_128_SHA", "cipher TLS_RSA_WITH_3DES_EDE_CBC_SHA"}
+	if got := weaknesses(info); !reflect.DeepEqual(got, want) {
+		t.Errorf("weaknesses() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+// Package tlsinfo harvests the certificate a TLS server presents and
+// audits the protocol versions and cipher suites it accepts. Handshakes
+// skip verification on purpose: expired, self-signed and mismatched
+// certificates are exactly what an inventory needs to see.
+package tlsinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+// Grab performs a TLS handshake with address and describes the leaf
+// certificate. serverName is sent as SNI when not empty, which servers
+// hosting several names need to pick the right certificate.
+func Grab(ctx context.Context, d probe.Dialer, address, serverName string, timeout time.Duration) (*scanner.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	raw, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer raw.Close()
+
+	conn := tls.Client(raw, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate presented")
+	}
+	return Describe(certs[0]), nil
+}
+
+// Describe summarizes cert.
+func Describe(cert *x509.Certificate) *scanner.Certificate {
+	sum := sha256.Sum256(cert.Raw)
+	c := &scanner.Certificate{
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		KeyType:     keyType(cert),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		SelfSigned:  selfSigned(cert),
+	}
+	c.SANs = append(c.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, ip.String())
+	}
+	return c
+}
+
+// keyType names the public key algorithm and its size or curve.
+func keyType(cert *x509.Certificate) string {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
+
+// selfSigned reports whether cert is its own issuer and signed with its
+// own key. CheckSignatureFrom is not used because it also requires the
+// issuer to be a CA, which self-signed server certificates rarely are.
+func selfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
@@ -0,0 +1,123 @@
+package tlsinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// newCert creates a certificate for key with the given subject, signed by
+// parent and its key, or self-signed when parent is nil.
+func newCert(t *testing.T, subject string, isCA bool, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		DNSNames:              []string{subject},
+		IPAddresses:           []net.IP{net.ParseIP("192.0.2.1")},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func ecKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSelfSigned(t *testing.T) {
+	caKey, leafKey, otherKey := ecKey(t, elliptic.P256()), ecKey(t, elliptic.P256()), ecKey(t, elliptic.P256())
+	ca := newCert(t, "Test CA", true, caKey, nil, nil)
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want bool
+	}{
+		{"CA root", ca, true},
+		{"self-signed server", newCert(t, "nas.local", false, leafKey, nil, nil), true},
+		{"CA-signed", newCert(t, "www.example.com", false, leafKey, ca, caKey), false},
+		// Issuer and subject match, but the CA's key signed it: a leaf
+		// named like its CA is not self-signed.
+		{"named like its issuer", newCert(t, "Test CA", false, otherKey, ca, caKey), false},
+	}
+	for _, tt := range tests {
+		if got := selfSigned(tt.cert); got != tt.want {
+			t.Errorf("%s: selfSigned() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  crypto.Signer
+		want string
+	}{
+		{rsaKey, "RSA 2048"},
+		{ecKey(t, elliptic.P256()), "ECDSA P-256"},
+		{ecKey(t, elliptic.P384()), "ECDSA P-384"},
+		{edKey, "Ed25519"},
+	}
+	for _, tt := range tests {
+		if got := keyType(newCert(t, "example.com", false, tt.key, nil, nil)); got != tt.want {
+			t.Errorf("keyType() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	caKey, leafKey := ecKey(t, elliptic.P256()), ecKey(t, elliptic.P256())
+	ca := newCert(t, "Test CA", true, caKey, nil, nil)
+	cert := newCert(t, "www.example.com", false, leafKey, ca, caKey)
+
+	got := Describe(cert)
+	if got.Subject != "CN=www.example.com" || got.Issuer != "CN=Test CA" || got.SelfSigned {
+		t.Errorf("Describe() = %+v", got)
+	}
+	if len(got.SANs) != 2 || got.SANs[0] != "www.example.com" || got.SANs[1] != "192.0.2.1" {
+		t.Errorf("SANs = %v, want [www.example.com 192.0.2.1]", got.SANs)
+	}
+	if !got.NotBefore.Equal(cert.NotBefore) || !got.NotAfter.Equal(cert.NotAfter) || got.KeyType != "ECDSA P-256" {
+		t.Errorf("Describe() = %+v", got)
+	}
+	if len(got.Fingerprint) != 64 {
+		t.Errorf("Fingerprint = %q, want 64 hex digits", got.Fingerprint)
+	}
+}
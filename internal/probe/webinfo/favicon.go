@@ -0,0 +1,68 @@
+package webinfo
+
+import (
+	"encoding/base64"
+	"math/bits"
+	"strings"
+)
+
+// FaviconHash hashes a favicon the way Shodan's http.favicon.hash does:
+// MurmurHash3 (32-bit, seed 0) of the icon encoded as MIME base64, with a
+// newline after every 76 characters and at the end (nothing at all for an
+// empty icon). The same value can be searched for on Shodan to find other
+// instances of an appliance.
+func FaviconHash(icon []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(icon)
+	var b strings.Builder
+	for len(encoded) > 0 {
+		n := min(len(encoded), 76)
+		b.WriteString(encoded[:n])
+		b.WriteByte('\n')
+		encoded = encoded[n:]
+	}
+	return int32(murmur3([]byte(b.String()), 0))
+}
+
+// murmur3 is MurmurHash3_x86_32.
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := uint32(data[4*i]) | uint32(data[4*i+1])<<8 | uint32(data[4*i+2])<<16 | uint32(data[4*i+3])<<24
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[4*n:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
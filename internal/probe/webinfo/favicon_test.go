@@ -0,0 +1,58 @@
+package webinfo
+
+import "testing"
+
+// seq returns n bytes counting up from 0 in steps of step, wrapping at 256.
+func seq(n, step int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * step)
+	}
+	return b
+}
+
+func TestFaviconHash(t *testing.T) {
+	// Expected values are mmh3.hash(codecs.encode(icon, "base64")), the
+	// recipe Shodan documents for http.favicon.hash.
+	tests := []struct {
+		name string
+		icon []byte
+		want int32
+	}{
+		{"empty", nil, 0},
+		{"short", []byte("\x00\x01\x02icon"), -623927187},
+		{"one full line", seq(57, 1), 459585070},
+		{"one line and a bit", seq(58, 1), -280317500},
+		{"two full lines", seq(114, 1), 1266597604},
+		{"several lines", seq(300, 7), 1507977274},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FaviconHash(tt.icon); got != tt.want {
+				t.Errorf("FaviconHash() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMurmur3(t *testing.T) {
+	// Verification vectors for MurmurHash3_x86_32.
+	tests := []struct {
+		data string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"\xff\xff\xff\xff", 0, 0x76293b50},
+		{"!Ce\x87", 0, 0xf55b516b},
+		{"foo", 0, 0xf6a5c420},
+		{"Hello, world!", 0x9747b28c, 0x24884cba},
+		{"The quick brown fox jumps over the lazy dog", 0, 0x2e4ff723},
+	}
+	for _, tt := range tests {
+		if got := murmur3([]byte(tt.data), tt.seed); got != tt.want {
+			t.Errorf("murmur3(%q, %#x) = %#x, want %#x", tt.data, tt.seed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package webinfo fingerprints web servers: the status, Server header,
+// title and redirect of the front page, and the favicon hash, which
+// together recognize admin panels, printers and appliances at a glance.
+package webinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"maki/internal/probe"
+	"maki/internal/scanner"
+)
+
+const (
+	// maxBody bounds how much of the front page is read for the title
+	// and favicon link.
+	maxBody = 64 << 10
+
+	// maxFavicon bounds the size of the favicon that is hashed.
+	maxFavicon = 256 << 10
+
+	// maxText bounds the length of the stored header values and title.
+	maxText = 120
+)
+
+var (
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	linkRe  = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	relRe   = regexp.MustCompile(`(?is)\brel\s*=\s*["']?([^"'>]*)`)
+	hrefRe  = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// Fetch requests the front page of the web server on ip:port and
+// fingerprints it. HTTPS is used when useTLS is set; otherwise plain HTTP
+// is tried first and HTTPS after it, for TLS ports the caller did not
+// know about. Redirects are recorded, not followed.
+func Fetch(ctx context.Context, d probe.Dialer, ip string, port int, useTLS bool, timeout time.Duration) (*scanner.HTTPInfo, error) {
+	client := newClient(d, timeout)
+	host := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	if !useTLS {
+		info, body, err := fetchPage(ctx, client, "http://"+host+"/")
+		if err == nil && !(info.Status == http.StatusBadRequest && strings.Contains(body, "HTTPS")) {
+			info.FaviconHash = faviconHash(ctx, client, info.URL, body)
+			return info, nil
+		}
+		// A TLS port answers plain HTTP with a handshake error or, like
+		// nginx, with "400 The plain HTTP request was sent to HTTPS port".
+	}
+
+	info, body, err := fetchPage(ctx, client, "https://"+host+"/")
+	if err != nil {
+		return nil, err
+	}
+	info.FaviconHash = faviconHash(ctx, client, info.URL, body)
+	return info, nil
+}
+
+// newClient returns an HTTP client that dials through d, does not verify
+// certificates and does not follow redirects.
+func newClient(d probe.Dialer, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       d.DialContext,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// fetchPage requests rawURL and describes the response, returning the
+// start of the body as well.
+func fetchPage(ctx context.Context, client *http.Client, rawURL string) (*scanner.HTTPInfo, string, error) {
+	resp, err := get(ctx, client, rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	body := string(data)
+
+	info := &scanner.HTTPInfo{
+		URL:    rawURL,
+		Status: resp.StatusCode,
+		Server: probe.Sanitize(resp.Header.Get("Server"), maxText),
+	}
+	if m := titleRe.FindStringSubmatch(body); m != nil {
+		title := strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+		info.Title = probe.Sanitize(title, maxText)
+	}
+	if loc := resp.Header.Get("Location"); loc != "" {
+		info.Redirect = probe.Sanitize(resolve(rawURL, loc), maxText)
+	}
+	return info, body, nil
+}
+
+// faviconHash fetches the icon linked from the page, or /favicon.ico,
+// and hashes it. Icons on other hosts are not fetched.
+func faviconHash(ctx context.Context, client *http.Client, pageURL, body string) string {
+	iconURL := resolve(pageURL, "/favicon.ico")
+	if href := iconLink(body); href != "" {
+		iconURL = resolve(pageURL, href)
+	}
+	page, err1 := url.Parse(pageURL)
+	icon, err2 := url.Parse(iconURL)
+	if err1 != nil || err2 != nil || icon.Host != page.Host {
+		return ""
+	}
+
+	resp, err := get(ctx, client, iconURL)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFavicon))
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(FaviconHash(data)))
+}
+
+// iconLink returns the href of the first <link rel="icon"> (or "shortcut
+// icon") in body.
+func iconLink(body string) string {
+	for _, tag := range linkRe.FindAllString(body, -1) {
+		rel := relRe.FindStringSubmatch(tag)
+		if rel == nil || !strings.Contains(strings.ToLower(rel[1]), "icon") {
+			continue
+		}
+		if href := hrefRe.FindStringSubmatch(tag); href != nil {
+			return html.UnescapeString(href[1] + href[2] + href[3])
+		}
+	}
+	return ""
+}
+
+// get requests rawURL with ctx.
+func get(ctx context.Context, client *http.Client, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "maki")
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%s: %v", rawURL, err)
+	}
+	return resp, nil
+}
+
+// resolve resolves ref against base, returning ref unchanged when either
+// does not parse.
+func resolve(base, ref string) string {
+	b, err1 := url.Parse(base)
+	r, err2 := url.Parse(ref)
+	if err1 != nil || err2 != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
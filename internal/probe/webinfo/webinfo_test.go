@@ -0,0 +1,288 @@
+package webinfo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// testIcon is the favicon the test sites serve.
+var testIcon = []byte("\x00\x00\x01\x00\x01\x00\x10\x10not really an icon")
+
+// site serves pages by path and records the paths requested.
+type site struct {
+	pages map[string]http.HandlerFunc
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func (s *site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requested = append(s.requested, r.URL.Path)
+	s.mu.Unlock()
+	w.Header().Set("Server", "test/1.0")
+	if page, ok := s.pages[r.URL.Path]; ok {
+		page(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (s *site) paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requested...)
+}
+
+// page serves body as HTML with the given status.
+func page(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+func serveIcon(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write(testIcon)
+}
+
+// fetch fingerprints the server listening on addr.
+func fetch(t *testing.T, addr net.Addr, useTLS bool) (*scanner.HTTPInfo, error) {
+	t.Helper()
+	tcp := addr.(*net.TCPAddr)
+	return Fetch(context.Background(), &net.Dialer{}, tcp.IP.String(), tcp.Port, useTLS, 2*time.Second)
+}
+
+func TestFetch(t *testing.T) {
+	iconHash := strconv.Itoa(int(FaviconHash(testIcon)))
+	tests := []struct {
+		name      string
+		pages     map[string]http.HandlerFunc
+		want      scanner.HTTPInfo // URL and Redirect relative to the site
+		requested []string
+	}{
+		{
+			name: "title and default favicon",
+			pages: map[string]http.HandlerFunc{
+				"/":            page(200, "<html><head><TITLE lang=en>\n  Router &amp; Admin\n  Panel </TITLE></head></html>"),
+				"/favicon.ico": serveIcon,
+			},
+			want:      scanner.HTTPInfo{Status: 200, Title: "Router & Admin Panel", FaviconHash: iconHash},
+			requested: []string{"/", "/favicon.ico"},
+		},
+		{
+			name: "linked icon",
+			pages: map[string]http.HandlerFunc{
+				"/":                page(200, `<link rel="stylesheet" href="/site.css"><LINK REL="Shortcut Icon" HREF='static/logo.ico?v=2&amp;x=1'><title>NAS</title>`),
+				"/static/logo.ico": serveIcon,
+				"/favicon.ico":     serveIcon,
+			},
+			want:      scanner.HTTPInfo{Status: 200, Title: "NAS", FaviconHash: iconHash},
+			requested: []string{"/", "/static/logo.ico"},
+		},
+		{
+			name: "icon on another host",
+			pages: map[string]http.HandlerFunc{
+				"/":            page(200, `<link rel=icon href=http://cdn.example.com/favicon.ico>`),
+				"/favicon.ico": serveIcon,
+			},
+			want:      scanner.HTTPInfo{Status: 200},
+			requested: []string{"/"},
+		},
+		{
+			name: "no favicon",
+			pages: map[string]http.HandlerFunc{
+				"/": page(200, "<title>\x1b[31mred</title>"),
+			},
+			want:      scanner.HTTPInfo{Status: 200, Title: ".[31mred"},
+			requested: []string{"/", "/favicon.ico"},
+		},
+		{
+			name: "redirect not followed",
+			pages: map[string]http.HandlerFunc{
+				"/": func(w http.ResponseWriter, r *http.Request) {
+					http.Redirect(w, r, "/login?next=%2F", http.StatusFound)
+				},
+				"/login": page(200, "<title>Login</title>"),
+			},
+			want:      scanner.HTTPInfo{Status: 302, Redirect: "/login?next=%2F"},
+			requested: []string{"/", "/favicon.ico"},
+		},
+		{
+			name: "bad request not mistaken for a TLS port",
+			pages: map[string]http.HandlerFunc{
+				"/": page(400, "<title>400 Bad Request</title>"),
+			},
+			want:      scanner.HTTPInfo{Status: 400, Title: "400 Bad Request"},
+			requested: []string{"/", "/favicon.ico"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &site{pages: tt.pages}
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+
+			got, err := fetch(t, srv.Listener.Addr(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			want.URL = srv.URL + "/"
+			want.Server = "test/1.0"
+			if want.Redirect != "" {
+				want.Redirect = srv.URL + want.Redirect
+			}
+			if *got != want {
+				t.Errorf("Fetch() = %+v, want %+v", *got, want)
+			}
+			if paths := s.paths(); !reflect.DeepEqual(paths, tt.requested) {
+				t.Errorf("requested %v, want %v", paths, tt.requested)
+			}
+		})
+	}
+}
+
+func TestFetchTLS(t *testing.T) {
+	s := &site{pages: map[string]http.HandlerFunc{
+		"/":            page(200, "<title>Secure</title>"),
+		"/favicon.ico": serveIcon,
+	}}
+	srv := httptest.NewTLSServer(s)
+	defer srv.Close()
+
+	want := scanner.HTTPInfo{URL: srv.URL + "/", Status: 200, Server: "test/1.0", Title: "Secure", FaviconHash: strconv.Itoa(int(FaviconHash(testIcon)))}
+	for _, useTLS := range []bool{true, false} {
+		// Without useTLS, the plain request fails the handshake and
+		// HTTPS is tried next.
+		got, err := fetch(t, srv.Listener.Addr(), useTLS)
+		if err != nil {
+			t.Fatalf("useTLS %v: %v", useTLS, err)
+		}
+		if *got != want {
+			t.Errorf("useTLS %v: Fetch() = %+v, want %+v", useTLS, *got, want)
+		}
+	}
+}
+
+// nginx400 is nginx's answer to plain HTTP on a TLS port.
+const nginx400 = "<html>\r\n<head><title>400 The plain HTTP request was sent to HTTPS port</title></head>\r\n" +
+	"<body>\r\n<center><h1>400 Bad Request</h1></center>\r\n" +
+	"<center>The plain HTTP request was sent to HTTPS port</center>\r\n<hr><center>nginx</center>\r\n</body>\r\n</html>\r\n"
+
+// nginxTLSPort listens in front of the TLS server backend and, like
+// nginx, answers plain HTTP requests with a 400 error page instead of
+// failing the handshake.
+func nginxTLSPort(t *testing.T, backend *httptest.Server) net.Addr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				r := bufio.NewReader(conn)
+				first, err := r.Peek(1)
+				if err != nil {
+					return
+				}
+				if first[0] != 0x16 { // not a TLS handshake record
+					if _, err := http.ReadRequest(r); err != nil {
+						return
+					}
+					fmt.Fprintf(conn, "HTTP/1.1 400 Bad Request\r\nServer: nginx\r\nContent-Type: text/html\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(nginx400), nginx400)
+					return
+				}
+				up, err := net.Dial("tcp", backend.Listener.Addr().String())
+				if err != nil {
+					return
+				}
+				defer up.Close()
+				go func() { _, _ = io.Copy(up, r) }()
+				_, _ = io.Copy(conn, up)
+			}()
+		}
+	}()
+	return ln.Addr()
+}
+
+func TestFetchPlainHTTPToTLSPort(t *testing.T) {
+	srv := httptest.NewTLSServer(&site{pages: map[string]http.HandlerFunc{
+		"/": page(200, "<title>Behind nginx</title>"),
+	}})
+	defer srv.Close()
+	addr := nginxTLSPort(t, srv)
+
+	got, err := fetch(t, addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://" + addr.String() + "/"; got.URL != want || got.Status != 200 || got.Title != "Behind nginx" {
+		t.Errorf("Fetch() = %+v, want the HTTPS page at %s", *got, want)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	plain := httptest.NewServer(&site{})
+	defer plain.Close()
+	if got, err := fetch(t, plain.Listener.Addr(), true); err == nil {
+		t.Errorf("Fetch() over TLS from a plain HTTP server = %+v", *got)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr()
+	ln.Close()
+	if got, err := fetch(t, addr, false); err == nil {
+		t.Errorf("Fetch() from a closed port = %+v", *got)
+	} else if !strings.Contains(err.Error(), "https://") {
+		t.Errorf("Fetch() error = %v, want the HTTPS attempt's", err)
+	}
+}
+
+func TestIconLink(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`<link rel="icon" href="/i.png">`, "/i.png"},
+		{`<link href='/s.ico' rel='shortcut icon'>`, "/s.ico"},
+		{`<LINK REL=ICON HREF=/u.ico>`, "/u.ico"},
+		{`<link rel="apple-touch-icon" href="/touch.png"><link rel="icon" href="/i.png">`, "/touch.png"},
+		{`<link rel="stylesheet" href="/s.css"><link rel="icon" href="/a.ico?x=1&amp;y=2">`, "/a.ico?x=1&y=2"},
+		{"<link\n  rel=\"icon\"\n  href=\"/multi.ico\"\n>", "/multi.ico"},
+		{`<link rel="icon">`, ""},
+		{`<linkrel="icon" href="/no.ico">`, ""},
+		{`<a rel="icon" href="/no.ico">`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := iconLink(tt.body); got != tt.want {
+			t.Errorf("iconLink(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
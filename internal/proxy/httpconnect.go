@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// httpConnect asks the HTTP proxy to open a tunnel to address with a
+// CONNECT request. Proxies answer 502 or 503 whether the target refused
+// the connection or did not answer, so refusals are only recognized from
+// Squid's X-Squid-Error header; other failures look filtered.
+func (d *Dialer) httpConnect(conn net.Conn, address string) (net.Conn, error) {
+	req := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if user, password, ok := d.credentials(); ok {
+		token := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		req += "Proxy-Authorization: Basic " + token + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy reply: %v", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		conn.Close()
+		if strings.HasSuffix(resp.Header.Get("X-Squid-Error"), " 111") { // ECONNREFUSED on the proxy
+			return nil, fmt.Errorf("http proxy: %w", syscall.ECONNREFUSED)
+		}
+		return nil, fmt.Errorf("http proxy: %s", resp.Status)
+	}
+	return wrap(conn, r), nil
+}
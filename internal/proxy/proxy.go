@@ -0,0 +1,195 @@
+// Package proxy opens TCP connections through a SOCKS5 (RFC 1928) or
+// HTTP CONNECT proxy, so that networks reachable only from a jump host
+// can be scanned from here. Only TCP can be proxied: ICMP, ARP, UDP and
+// raw-socket probes still leave from this machine.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Default proxy ports, used when the URL has none.
+const (
+	defaultSOCKSPort = "1080"
+	defaultHTTPPort  = "8080"
+)
+
+// Dialer connects to addresses through a proxy. It satisfies
+// probe.Dialer.
+type Dialer struct {
+	// Timeout bounds a whole connection: reaching the proxy, the proxy
+	// handshake and the proxy's connection to the target. Zero means no
+	// timeout beyond the context's.
+	Timeout time.Duration
+
+	// Forward, when set, opens the connection to the proxy itself, e.g.
+	// from a chosen source address.
+	Forward interface {
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	}
+
+	url *url.URL
+}
+
+// New parses a proxy URL: socks5://[user:pass@]host[:port] (socks5h is
+// accepted as a synonym, as targets are always IP addresses) or
+// http://[user:pass@]host[:port].
+func New(raw string) (*Dialer, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	port := ""
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		u.Scheme = "socks5"
+		port = defaultSOCKSPort
+	case "http":
+		port = defaultHTTPPort
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use socks5:// or http://)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return &Dialer{url: u}, nil
+}
+
+// String returns the proxy URL without its password.
+func (d *Dialer) String() string {
+	return d.url.Redacted()
+}
+
+// Kind returns "socks5" or "http".
+func (d *Dialer) Kind() string {
+	return d.url.Scheme
+}
+
+// DialContext connects to address through the proxy. When the proxy
+// reports that the target refused the connection, the error wraps
+// syscall.ECONNREFUSED, like a direct connection's would; failing to
+// reach the proxy itself never does.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("proxy: network %s not supported", network)
+	}
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	conn, err := d.dialProxy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if d.Kind() == "socks5" {
+		conn, err = d.socksConnect(ctx, conn, address)
+	} else {
+		conn, err = d.httpConnect(conn, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// Verify connects to the proxy and, for SOCKS5, negotiates
+// authentication, so that an unreachable proxy or wrong credentials are
+// reported before a scan rather than showing up as filtered ports.
+func (d *Dialer) Verify(ctx context.Context) error {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	conn, err := d.dialProxy(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if d.Kind() == "socks5" {
+		return d.socksAuth(conn)
+	}
+	return nil
+}
+
+// dialProxy connects to the proxy, applying the context's deadline to
+// the connection for the handshake. Errors are flattened so that a proxy
+// refusing connections is not mistaken for a closed target port.
+func (d *Dialer) dialProxy(ctx context.Context) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if d.Forward != nil {
+		conn, err = d.Forward.DialContext(ctx, "tcp", d.url.Host)
+	} else {
+		var nd net.Dialer
+		conn, err = nd.DialContext(ctx, "tcp", d.url.Host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach proxy %s: %v", d.url.Host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read into
+// r, while parsing the proxy's reply or waiting for the target to settle.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// wrap returns conn, or a bufferedConn when r holds bytes that came after
+// the proxy's reply.
+func wrap(conn net.Conn, r *bufio.Reader) net.Conn {
+	if r.Buffered() == 0 {
+		return conn
+	}
+	return &bufferedConn{Conn: conn, r: r}
+}
+
+// credentials returns the user name and password in the proxy URL.
+func (d *Dialer) credentials() (user, password string, ok bool) {
+	if d.url.User == nil {
+		return "", "", false
+	}
+	password, _ = d.url.User.Password()
+	return d.url.User.Username(), password, true
+}
+
+// hostPort splits address, rejecting host names: the scanner only dials
+// IP addresses, and resolving names through the proxy is not needed.
+func hostPort(address string) (net.IP, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("proxy: %q is not an IP address", host)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, 0, fmt.Errorf("proxy: invalid port %q", portStr)
+	}
+	return ip, port, nil
+}
@@ -0,0 +1,334 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeServer serves each connection accepted on a local port with handle
+// and returns the port's address.
+func fakeServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// socksServer is a SOCKS5 server that requires user and password when
+// user is set, answers CONNECT requests with reply, sends the requested
+// address on targets and then hands the connection to after, if set.
+func socksServer(t *testing.T, user, password string, reply byte, targets chan<- string, after func(conn net.Conn)) string {
+	return fakeServer(t, func(conn net.Conn) {
+		var head [2]byte
+		if _, err := io.ReadFull(conn, head[:]); err != nil || head[0] != socksVersion {
+			return
+		}
+		methods := make([]byte, head[1])
+		if _, err := io.ReadFull(conn, methods); err != nil {
+			return
+		}
+		if user == "" {
+			_, _ = conn.Write([]byte{socksVersion, socksNoAuth})
+		} else {
+			if !bytes.Contains(methods, []byte{socksUserPass}) {
+				_, _ = conn.Write([]byte{socksVersion, socksNoMethod})
+				return
+			}
+			_, _ = conn.Write([]byte{socksVersion, socksUserPass})
+			var version [1]byte
+			if _, err := io.ReadFull(conn, version[:]); err != nil || version[0] != userPassVersion {
+				return
+			}
+			gotUser := readField(conn)
+			gotPassword := readField(conn)
+			if string(gotUser) != user || string(gotPassword) != password {
+				_, _ = conn.Write([]byte{userPassVersion, 1})
+				return
+			}
+			_, _ = conn.Write([]byte{userPassVersion, userPassAccepted})
+		}
+
+		var req [4]byte
+		if _, err := io.ReadFull(conn, req[:]); err != nil || req[1] != socksConnect {
+			return
+		}
+		addr := make([]byte, net.IPv4len)
+		if req[3] == socksIPv6 {
+			addr = make([]byte, net.IPv6len)
+		}
+		var port [2]byte
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, port[:]); err != nil {
+			return
+		}
+		if targets != nil {
+			targets <- net.JoinHostPort(net.IP(addr).String(), strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+		}
+		_, _ = conn.Write([]byte{socksVersion, reply, 0, socksIPv4, 127, 0, 0, 1, 0x04, 0x38})
+		if after != nil {
+			after(conn)
+		}
+	})
+}
+
+// readField reads a field prefixed with its length in one byte.
+func readField(r io.Reader) []byte {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil
+	}
+	field := make([]byte, b[0])
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil
+	}
+	return field
+}
+
+// dial connects to target through the proxy at raw.
+func dial(t *testing.T, raw, target string) (net.Conn, error) {
+	t.Helper()
+	d, err := New(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Timeout = 2 * time.Second
+	return d.DialContext(context.Background(), "tcp", target)
+}
+
+// closedAddress returns the address of a local port nothing listens on.
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestSOCKSConnect(t *testing.T) {
+	hold := func(conn net.Conn) { _, _ = io.Copy(io.Discard, conn) }
+	hangUp := func(net.Conn) {}
+	greet := func(conn net.Conn) {
+		_, _ = io.WriteString(conn, "SSH-2.0-OpenSSH_9.6\r\n")
+		hold(conn)
+	}
+
+	tests := []struct {
+		name    string
+		reply   byte
+		after   func(conn net.Conn)
+		refused bool
+		failed  bool
+		early   string
+	}{
+		{name: "open", reply: socksSucceeded, after: hold},
+		{name: "early data", reply: socksSucceeded, after: greet, early: "SSH-2.0-OpenSSH_9.6\r\n"},
+		{name: "refused", reply: socksRefused, after: hangUp, refused: true, failed: true},
+		{name: "closed after success", reply: socksSucceeded, after: hangUp, refused: true, failed: true},
+		{name: "host unreachable", reply: 0x04, after: hangUp, failed: true},
+		{name: "general failure", reply: 0x01, after: hangUp, failed: true},
+		{name: "unknown reply", reply: 0x42, after: hangUp, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := make(chan string, 1)
+			addr := socksServer(t, "", "", tt.reply, targets, tt.after)
+			conn, err := dial(t, "socks5://"+addr, "192.0.2.7:22")
+			if got := <-targets; got != "192.0.2.7:22" {
+				t.Errorf("proxy was asked for %s, want 192.0.2.7:22", got)
+			}
+			if refused := errors.Is(err, syscall.ECONNREFUSED); refused != tt.refused {
+				t.Errorf("DialContext() error = %v, refused %v, want refused %v", err, refused, tt.refused)
+			}
+			if (err != nil) != tt.failed {
+				t.Fatalf("DialContext() error = %v, want failure %v", err, tt.failed)
+			}
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if tt.early == "" {
+				return
+			}
+			if _, ok := conn.(*bufferedConn); !ok {
+				t.Errorf("DialContext() = %T, want *bufferedConn holding the early data", conn)
+			}
+			got := make([]byte, len(tt.early))
+			if _, err := io.ReadFull(conn, got); err != nil || string(got) != tt.early {
+				t.Errorf("read %q, %v; want %q", got, err, tt.early)
+			}
+		})
+	}
+}
+
+func TestSOCKSConnectIPv6(t *testing.T) {
+	targets := make(chan string, 1)
+	addr := socksServer(t, "", "", socksRefused, targets, nil)
+	_, _ = dial(t, "socks5h://"+addr, "[2001:db8::1]:443")
+	if got := <-targets; got != "[2001:db8::1]:443" {
+		t.Errorf("proxy was asked for %s, want [2001:db8::1]:443", got)
+	}
+}
+
+func TestSOCKSAuth(t *testing.T) {
+	hold := func(conn net.Conn) { _, _ = io.Copy(io.Discard, conn) }
+	addr := socksServer(t, "scan", "s3cret", socksSucceeded, nil, hold)
+
+	tests := []struct {
+		name string
+		url  string
+		ok   bool
+	}{
+		{"accepted", "socks5://scan:s3cret@" + addr, true},
+		{"rejected", "socks5://scan:wrong@" + addr, false},
+		{"no credentials", "socks5://" + addr, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Timeout = 2 * time.Second
+			if err := d.Verify(context.Background()); (err == nil) != tt.ok {
+				t.Errorf("Verify() = %v, want success %v", err, tt.ok)
+			}
+			conn, err := d.DialContext(context.Background(), "tcp", "192.0.2.7:80")
+			if (err == nil) != tt.ok {
+				t.Errorf("DialContext() error = %v, want success %v", err, tt.ok)
+			}
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				t.Errorf("DialContext() error %v reads as a refused connection", err)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		})
+	}
+}
+
+// httpServer is an HTTP proxy that answers CONNECT requests with reply,
+// sending the request on requests.
+func httpServer(t *testing.T, reply string, requests chan<- *http.Request) string {
+	return fakeServer(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		if requests != nil {
+			requests <- req
+		}
+		_, _ = io.WriteString(conn, reply)
+		_, _ = io.Copy(io.Discard, conn)
+	})
+}
+
+func TestHTTPConnect(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		refused bool
+		failed  bool
+		early   string
+	}{
+		{name: "established", reply: "HTTP/1.1 200 Connection established\r\n\r\n"},
+		{name: "early data", reply: "HTTP/1.1 200 Connection established\r\n\r\n220 mail ESMTP\r\n", early: "220 mail ESMTP\r\n"},
+		{name: "squid refused", reply: "HTTP/1.1 503 Service Unavailable\r\nX-Squid-Error: ERR_CONNECT_FAIL 111\r\nContent-Length: 0\r\n\r\n", refused: true, failed: true},
+		{name: "squid timeout", reply: "HTTP/1.1 503 Service Unavailable\r\nX-Squid-Error: ERR_CONNECT_FAIL 110\r\nContent-Length: 0\r\n\r\n", failed: true},
+		{name: "bad gateway", reply: "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n", failed: true},
+		{name: "forbidden", reply: "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n", failed: true},
+		{name: "not http", reply: "SSH-2.0-OpenSSH_9.6\r\n", failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan *http.Request, 1)
+			addr := httpServer(t, tt.reply, requests)
+			conn, err := dial(t, "http://"+addr, "192.0.2.7:25")
+			if req := <-requests; req.Method != http.MethodConnect || req.Host != "192.0.2.7:25" {
+				t.Errorf("proxy got %s %s, want CONNECT 192.0.2.7:25", req.Method, req.Host)
+			}
+			if refused := errors.Is(err, syscall.ECONNREFUSED); refused != tt.refused {
+				t.Errorf("DialContext() error = %v, refused %v, want refused %v", err, refused, tt.refused)
+			}
+			if (err != nil) != tt.failed {
+				t.Fatalf("DialContext() error = %v, want failure %v", err, tt.failed)
+			}
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if tt.early == "" {
+				if _, ok := conn.(*bufferedConn); ok {
+					t.Error("DialContext() wrapped a connection with nothing buffered")
+				}
+				return
+			}
+			got := make([]byte, len(tt.early))
+			if _, err := io.ReadFull(conn, got); err != nil || string(got) != tt.early {
+				t.Errorf("read %q, %v; want %q", got, err, tt.early)
+			}
+		})
+	}
+}
+
+func TestHTTPConnectAuth(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	addr := httpServer(t, "HTTP/1.1 200 OK\r\n\r\n", requests)
+	conn, err := dial(t, "http://scan:s3cret@"+addr, "192.0.2.7:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	// "scan:s3cret" in base64.
+	if got, want := (<-requests).Header.Get("Proxy-Authorization"), "Basic c2NhbjpzM2NyZXQ="; got != want {
+		t.Errorf("Proxy-Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestUnreachableProxy(t *testing.T) {
+	addr := closedAddress(t)
+	for _, scheme := range []string{"socks5", "http"} {
+		d, err := New(scheme + "://" + addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Timeout = 2 * time.Second
+		_, err = d.DialContext(context.Background(), "tcp", "192.0.2.7:80")
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			t.Errorf("%s: DialContext() through a closed proxy port = %v, want an error that does not read as refused", scheme, err)
+		}
+		if err := d.Verify(context.Background()); err == nil {
+			t.Errorf("%s: Verify() succeeded with no proxy listening", scheme)
+		}
+	}
+}
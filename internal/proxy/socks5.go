@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// SOCKS5 protocol constants (RFC 1928, RFC 1929).
+const (
+	socksVersion     = 5
+	socksNoAuth      = 0x00
+	socksUserPass    = 0x02
+	socksNoMethod    = 0xFF
+	socksConnect     = 0x01
+	socksIPv4        = 0x01
+	socksDomain      = 0x03
+	socksIPv6        = 0x04
+	socksSucceeded   = 0x00
+	socksRefused     = 0x05
+	userPassVersion  = 0x01
+	userPassAccepted = 0x00
+)
+
+// settleTime is how long a connection the proxy reported as established
+// is watched for the proxy hanging up. Some SOCKS servers, ssh -D among
+// them, report success before connecting to the target and close the
+// connection when the target refuses it.
+const settleTime = 250 * time.Millisecond
+
+// socksReplies describes the SOCKS5 reply codes.
+var socksReplies = map[byte]string{
+	0x01: "general failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socksAuth greets the SOCKS5 server and authenticates with the URL's
+// credentials, if any.
+func (d *Dialer) socksAuth(conn net.Conn) error {
+	user, password, hasAuth := d.credentials()
+	greeting := []byte{socksVersion, 1, socksNoAuth}
+	if hasAuth {
+		greeting = []byte{socksVersion, 2, socksNoAuth, socksUserPass}
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	var choice [2]byte
+	if _, err := io.ReadFull(conn, choice[:]); err != nil {
+		return fmt.Errorf("socks5 greeting: %v", err)
+	}
+	if choice[0] != socksVersion {
+		return errors.New("not a SOCKS5 proxy")
+	}
+
+	switch choice[1] {
+	case socksNoAuth:
+		return nil
+	case socksUserPass:
+		if !hasAuth {
+			break
+		}
+		if len(user) > 255 || len(password) > 255 {
+			return errors.New("socks5: user name or password too long")
+		}
+		req := []byte{userPassVersion, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		var status [2]byte
+		if _, err := io.ReadFull(conn, status[:]); err != nil {
+			return fmt.Errorf("socks5 authentication: %v", err)
+		}
+		if status[1] != userPassAccepted {
+			return errors.New("socks5: user name or password rejected")
+		}
+		return nil
+	}
+	return errors.New("socks5: proxy requires an authentication method maki does not offer")
+}
+
+// socksConnect asks the SOCKS5 server to connect to address.
+func (d *Dialer) socksConnect(ctx context.Context, conn net.Conn, address string) (net.Conn, error) {
+	ip, port, err := hostPort(address)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := d.socksAuth(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	req := []byte{socksVersion, socksConnect, 0}
+	if ip4 := ip.To4(); ip4 != nil {
+		req = append(append(req, socksIPv4), ip4...)
+	} else {
+		req = append(append(req, socksIPv6), ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	if err := readSOCKSReply(r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := settle(ctx, conn, r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return wrap(conn, r), nil
+}
+
+// readSOCKSReply reads the reply to a CONNECT request, including the
+// bound address that follows it.
+func readSOCKSReply(r *bufio.Reader) error {
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return fmt.Errorf("socks5 reply: %v", err)
+	}
+	if head[0] != socksVersion {
+		return errors.New("socks5: invalid reply")
+	}
+	if head[1] != socksSucceeded {
+		reason, ok := socksReplies[head[1]]
+		if !ok {
+			reason = fmt.Sprintf("error %d", head[1])
+		}
+		if head[1] == socksRefused {
+			return fmt.Errorf("socks5: %w", syscall.ECONNREFUSED)
+		}
+		return fmt.Errorf("socks5: %s", reason)
+	}
+
+	var n int
+	switch head[3] {
+	case socksIPv4:
+		n = net.IPv4len
+	case socksIPv6:
+		n = net.IPv6len
+	case socksDomain:
+		l, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		n = int(l)
+	default:
+		return errors.New("socks5: invalid bound address type")
+	}
+	_, err := io.ReadFull(r, make([]byte, n+2)) // address and port
+	return err
+}
+
+// settle waits up to settleTime for the proxy to hang up, which proxies
+// that report success before connecting do when the target refuses the
+// connection. Data from the target ends the wait early and stays in r.
+func settle(ctx context.Context, conn net.Conn, r *bufio.Reader) error {
+	wait := time.Now().Add(settleTime)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(wait) {
+		wait = deadline
+	}
+	_ = conn.SetReadDeadline(wait)
+	_, err := r.Peek(1)
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return nil
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("socks5: proxy closed the connection: %w", syscall.ECONNREFUSED)
+	}
+	return err
+}
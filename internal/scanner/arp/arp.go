@@ -1,4 +1,8 @@
 // Package arp implements ARP-based host discovery for local network scanning.
+//
+// On Linux, requests are broadcast and replies read on an AF_PACKET socket
+// bound to the interface (root/CAP_NET_RAW). Elsewhere, or when the socket
+// cannot be opened, the scanner falls back to the arping utility.
 package arp
 
 import (
@@ -9,15 +13,21 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"maki/internal/network"
 	"maki/internal/scanner"
 )
 
-// Scanner implements ARP scanning using the arping utility.
+// Scanner implements ARP scanning.
 type Scanner struct {
 	timeout time.Duration
 	iface   string
+
+	mu        sync.Mutex
+	native    *resolver
+	nativeErr error
 }
 
 // New creates a new ARP scanner with the given timeout and network interface.
@@ -33,13 +43,53 @@ func (s *Scanner) Name() string {
 	return "ARP Scan"
 }
 
+// Close releases the ARP socket opened by the scanner.
+func (s *Scanner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.native == nil {
+		return nil
+	}
+	err := s.native.Close()
+	s.native = nil
+	return err
+}
+
+// resolver returns the native ARP resolver, opening it on first use, or
+// nil when no ARP socket is available.
+func (s *Scanner) resolver() *resolver {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.native != nil || s.nativeErr != nil {
+		return s.native
+	}
+	r, err := openResolver(s.iface)
+	if err != nil {
+		s.nativeErr = err
+		fmt.Printf("\nℹ️  Native ARP unavailable (%v), falling back to arping\n", err)
+		return nil
+	}
+	s.native = r
+	return r
+}
+
 // Scan performs an ARP scan on the target IP by sending a single ARP request.
 // ARP scanning only works on the local network segment.
 func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
 	start := time.Now()
 
-	// Use arping to send ARP request to individual host
-	macAddr, err := s.arpPing(ctx, ip)
+	// ARP only exists for IPv4; IPv6 neighbors are found via NDP.
+	if network.IsIPv6(ip) {
+		return scanner.Result{
+			IP:      ip,
+			Alive:   false,
+			Method:  s.Name(),
+			Details: "ARP not available for IPv6",
+		}
+	}
+
+	macAddr, err := s.lookup(ctx, ip)
 	duration := time.Since(start)
 
 	if err == nil && macAddr != "" {
@@ -49,6 +99,7 @@ func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
 			Method:   s.Name(),
 			Details:  fmt.Sprintf("MAC: %s", macAddr),
 			Duration: duration,
+			MAC:      macAddr,
 		}
 	}
 
@@ -61,6 +112,19 @@ func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
 	}
 }
 
+// lookup sends a single ARP request for ip, from the native socket when
+// one is available and with arping otherwise.
+func (s *Scanner) lookup(ctx context.Context, ip string) (string, error) {
+	if r := s.resolver(); r != nil {
+		mac, err := r.resolve(net.ParseIP(ip), s.timeout, ctx.Done())
+		if err != nil {
+			return "", err
+		}
+		return strings.ToUpper(mac.String()), nil
+	}
+	return s.arpPing(ctx, ip)
+}
+
 // permissionWarningShown tracks if we've already shown the permission warning
 var permissionWarningShown bool
 
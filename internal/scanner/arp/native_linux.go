@@ -0,0 +1,186 @@
+package arp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"maki/internal/packet"
+)
+
+// ethPARP is the ARP EtherType in network byte order, as AF_PACKET
+// expects it.
+var ethPARP = htons(syscall.ETH_P_ARP)
+
+// htons converts v from host to network byte order.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
+
+// resolver sends ARP requests over one AF_PACKET socket bound to the
+// interface and hands each reply to the goroutine waiting for its sender.
+// The socket is SOCK_DGRAM, so the kernel adds and strips the Ethernet
+// header. It requires root or CAP_NET_RAW.
+type resolver struct {
+	f       *os.File
+	raw     syscall.RawConn
+	ifindex int
+	mac     net.HardwareAddr
+	addrs   []*net.IPNet // the interface's IPv4 networks
+
+	mu      sync.Mutex
+	waiters map[string]chan net.HardwareAddr
+}
+
+// openResolver opens an ARP socket on the named interface.
+func openResolver(iface string) (*resolver, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %v", iface, err)
+	}
+	if len(ifi.HardwareAddr) != 6 {
+		return nil, fmt.Errorf("interface %s has no Ethernet address", iface)
+	}
+
+	r := &resolver{
+		ifindex: ifi.Index,
+		mac:     ifi.HardwareAddr,
+		waiters: make(map[string]chan net.HardwareAddr),
+	}
+	if addrs, err := ifi.Addrs(); err == nil {
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+				r.addrs = append(r.addrs, n)
+			}
+		}
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, int(ethPARP))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: ethPARP, Ifindex: ifi.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	r.f = os.NewFile(uintptr(fd), "arp")
+	if r.raw, err = r.f.SyscallConn(); err != nil {
+		r.f.Close()
+		return nil, err
+	}
+	go r.run()
+	return r, nil
+}
+
+// Close closes the socket, which also stops the reader.
+func (r *resolver) Close() error {
+	return r.f.Close()
+}
+
+// resolve broadcasts an ARP request for ip and waits up to timeout for the
+// reply, returning the MAC address it carries.
+func (r *resolver) resolve(ip net.IP, timeout time.Duration, done <-chan struct{}) (net.HardwareAddr, error) {
+	key := ip.String()
+	ch := make(chan net.HardwareAddr, 1)
+	r.mu.Lock()
+	r.waiters[key] = ch
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.waiters, key)
+		r.mu.Unlock()
+	}()
+
+	req := &packet.ARP{
+		Op:        packet.ARPRequest,
+		SenderMAC: r.mac,
+		SenderIP:  r.source(ip),
+		TargetIP:  ip,
+	}
+	if err := r.send(req.Marshal()); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case mac := <-ch:
+		return mac, nil
+	case <-timer.C:
+		return nil, errNoReply
+	case <-done:
+		return nil, errNoReply
+	}
+}
+
+// errNoReply is returned when the target did not answer in time.
+var errNoReply = errors.New("no ARP reply")
+
+// source returns the interface address to send from: one on the target's
+// subnet, else the first IPv4 address, else 0.0.0.0 as in an RFC 5227
+// probe, which hosts answer all the same.
+func (r *resolver) source(dst net.IP) net.IP {
+	for _, n := range r.addrs {
+		if n.Contains(dst) {
+			return n.IP
+		}
+	}
+	if len(r.addrs) > 0 {
+		return r.addrs[0].IP
+	}
+	return net.IPv4zero
+}
+
+// send broadcasts msg on the interface.
+func (r *resolver) send(msg []byte) error {
+	to := &syscall.SockaddrLinklayer{
+		Protocol: ethPARP,
+		Ifindex:  r.ifindex,
+		Halen:    6,
+		Addr:     [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	var sendErr error
+	err := r.raw.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendto(int(fd), msg, 0, to)
+		return sendErr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// run reads ARP messages until the socket is closed and hands replies to
+// the probe waiting for their sender.
+func (r *resolver) run() {
+	buf := make([]byte, 1500)
+	for {
+		n, err := r.f.Read(buf)
+		if err != nil {
+			return
+		}
+		msg, err := packet.ParseARP(buf[:n])
+		if err != nil || msg.Op != packet.ARPReply {
+			continue
+		}
+
+		r.mu.Lock()
+		ch, ok := r.waiters[msg.SenderIP.String()]
+		r.mu.Unlock()
+		if ok {
+			select {
+			case ch <- msg.SenderMAC:
+			default:
+			}
+		}
+	}
+}
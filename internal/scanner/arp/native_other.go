@@ -0,0 +1,26 @@
+//go:build !linux
+
+package arp
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"time"
+)
+
+// resolver is not available on this platform: it needs Linux AF_PACKET
+// sockets.
+type resolver struct{}
+
+func openResolver(iface string) (*resolver, error) {
+	return nil, errors.New("native ARP is not supported on " + runtime.GOOS)
+}
+
+func (r *resolver) Close() error {
+	return nil
+}
+
+func (r *resolver) resolve(ip net.IP, timeout time.Duration, done <-chan struct{}) (net.HardwareAddr, error) {
+	return nil, errors.New("native ARP is not supported on " + runtime.GOOS)
+}
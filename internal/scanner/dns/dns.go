@@ -0,0 +1,87 @@
+// Package dns implements reverse DNS (PTR) lookups for scan targets.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// Scanner implements reverse DNS scanning using PTR lookups.
+type Scanner struct {
+	timeout  time.Duration
+	resolver *net.Resolver
+}
+
+// New creates a new reverse DNS scanner. When server is empty the system
+// resolver is used; otherwise queries go to server, given as "host" or
+// "host:port" (port 53 by default).
+func New(timeout time.Duration, server string) *Scanner {
+	s := &Scanner{
+		timeout:  timeout,
+		resolver: net.DefaultResolver,
+	}
+
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		s.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: timeout}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return s
+}
+
+// Name returns the scanner name.
+func (s *Scanner) Name() string {
+	return "Reverse DNS"
+}
+
+// Scan looks up the PTR records of the target IP. A result is reported
+// alive when the address has a PTR record; this says nothing about
+// whether the host itself is up.
+func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	names, err := s.resolver.LookupAddr(ctx, ip)
+	duration := time.Since(start)
+
+	if err == nil && len(names) > 0 {
+		for i, name := range names {
+			names[i] = strings.TrimSuffix(name, ".")
+		}
+		return scanner.Result{
+			IP:       ip,
+			Alive:    true,
+			Method:   s.Name(),
+			Duration: duration,
+			Hostname: strings.Join(names, ", "),
+		}
+	}
+
+	details := "No PTR record"
+	if dnsErr, ok := err.(*net.DNSError); ok && !dnsErr.IsNotFound {
+		details = fmt.Sprintf("Lookup failed: %s", dnsErr.Err)
+	}
+
+	return scanner.Result{
+		IP:       ip,
+		Alive:    false,
+		Method:   s.Name(),
+		Details:  details,
+		Duration: duration,
+	}
+}
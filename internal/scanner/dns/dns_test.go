@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubServer answers PTR queries over UDP on a local port from records,
+// keyed by the queried name without the trailing dot. Unknown names get
+// NXDOMAIN, and names mapped to "" an empty NOERROR answer.
+func stubServer(t *testing.T, records map[string]string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := answer(buf[:n], records); resp != nil {
+				_, _ = conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// answer builds the response to query.
+func answer(query []byte, records map[string]string) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	var labels []string
+	off := 12
+	for off < len(query) && query[off] != 0 {
+		n := int(query[off])
+		if off+1+n > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[off+1:off+1+n]))
+		off += 1 + n
+	}
+	end := off + 5 // root label, type, class
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[off+1:])
+
+	resp := append([]byte(nil), query[:end]...)
+	resp[2] = 0x84 | query[2]&0x01 // response, authoritative, RD copied
+	resp[3] = 0x80                 // recursion available
+	binary.BigEndian.PutUint16(resp[6:], 0)
+	binary.BigEndian.PutUint16(resp[8:], 0)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+
+	target, ok := records[strings.Join(labels, ".")]
+	switch {
+	case !ok:
+		resp[3] |= 3 // NXDOMAIN
+	case target != "" && qtype == 12:
+		var rdata []byte
+		for _, l := range strings.Split(target, ".") {
+			rdata = append(rdata, byte(len(l)))
+			rdata = append(rdata, l...)
+		}
+		rdata = append(rdata, 0)
+
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		resp = append(resp, 0xc0, 12) // name: pointer to the question
+		resp = binary.BigEndian.AppendUint16(resp, 12)
+		resp = binary.BigEndian.AppendUint16(resp, 1)
+		resp = binary.BigEndian.AppendUint32(resp, 300)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+		resp = append(resp, rdata...)
+	}
+	return resp
+}
+
+func TestScanThroughServer(t *testing.T) {
+	server := stubServer(t, map[string]string{
+		"5.1.168.192.in-addr.arpa": "gw.example.lan",
+		"6.1.168.192.in-addr.arpa": "",
+	})
+	s := New(2*time.Second, server)
+
+	tests := []struct {
+		ip       string
+		alive    bool
+		hostname string
+		details  string
+	}{
+		{"192.168.1.5", true, "gw.example.lan", ""},
+		{"192.168.1.6", false, "", "No PTR record"},
+		{"192.168.1.7", false, "", "No PTR record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			r := s.Scan(context.Background(), tt.ip)
+			if r.Alive != tt.alive || r.Hostname != tt.hostname || r.Details != tt.details {
+				t.Errorf("Scan(%s) = alive %v, hostname %q, details %q; want %v, %q, %q",
+					tt.ip, r.Alive, r.Hostname, r.Details, tt.alive, tt.hostname, tt.details)
+			}
+		})
+	}
+}
+
+func TestScanUnreachableServer(t *testing.T) {
+	// A bound but silent UDP port: queries time out.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := New(300*time.Millisecond, conn.LocalAddr().String())
+	r := s.Scan(context.Background(), "192.168.1.5")
+	if r.Alive || !strings.HasPrefix(r.Details, "Lookup failed") {
+		t.Errorf("Scan() = alive %v, details %q; want a failed lookup", r.Alive, r.Details)
+	}
+}
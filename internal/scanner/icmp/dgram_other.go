@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package icmp
+
+import (
+	"errors"
+	"net"
+	"runtime"
+
+	"maki/internal/network"
+)
+
+// listenDatagram is not available on this platform.
+func listenDatagram(v6 bool, laddr net.IP, src network.Source) (net.PacketConn, error) {
+	return nil, errors.New("unprivileged ICMP sockets are not supported on " + runtime.GOOS)
+}
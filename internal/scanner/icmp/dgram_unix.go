@@ -0,0 +1,49 @@
+//go:build linux || darwin
+
+package icmp
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"maki/internal/network"
+)
+
+// listenDatagram opens an unprivileged ICMP datagram socket
+// (SOCK_DGRAM/IPPROTO_ICMP). On Linux this is permitted for groups in
+// net.ipv4.ping_group_range. The socket is bound to laddr and to src's
+// interface, if any.
+func listenDatagram(v6 bool, laddr net.IP, src network.Source) (net.PacketConn, error) {
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	sa4 := &syscall.SockaddrInet4{}
+	copy(sa4.Addr[:], laddr.To4())
+	var sa syscall.Sockaddr = sa4
+	if v6 {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+		sa6 := &syscall.SockaddrInet6{}
+		copy(sa6.Addr[:], laddr.To16())
+		sa = sa6
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+	conn, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := src.Bind(conn.(syscall.Conn)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -1,25 +1,52 @@
 // Package icmp implements ICMP echo (ping) based host discovery.
+//
+// Echo requests are sent natively over a shared raw or unprivileged ICMP
+// socket. When neither socket type can be opened, the scanner falls back
+// to running the system ping command once per host. Over a raw socket,
+// IPv4 timestamp, information and address mask requests can be sent as
+// well, alongside or instead of echo.
 package icmp
 
 import (
 	"context"
 	"fmt"
+	"math"
+	"net"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"maki/internal/network"
+	"maki/internal/packet"
 	"maki/internal/scanner"
 )
 
-// Scanner implements ICMP ping scanning using the system ping command.
+// Scanner implements ICMP ping scanning.
 type Scanner struct {
-	timeout time.Duration
+	timeout   time.Duration
+	sweepRate int
+	probes    []Probe
+	source    network.Source
+
+	monitorCount    int
+	monitorInterval time.Duration
+
+	mu        sync.Mutex
+	pingers   map[bool]*pinger // keyed by "is IPv6"
+	nativeErr map[bool]error
 }
 
 // New creates a new ICMP scanner with the given timeout.
 func New(timeout time.Duration) *Scanner {
 	return &Scanner{
-		timeout: timeout,
+		timeout:   timeout,
+		probes:    []Probe{ProbeEcho},
+		pingers:   make(map[bool]*pinger),
+		nativeErr: make(map[bool]error),
 	}
 }
 
@@ -28,21 +55,202 @@ func (s *Scanner) Name() string {
 	return "ICMP Ping"
 }
 
-// Scan performs an ICMP ping scan on the target IP.
+// SetProbes selects the ICMP probes sent to each host. They are sent
+// together and the first reply marks the host alive.
+func (s *Scanner) SetProbes(probes []Probe) {
+	if len(probes) > 0 {
+		s.probes = probes
+	}
+}
+
+// SetSource sends probes from a local address or interface. The system
+// ping command, used when no ICMP socket can be opened, is bound to the
+// interface, or else to the address.
+func (s *Scanner) SetSource(src network.Source) {
+	s.source = src
+}
+
+// echoOnly reports whether echo is the only probe selected.
+func (s *Scanner) echoOnly() bool {
+	return len(s.probes) == 1 && s.probes[0] == ProbeEcho
+}
+
+// hasProbe reports whether probe is selected.
+func (s *Scanner) hasProbe(probe Probe) bool {
+	for _, p := range s.probes {
+		if p == probe {
+			return true
+		}
+	}
+	return false
+}
+
+// Close releases the ICMP sockets opened by the scanner.
+func (s *Scanner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for v6, p := range s.pingers {
+		p.Close()
+		delete(s.pingers, v6)
+	}
+	return nil
+}
+
+// Scan performs an ICMP ping scan on the target IP. In monitoring mode
+// the target is probed repeatedly and its latency statistics recorded.
 func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
+	if s.monitorCount > 0 {
+		return s.monitor(ctx, ip)
+	}
+	return s.ping(ctx, ip)
+}
+
+// ping sends a single echo request to the target.
+func (s *Scanner) ping(ctx context.Context, ip string) scanner.Result {
+	dst := net.ParseIP(ip)
+	if dst != nil {
+		if p := s.pinger(dst.To4() == nil); p != nil {
+			return s.scanNative(ctx, p, ip, dst)
+		}
+	}
+	return s.scanExec(ctx, ip)
+}
+
+// pinger returns the native pinger for the address family, opening it on
+// first use, or nil when no ICMP socket is available.
+func (s *Scanner) pinger(v6 bool) *pinger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.pingers[v6]; ok {
+		return p
+	}
+	if _, failed := s.nativeErr[v6]; failed {
+		return nil
+	}
+
+	p, err := openPinger(v6, s.source)
+	if err != nil {
+		s.nativeErr[v6] = err
+		fmt.Printf("\nℹ️  Native ICMP unavailable (%v), falling back to the system ping command\n", err)
+		return nil
+	}
+	s.pingers[v6] = p
+	return p
+}
+
+// scanNative sends the selected probes over the shared ICMP socket and
+// reports the first one answered.
+func (s *Scanner) scanNative(ctx context.Context, p *pinger, ip string, dst net.IP) scanner.Result {
+	start := time.Now()
+
+	var probes []Probe
+	for _, probe := range s.probes {
+		if p.supports(probe) {
+			probes = append(probes, probe)
+		}
+	}
+	if len(probes) == 0 {
+		return scanner.Result{
+			IP:       ip,
+			Alive:    false,
+			Method:   s.Name(),
+			Details:  unsupportedDetails(p.v6),
+			Duration: time.Since(start),
+		}
+	}
+
+	type answer struct {
+		probe Probe
+		reply echoReply
+		rtt   time.Duration
+		ok    bool
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	answers := make(chan answer, len(probes))
+	for _, probe := range probes {
+		go func(probe Probe) {
+			r, rtt, ok := p.ping(dst, probe, s.timeout, ctx.Done())
+			answers <- answer{probe, r, rtt, ok}
+		}(probe)
+	}
+
+	for range probes {
+		a := <-answers
+		if !a.ok {
+			continue
+		}
+		return scanner.Result{
+			IP:       ip,
+			Alive:    true,
+			Method:   s.Name(),
+			Details:  probeDetails(a.probe, a.reply, a.rtt),
+			Duration: time.Since(start),
+			RTT:      a.rtt,
+			TTL:      a.reply.ttl,
+			Probe:    string(a.probe),
+		}
+	}
+
+	return scanner.Result{
+		IP:       ip,
+		Alive:    false,
+		Method:   s.Name(),
+		Details:  "No response",
+		Duration: time.Since(start),
+	}
+}
+
+// unsupportedDetails explains why none of the selected probes could be
+// sent to a host.
+func unsupportedDetails(v6 bool) string {
+	if v6 {
+		return "Only echo probes exist for IPv6"
+	}
+	return "Timestamp, mask and info probes require a raw socket (root/CAP_NET_RAW)"
+}
+
+// scanExec pings the target by running the system ping command, which
+// can only send echo requests.
+func (s *Scanner) scanExec(ctx context.Context, ip string) scanner.Result {
 	start := time.Now()
 
+	if !s.hasProbe(ProbeEcho) {
+		return scanner.Result{
+			IP:       ip,
+			Alive:    false,
+			Method:   s.Name(),
+			Details:  unsupportedDetails(network.IsIPv6(ip)),
+			Duration: time.Since(start),
+		}
+	}
+
+	// Bound the whole ping process, not just the reply wait, so that
+	// variants without a wait flag cannot hang a worker.
+	ctx, cancel := context.WithTimeout(ctx, s.timeout+time.Second)
+	defer cancel()
+
 	cmd := s.buildPingCommand(ctx, ip)
-	err := cmd.Run()
+	out, err := cmd.Output()
 	duration := time.Since(start)
 
 	if err == nil {
+		// Prefer the RTT ping measured over our wall-clock time, which
+		// includes process startup.
+		rtt, ttl := parsePingOutput(string(out))
+		if rtt == 0 {
+			rtt = duration
+		}
 		return scanner.Result{
 			IP:       ip,
 			Alive:    true,
 			Method:   s.Name(),
-			Details:  fmt.Sprintf("Response in %v", duration.Round(time.Millisecond)),
+			Details:  replyDetails(rtt, ttl),
 			Duration: duration,
+			RTT:      rtt,
+			TTL:      ttl,
+			Probe:    string(ProbeEcho),
 		}
 	}
 
@@ -55,14 +263,112 @@ func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
 	}
 }
 
-// buildPingCommand creates the appropriate ping command for the current OS.
+// pingTTLPattern and pingTimePattern match the reply line of the common
+// ping implementations ("ttl=64 time=0.43 ms", "TTL=128 time<1ms").
+var (
+	pingTTLPattern  = regexp.MustCompile(`(?i)\b(?:ttl|hlim)=(\d+)`)
+	pingTimePattern = regexp.MustCompile(`(?i)\btime[=<]([\d.]+) ?ms`)
+)
+
+// parsePingOutput extracts the RTT and reply TTL from ping's output,
+// returning zeros for values it cannot find.
+func parsePingOutput(out string) (time.Duration, int) {
+	var (
+		rtt time.Duration
+		ttl int
+	)
+	if m := pingTTLPattern.FindStringSubmatch(out); m != nil {
+		ttl, _ = strconv.Atoi(m[1])
+	}
+	if m := pingTimePattern.FindStringSubmatch(out); m != nil {
+		if ms, err := strconv.ParseFloat(m[1], 64); err == nil {
+			rtt = time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	return rtt, ttl
+}
+
+// replyDetails formats the RTT and, when known, the reply TTL with the
+// inferred OS family and hop distance, e.g.
+// "Response in 1.2ms, TTL 63 (Linux/Unix, 1 hop)".
+func replyDetails(rtt time.Duration, ttl int) string {
+	details := fmt.Sprintf("Response in %v", rtt.Round(time.Microsecond))
+	if ttl <= 0 {
+		return details
+	}
+
+	initial, hops := network.InferInitialTTL(ttl)
+	suffix := "s"
+	if hops == 1 {
+		suffix = ""
+	}
+	return fmt.Sprintf("%s, TTL %d (%s, %d hop%s)", details, ttl, network.OSFamilyFromTTL(initial), hops, suffix)
+}
+
+// probeDetails formats a reply like replyDetails, naming the probe when it
+// was not an echo and adding the mask an address mask reply carries, e.g.
+// "Address mask reply in 1.2ms, TTL 64 (Linux/Unix, 0 hops), mask 255.255.255.0".
+func probeDetails(probe Probe, r echoReply, rtt time.Duration) string {
+	details := replyDetails(rtt, r.ttl)
+	if probe == ProbeEcho {
+		return details
+	}
+	details = probe.label() + strings.TrimPrefix(details, "Response")
+	if probe == ProbeMask {
+		if mask, err := packet.ParseAddressMask(r.data); err == nil {
+			details += fmt.Sprintf(", mask %s", net.IP(mask))
+		}
+	}
+	return details
+}
+
+// buildPingCommand creates the appropriate ping command for the current OS
+// and address family.
 func (s *Scanner) buildPingCommand(ctx context.Context, ip string) *exec.Cmd {
+	v6 := network.IsIPv6(ip)
+
+	// ping's -W takes whole seconds on Linux, and 0 means "wait forever"
+	// on some versions, so round sub-second timeouts up.
+	waitSecs := int(math.Ceil(s.timeout.Seconds()))
+	if waitSecs < 1 {
+		waitSecs = 1
+	}
+
+	src := s.source.LocalIP(net.ParseIP(ip))
+	var args []string
 	switch runtime.GOOS {
 	case "windows":
-		return exec.CommandContext(ctx, "ping", "-n", "1", "-w", fmt.Sprintf("%d", s.timeout.Milliseconds()), ip)
+		if v6 {
+			args = append(args, "-6")
+		}
+		args = append(args, "-n", "1", "-w", fmt.Sprintf("%d", s.timeout.Milliseconds()))
+		if src != nil {
+			args = append(args, "-S", src.String())
+		}
+		return exec.CommandContext(ctx, "ping", append(args, ip)...)
 	case "darwin":
-		return exec.CommandContext(ctx, "ping", "-c", "1", "-W", fmt.Sprintf("%d", int(s.timeout.Seconds())*1000), ip)
+		name := "ping"
+		if v6 {
+			// ping6 has no per-reply wait flag; the caller's context bounds it.
+			name = "ping6"
+			args = append(args, "-c", "1")
+		} else {
+			args = append(args, "-c", "1", "-W", fmt.Sprintf("%d", s.timeout.Milliseconds()))
+		}
+		if src != nil {
+			args = append(args, "-S", src.String())
+		}
+		return exec.CommandContext(ctx, name, append(args, ip)...)
 	default: // Linux
-		return exec.CommandContext(ctx, "ping", "-c", "1", "-W", fmt.Sprintf("%d", int(s.timeout.Seconds())), ip)
+		if v6 {
+			args = append(args, "-6")
+		}
+		args = append(args, "-c", "1", "-W", fmt.Sprintf("%d", waitSecs))
+		if s.source.Interface != "" {
+			args = append(args, "-I", s.source.Interface)
+		} else if src != nil {
+			args = append(args, "-I", src.String())
+		}
+		return exec.CommandContext(ctx, "ping", append(args, ip)...)
 	}
 }
@@ -0,0 +1,146 @@
+package icmp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+const (
+	// DefaultMonitorCount is the default number of probes per host in
+	// monitoring mode.
+	DefaultMonitorCount = 10
+
+	// DefaultMonitorInterval is the default delay between probes to the
+	// same host in monitoring mode.
+	DefaultMonitorInterval = time.Second
+)
+
+// SetMonitor enables monitoring mode, in which each host is sent count
+// echo requests, interval apart, and reported with its min/avg/max RTT,
+// jitter and packet loss. A count of 0 disables monitoring mode.
+func (s *Scanner) SetMonitor(count int, interval time.Duration) {
+	if count < 0 {
+		count = 0
+	}
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+	s.monitorCount = count
+	s.monitorInterval = interval
+}
+
+// monitor probes the target monitorCount times. Probes are sent on
+// schedule without waiting for earlier replies, so a host that stops
+// answering costs one timeout rather than one per probe.
+func (s *Scanner) monitor(ctx context.Context, ip string) scanner.Result {
+	start := time.Now()
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		rtts  = make([]time.Duration, s.monitorCount)
+		ttl   int
+		sent  int
+		timer = time.NewTimer(0)
+	)
+	defer timer.Stop()
+
+probes:
+	for i := 0; i < s.monitorCount; i++ {
+		if i > 0 {
+			timer.Reset(s.monitorInterval)
+		}
+		select {
+		case <-ctx.Done():
+			break probes
+		case <-timer.C:
+		}
+
+		sent++
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := s.ping(ctx, ip)
+			if !r.Alive {
+				return
+			}
+			mu.Lock()
+			rtts[i] = r.RTT
+			if r.TTL > 0 {
+				ttl = r.TTL
+			}
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	stats := latencyStats(rtts[:sent])
+	result := scanner.Result{
+		IP:       ip,
+		Alive:    stats.Received > 0,
+		Method:   s.Name(),
+		Duration: time.Since(start),
+		TTL:      ttl,
+		Latency:  stats,
+	}
+	if !result.Alive {
+		result.Details = "No response"
+		return result
+	}
+	result.RTT = stats.Avg
+	result.Details = latencyDetails(stats)
+	return result
+}
+
+// latencyStats summarizes the round-trip times of a series of probes in
+// the order they were sent, with 0 marking a probe that got no reply.
+// Jitter is the mean difference between consecutive replies.
+func latencyStats(rtts []time.Duration) *scanner.Latency {
+	stats := &scanner.Latency{Sent: len(rtts)}
+
+	var (
+		total, deltas time.Duration
+		prev          time.Duration
+	)
+	for _, rtt := range rtts {
+		if rtt <= 0 {
+			continue
+		}
+		if stats.Received == 0 || rtt < stats.Min {
+			stats.Min = rtt
+		}
+		if rtt > stats.Max {
+			stats.Max = rtt
+		}
+		if stats.Received > 0 {
+			d := rtt - prev
+			if d < 0 {
+				d = -d
+			}
+			deltas += d
+		}
+		prev = rtt
+		total += rtt
+		stats.Received++
+	}
+
+	if stats.Received > 0 {
+		stats.Avg = total / time.Duration(stats.Received)
+	}
+	if stats.Received > 1 {
+		stats.Jitter = deltas / time.Duration(stats.Received-1)
+	}
+	return stats
+}
+
+// latencyDetails formats latency statistics, e.g.
+// "avg 1.2ms, min 0.9ms, max 2.1ms, jitter 0.3ms, loss 10% (9/10)".
+func latencyDetails(l *scanner.Latency) string {
+	return fmt.Sprintf("avg %v, min %v, max %v, jitter %v, loss %.0f%% (%d/%d)",
+		l.Avg.Round(time.Microsecond), l.Min.Round(time.Microsecond), l.Max.Round(time.Microsecond),
+		l.Jitter.Round(time.Microsecond), l.Loss(), l.Received, l.Sent)
+}
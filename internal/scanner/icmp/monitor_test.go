@@ -0,0 +1,44 @@
+package icmp
+
+import (
+	"testing"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+func TestLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name string
+		rtts []time.Duration
+		want scanner.Latency
+		loss float64
+	}{
+		{"none sent", nil, scanner.Latency{}, 0},
+		{"all lost", []time.Duration{0, 0, 0, 0}, scanner.Latency{Sent: 4}, 100},
+		{"single reply", []time.Duration{0, 3 * ms, 0, 0}, scanner.Latency{Sent: 4, Received: 1, Min: 3 * ms, Avg: 3 * ms, Max: 3 * ms}, 75},
+		{"steady", []time.Duration{2 * ms, 2 * ms, 2 * ms}, scanner.Latency{Sent: 3, Received: 3, Min: 2 * ms, Avg: 2 * ms, Max: 2 * ms}, 0},
+		// Deltas 4, 3, 5: jitter 4ms.
+		{"varying", []time.Duration{1 * ms, 5 * ms, 2 * ms, 7 * ms}, scanner.Latency{Sent: 4, Received: 4, Min: 1 * ms, Avg: 3750 * time.Microsecond, Max: 7 * ms, Jitter: 4 * ms}, 0},
+		// Jitter compares consecutive replies, skipping lost probes:
+		// deltas 6 and 2.
+		{"with loss", []time.Duration{0, 4 * ms, 0, 10 * ms, 8 * ms, 0, 0, 0, 0, 0}, scanner.Latency{Sent: 10, Received: 3, Min: 4 * ms, Avg: 22 * ms / 3, Max: 10 * ms, Jitter: 4 * ms}, 70},
+	}
+	for _, tt := range tests {
+		got := latencyStats(tt.rtts)
+		if *got != tt.want {
+			t.Errorf("%s: latencyStats() = %+v, want %+v", tt.name, *got, tt.want)
+		}
+		if loss := got.Loss(); loss != tt.loss {
+			t.Errorf("%s: Loss() = %v, want %v", tt.name, loss, tt.loss)
+		}
+	}
+}
+
+func TestLatencyDetails(t *testing.T) {
+	l := &scanner.Latency{Sent: 10, Received: 9, Min: 900 * time.Microsecond, Avg: 1234567 * time.Nanosecond, Max: 2100 * time.Microsecond, Jitter: 300 * time.Microsecond}
+	if got, want := latencyDetails(l), "avg 1.235ms, min 900µs, max 2.1ms, jitter 300µs, loss 10% (9/10)"; got != want {
+		t.Errorf("latencyDetails() = %q, want %q", got, want)
+	}
+}
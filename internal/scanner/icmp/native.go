@@ -0,0 +1,214 @@
+package icmp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/packet"
+)
+
+// pinger sends ICMP echo requests over one shared socket and hands each
+// reply to the goroutine waiting for its sequence number and source.
+//
+// It prefers a raw socket (root/CAP_NET_RAW) and falls back to an
+// unprivileged ICMP datagram socket where the OS allows it (Linux with a
+// suitable net.ipv4.ping_group_range, macOS). With datagram sockets the
+// kernel owns the echo identifier and only delivers our own replies.
+type pinger struct {
+	conn  net.PacketConn
+	v6    bool
+	dgram bool
+	id    uint16
+
+	mu      sync.Mutex
+	seq     uint16
+	waiters map[uint16]waiter
+
+	// sweep, when set, receives every echo reply instead of the waiters.
+	sweep func(seq uint16, r echoReply)
+}
+
+// waiter is a probe awaiting its reply. Sequence numbers wrap and raw
+// sockets also see other processes' pings, so a reply only counts if it
+// comes from the probed address.
+type waiter struct {
+	dst net.IP
+	ch  chan echoReply
+}
+
+// echoReply is a matched echo (or other query) reply.
+type echoReply struct {
+	from net.IP
+	at   time.Time
+	ttl  int // 0 when unknown
+	typ  uint8
+	data []byte
+}
+
+// openPinger opens an ICMP socket for the address family, bound to src's
+// address and interface where they are set.
+func openPinger(v6 bool, src network.Source) (*pinger, error) {
+	netw, laddr := "ip4:icmp", net.IPv4zero
+	if v6 {
+		netw, laddr = "ip6:ipv6-icmp", net.IPv6unspecified
+	}
+	if ip := src.LocalIP(laddr); ip != nil {
+		laddr = ip
+	}
+
+	p := &pinger{
+		v6:      v6,
+		id:      uint16(os.Getpid() & 0xffff),
+		waiters: make(map[uint16]waiter),
+	}
+
+	lc := net.ListenConfig{Control: src.Control}
+	conn, rawErr := lc.ListenPacket(context.Background(), netw, laddr.String())
+	if rawErr != nil {
+		var dgramErr error
+		conn, dgramErr = listenDatagram(v6, laddr, src)
+		if dgramErr != nil {
+			return nil, fmt.Errorf("raw socket: %v; datagram socket: %v", rawErr, dgramErr)
+		}
+		p.dgram = true
+	}
+	p.conn = conn
+
+	// Reply TTLs are a nice-to-have; pinging works without them.
+	_ = enableTTL(conn, v6)
+
+	go p.run()
+	return p, nil
+}
+
+// Close closes the socket, which also stops the reader.
+func (p *pinger) Close() error {
+	return p.conn.Close()
+}
+
+// ping sends one probe to dst and waits up to timeout for the reply,
+// returning it with the round-trip time.
+func (p *pinger) ping(dst net.IP, probe Probe, timeout time.Duration, done <-chan struct{}) (echoReply, time.Duration, bool) {
+	p.mu.Lock()
+	p.seq++
+	seq := p.seq
+	ch := make(chan echoReply, 1)
+	p.waiters[seq] = waiter{dst: dst, ch: ch}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiters, seq)
+		p.mu.Unlock()
+	}()
+
+	start := time.Now()
+	if err := p.send(dst, probe, seq); err != nil {
+		return echoReply{}, 0, false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-ch:
+		return r, r.at.Sub(start), true
+	case <-timer.C:
+		return echoReply{}, 0, false
+	case <-done:
+		return echoReply{}, 0, false
+	}
+}
+
+// supports reports whether the probe can be sent over this socket: the
+// non-echo queries exist only for IPv4, and the kernel only lets echo
+// requests out of datagram ICMP sockets.
+func (p *pinger) supports(probe Probe) bool {
+	return probe == ProbeEcho || (!p.v6 && !p.dgram)
+}
+
+// send writes one probe with the given sequence number to dst.
+func (p *pinger) send(dst net.IP, probe Probe, seq uint16) error {
+	msg := probe.message(p.v6, p.id, seq).Marshal()
+
+	var addr net.Addr = &net.IPAddr{IP: dst}
+	if p.dgram {
+		addr = &net.UDPAddr{IP: dst}
+	}
+
+	_, err := p.conn.WriteTo(msg, addr)
+	return err
+}
+
+// setSweep installs (or, with nil, removes) the sweep reply handler.
+func (p *pinger) setSweep(fn func(seq uint16, r echoReply)) {
+	p.mu.Lock()
+	p.sweep = fn
+	p.mu.Unlock()
+}
+
+// run reads replies until the socket is closed.
+func (p *pinger) run() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, ttl, err := readWithTTL(p.conn, buf)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+
+		msg := buf[:n]
+		// Raw IPv4 reads via ReadMsgIP and macOS datagram sockets deliver
+		// replies with the IP header, which also carries the TTL. An ICMP
+		// header never starts with 0x4_, so the version nibble tells them
+		// apart.
+		if !p.v6 && len(msg) >= 20 && msg[0]>>4 == 4 {
+			if ttl == 0 {
+				ttl = int(msg[8])
+			}
+			msg = msg[int(msg[0]&0x0f)*4:]
+		}
+
+		echo, err := packet.ParseICMPEcho(msg)
+		if err != nil {
+			continue
+		}
+		if !isReply(p.v6, echo.Type) || (!p.dgram && echo.ID != p.id) {
+			continue
+		}
+
+		r := echoReply{from: addrIP(from), at: now, ttl: ttl, typ: echo.Type, data: append([]byte(nil), echo.Data...)}
+
+		p.mu.Lock()
+		sweep := p.sweep
+		w, ok := p.waiters[echo.Seq]
+		p.mu.Unlock()
+
+		if sweep != nil {
+			sweep(echo.Seq, r)
+			continue
+		}
+		if ok && w.dst.Equal(r.from) {
+			select {
+			case w.ch <- r:
+			default:
+			}
+		}
+	}
+}
+
+// addrIP extracts the IP from a raw or datagram socket address.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
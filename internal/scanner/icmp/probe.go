@@ -0,0 +1,85 @@
+package icmp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"maki/internal/packet"
+)
+
+// Probe is an ICMP query message used for discovery. Some firewalls drop
+// echo requests but let the other query types through.
+type Probe string
+
+const (
+	ProbeEcho      Probe = "echo"
+	ProbeTimestamp Probe = "timestamp"
+	ProbeMask      Probe = "mask"
+	ProbeInfo      Probe = "info"
+)
+
+// ParseProbes parses a comma-separated list of probe names. An empty
+// spec selects the echo probe.
+func ParseProbes(spec string) ([]Probe, error) {
+	var probes []Probe
+	seen := make(map[Probe]bool)
+	for _, name := range strings.Split(spec, ",") {
+		probe := Probe(strings.ToLower(strings.TrimSpace(name)))
+		if probe == "" || seen[probe] {
+			continue
+		}
+		switch probe {
+		case ProbeEcho, ProbeTimestamp, ProbeMask, ProbeInfo:
+		default:
+			return nil, fmt.Errorf("unknown ICMP probe %q (want echo, timestamp, mask or info)", name)
+		}
+		seen[probe] = true
+		probes = append(probes, probe)
+	}
+	if len(probes) == 0 {
+		probes = []Probe{ProbeEcho}
+	}
+	return probes, nil
+}
+
+// label names the probe's reply in result details.
+func (p Probe) label() string {
+	switch p {
+	case ProbeTimestamp:
+		return "Timestamp reply"
+	case ProbeMask:
+		return "Address mask reply"
+	case ProbeInfo:
+		return "Information reply"
+	}
+	return "Response"
+}
+
+// message builds the request for the probe. Only echo exists for ICMPv6.
+func (p Probe) message(v6 bool, id, seq uint16) *packet.ICMPEcho {
+	switch p {
+	case ProbeTimestamp:
+		return &packet.ICMPEcho{Type: packet.ICMPv4TimestampRequest, ID: id, Seq: seq, Data: packet.TimestampData(time.Now())}
+	case ProbeMask:
+		return &packet.ICMPEcho{Type: packet.ICMPv4AddressMaskRequest, ID: id, Seq: seq, Data: packet.AddressMaskData()}
+	case ProbeInfo:
+		return &packet.ICMPEcho{Type: packet.ICMPv4InfoRequest, ID: id, Seq: seq}
+	}
+	if v6 {
+		return &packet.ICMPEcho{Type: packet.ICMPv6EchoRequest, ID: id, Seq: seq, Data: []byte("maki-ping")}
+	}
+	return &packet.ICMPEcho{Type: packet.ICMPv4EchoRequest, ID: id, Seq: seq, Data: []byte("maki-ping")}
+}
+
+// isReply reports whether an ICMP message type answers a query.
+func isReply(v6 bool, typ uint8) bool {
+	if v6 {
+		return typ == packet.ICMPv6EchoReply
+	}
+	switch typ {
+	case packet.ICMPv4EchoReply, packet.ICMPv4TimestampReply, packet.ICMPv4InfoReply, packet.ICMPv4AddressMaskReply:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package icmp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// DefaultSweepRate is the default number of echo requests per second
+// sent in sweep mode.
+const DefaultSweepRate = 1000
+
+// sweepTick is how often the sweep sender wakes up to send its next batch.
+const sweepTick = 5 * time.Millisecond
+
+// SetSweepRate enables sweep mode, in which all targets are pinged from a
+// single sender at up to rate requests per second while a single receiver
+// marks hosts alive as replies arrive. A rate of 0 disables sweep mode.
+func (s *Scanner) SetSweepRate(rate int) {
+	if rate < 0 {
+		rate = 0
+	}
+	s.sweepRate = rate
+}
+
+// ScanAll implements scanner.BatchScanner. It sweeps all targets when
+// sweep mode is enabled and native ICMP sockets are available.
+func (s *Scanner) ScanAll(ctx context.Context, targets []string, progress func(done int)) ([]scanner.Result, bool) {
+	if s.sweepRate == 0 || s.monitorCount > 0 || !s.echoOnly() {
+		return nil, false
+	}
+
+	type target struct {
+		ip  net.IP
+		p   *pinger
+		seq uint16
+	}
+	parsed := make([]target, len(targets))
+	index := make(map[string]int, len(targets))
+	for i, t := range targets {
+		ip := net.ParseIP(t)
+		if ip == nil {
+			return nil, false
+		}
+		p := s.pinger(ip.To4() == nil)
+		if p == nil {
+			return nil, false
+		}
+		parsed[i] = target{ip: ip, p: p, seq: uint16(i)}
+		index[ip.String()] = i
+	}
+
+	var (
+		mu    sync.Mutex
+		sent  = make([]time.Time, len(targets))
+		rtts  = make([]time.Duration, len(targets))
+		ttls  = make([]int, len(targets))
+		alive = make([]bool, len(targets))
+	)
+
+	// Replies are matched by sequence number and confirmed by source
+	// address, since sequence numbers wrap on sweeps over 65536 hosts.
+	handler := func(seq uint16, r echoReply) {
+		i, ok := index[r.from.String()]
+		if !ok || parsed[i].seq != seq {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !alive[i] && !sent[i].IsZero() {
+			alive[i] = true
+			rtts[i] = r.at.Sub(sent[i])
+			ttls[i] = r.ttl
+		}
+	}
+	for _, t := range parsed {
+		t.p.setSweep(handler)
+	}
+	defer func() {
+		for _, t := range parsed {
+			t.p.setSweep(nil)
+		}
+	}()
+
+	start := time.Now()
+	perTick := s.sweepRate * int(sweepTick) / int(time.Second)
+	if perTick < 1 {
+		perTick = 1
+	}
+	interval := sweepTick
+	if s.sweepRate*int(sweepTick) < int(time.Second) {
+		interval = time.Second / time.Duration(s.sweepRate)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+send:
+	for i := 0; i < len(parsed); {
+		for n := 0; n < perTick && i < len(parsed); n++ {
+			mu.Lock()
+			sent[i] = time.Now()
+			mu.Unlock()
+			_ = parsed[i].p.send(parsed[i].ip, ProbeEcho, parsed[i].seq)
+			i++
+		}
+		if progress != nil {
+			progress(i)
+		}
+		select {
+		case <-ctx.Done():
+			break send
+		case <-ticker.C:
+		}
+	}
+
+	// Give the last requests a full timeout to be answered.
+	select {
+	case <-ctx.Done():
+	case <-time.After(s.timeout):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	results := make([]scanner.Result, len(targets))
+	duration := time.Since(start)
+	for i, ip := range targets {
+		if alive[i] {
+			results[i] = scanner.Result{
+				IP:       ip,
+				Alive:    true,
+				Method:   s.Name(),
+				Details:  replyDetails(rtts[i], ttls[i]),
+				Duration: rtts[i],
+				RTT:      rtts[i],
+				TTL:      ttls[i],
+			}
+			continue
+		}
+		results[i] = scanner.Result{
+			IP:       ip,
+			Alive:    false,
+			Method:   s.Name(),
+			Details:  "No response",
+			Duration: duration,
+		}
+	}
+	return results, true
+}
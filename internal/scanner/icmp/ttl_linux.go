@@ -0,0 +1,72 @@
+package icmp
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+// enableTTL asks the kernel to report the TTL (hop limit) of each
+// received packet as ancillary data.
+func enableTTL(conn net.PacketConn, v6 bool) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		if v6 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVHOPLIMIT, 1)
+		} else {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// readWithTTL reads one packet and returns the TTL it arrived with, or 0
+// when the kernel did not report it.
+func readWithTTL(conn net.PacketConn, buf []byte) (int, net.Addr, int, error) {
+	oob := make([]byte, 64)
+
+	var (
+		n, oobn int
+		from    net.Addr
+		err     error
+	)
+	switch c := conn.(type) {
+	case *net.IPConn:
+		var addr *net.IPAddr
+		n, oobn, _, addr, err = c.ReadMsgIP(buf, oob)
+		from = addr
+	case *net.UDPConn:
+		var addr *net.UDPAddr
+		n, oobn, _, addr, err = c.ReadMsgUDP(buf, oob)
+		from = addr
+	default:
+		n, from, err = conn.ReadFrom(buf)
+	}
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return n, from, 0, nil
+	}
+	for _, m := range msgs {
+		isTTL := m.Header.Level == syscall.IPPROTO_IP && m.Header.Type == syscall.IP_TTL
+		isHopLimit := m.Header.Level == syscall.IPPROTO_IPV6 && m.Header.Type == syscall.IPV6_HOPLIMIT
+		if (isTTL || isHopLimit) && len(m.Data) >= 4 {
+			return n, from, int(binary.NativeEndian.Uint32(m.Data)), nil
+		}
+	}
+	return n, from, 0, nil
+}
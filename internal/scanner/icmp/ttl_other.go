@@ -0,0 +1,17 @@
+//go:build !linux
+
+package icmp
+
+import "net"
+
+// enableTTL is a no-op: reply TTLs are only collected on Linux.
+func enableTTL(conn net.PacketConn, v6 bool) error {
+	return nil
+}
+
+// readWithTTL reads one packet; the TTL is not available on this
+// platform and is reported as 0.
+func readWithTTL(conn net.PacketConn, buf []byte) (int, net.Addr, int, error) {
+	n, from, err := conn.ReadFrom(buf)
+	return n, from, 0, err
+}
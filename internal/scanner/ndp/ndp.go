@@ -0,0 +1,281 @@
+// Package ndp implements IPv6 neighbor discovery on a local link.
+//
+// IPv6 subnets are far too large to sweep address by address, so instead
+// of probing targets one at a time the scanner asks the link who is there:
+// it sends an ICMPv6 echo request to the all-nodes multicast group
+// (ff02::1) from each of the interface's addresses, collects the replies,
+// and then sends a Neighbor Solicitation to every responder to learn its
+// MAC address.
+package ndp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"maki/internal/packet"
+	"maki/internal/scanner"
+)
+
+// allNodes is the link-local all-nodes multicast group.
+var allNodes = net.ParseIP("ff02::1")
+
+// Scanner discovers IPv6 neighbors on a single interface.
+type Scanner struct {
+	timeout time.Duration
+	iface   string
+}
+
+// New creates a new neighbor discovery scanner for the given interface.
+// The timeout applies to each of the echo and solicitation phases.
+func New(timeout time.Duration, iface string) *Scanner {
+	return &Scanner{
+		timeout: timeout,
+		iface:   iface,
+	}
+}
+
+// Name returns the scanner name.
+func (s *Scanner) Name() string {
+	return "IPv6 Neighbor Discovery"
+}
+
+// neighbor is an address that answered on the link.
+type neighbor struct {
+	ip  net.IP
+	mac net.HardwareAddr
+}
+
+// Discover finds IPv6 neighbors on the scanner's interface and returns one
+// alive result per discovered address. Link-local addresses carry the
+// interface as zone ("fe80::1%eth0") so they can be used as targets.
+// Raw ICMPv6 sockets require root/CAP_NET_RAW.
+func (s *Scanner) Discover(ctx context.Context) ([]scanner.Result, error) {
+	ifi, err := net.InterfaceByName(s.iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %v", s.iface, err)
+	}
+
+	locals, err := localIPv6Addrs(ifi)
+	if err != nil {
+		return nil, err
+	}
+	if len(locals) == 0 {
+		return nil, fmt.Errorf("interface %s has no IPv6 addresses", s.iface)
+	}
+
+	// One socket per local address so that echo requests go out from each
+	// of them: hosts answer from an address of the same scope, which is how
+	// global addresses are found alongside link-local ones.
+	var conns []*net.IPConn
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	var linkLocal *net.IPConn
+	for _, ip := range locals {
+		c, err := net.ListenIP("ip6:ipv6-icmp", &net.IPAddr{IP: ip, Zone: s.iface})
+		if err != nil {
+			if os.IsPermission(err) || strings.Contains(err.Error(), "operation not permitted") {
+				return nil, fmt.Errorf("neighbor discovery requires root/sudo privileges")
+			}
+			return nil, fmt.Errorf("cannot open ICMPv6 socket on %s: %v", ip, err)
+		}
+		// Neighbor Discovery messages are only accepted with a hop limit
+		// of 255 (RFC 4861 section 7.1).
+		if err := setHopLimit(c, 255); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("cannot set hop limit: %v", err)
+		}
+		conns = append(conns, c)
+		if ip.IsLinkLocalUnicast() && linkLocal == nil {
+			linkLocal = c
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		neighbors = make(map[string]*neighbor)
+		own       = make(map[string]struct{})
+		id        = uint16(os.Getpid() & 0xffff)
+		wg        sync.WaitGroup
+	)
+	for _, ip := range locals {
+		own[ip.String()] = struct{}{}
+	}
+
+	deadline := time.Now().Add(2 * s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	for _, c := range conns {
+		_ = c.SetReadDeadline(deadline)
+		wg.Add(1)
+		go func(c *net.IPConn) {
+			defer wg.Done()
+			buf := make([]byte, 1500)
+			for {
+				n, from, err := c.ReadFromIP(buf)
+				if err != nil {
+					return
+				}
+				if _, ok := own[from.IP.String()]; ok {
+					continue
+				}
+				s.handleMessage(buf[:n], from.IP, id, &mu, neighbors)
+			}
+		}(c)
+	}
+
+	// Phase 1: multicast echo from every local address.
+	echo := &packet.ICMPEcho{Type: packet.ICMPv6EchoRequest, ID: id, Seq: 1, Data: []byte("maki")}
+	for _, c := range conns {
+		_, _ = c.WriteToIP(echo.Marshal(), &net.IPAddr{IP: allNodes, Zone: s.iface})
+	}
+	if !sleepCtx(ctx, s.timeout) {
+		return nil, ctx.Err()
+	}
+
+	// Phase 2: solicit every responder to learn its MAC address.
+	if linkLocal != nil {
+		mu.Lock()
+		var targets []net.IP
+		for _, n := range neighbors {
+			if n.mac == nil {
+				targets = append(targets, n.ip)
+			}
+		}
+		mu.Unlock()
+
+		for _, target := range targets {
+			ns := packet.NeighborSolicitation(target, ifi.HardwareAddr)
+			dst := &net.IPAddr{IP: packet.SolicitedNodeMulticast(target), Zone: s.iface}
+			_, _ = linkLocal.WriteToIP(ns, dst)
+		}
+	}
+
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	results := make([]scanner.Result, 0, len(neighbors))
+	for _, n := range neighbors {
+		results = append(results, s.result(n))
+	}
+	return results, nil
+}
+
+// handleMessage records echo replies and neighbor advertisements.
+func (s *Scanner) handleMessage(msg []byte, from net.IP, id uint16, mu *sync.Mutex, neighbors map[string]*neighbor) {
+	if len(msg) == 0 {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch msg[0] {
+	case packet.ICMPv6EchoReply:
+		echo, err := packet.ParseICMPEcho(msg)
+		if err != nil || echo.ID != id {
+			return
+		}
+		key := from.String()
+		if _, ok := neighbors[key]; !ok {
+			neighbors[key] = &neighbor{ip: from}
+		}
+	case packet.ICMPv6NeighborAdvert:
+		target, mac, err := packet.ParseNeighborAdvertisement(msg)
+		if err != nil || mac == nil {
+			return
+		}
+		key := target.String()
+		n, ok := neighbors[key]
+		if !ok {
+			n = &neighbor{ip: target}
+			neighbors[key] = n
+		}
+		n.mac = mac
+	}
+}
+
+// result converts a discovered neighbor into a scan result.
+func (s *Scanner) result(n *neighbor) scanner.Result {
+	ip := n.ip.String()
+	scope := "global"
+	if n.ip.IsLinkLocalUnicast() {
+		ip += "%" + s.iface
+		scope = "link-local"
+	}
+
+	details := scope
+	var mac string
+	if n.mac != nil {
+		mac = strings.ToUpper(n.mac.String())
+		details = fmt.Sprintf("MAC: %s, %s", mac, scope)
+	}
+
+	return scanner.Result{
+		IP:      ip,
+		Alive:   true,
+		Method:  s.Name(),
+		Details: details,
+		MAC:     mac,
+	}
+}
+
+// Correlate annotates neighbor discovery results with the IPv4 addresses
+// of hosts that share their MAC address, typically taken from an ARP scan.
+func Correlate(neighbors, ipv4 []scanner.Result) {
+	byMAC := make(map[string][]string)
+	for _, r := range ipv4 {
+		if r.Alive && r.MAC != "" {
+			mac := strings.ToUpper(r.MAC)
+			byMAC[mac] = append(byMAC[mac], r.IP)
+		}
+	}
+
+	for i := range neighbors {
+		if ips, ok := byMAC[neighbors[i].MAC]; ok && neighbors[i].MAC != "" {
+			neighbors[i].Details += fmt.Sprintf(", IPv4: %s", strings.Join(ips, ","))
+		}
+	}
+}
+
+// localIPv6Addrs returns the IPv6 unicast addresses assigned to ifi.
+func localIPv6Addrs(ifi *net.Interface) ([]net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list addresses of %s: %v", ifi.Name, err)
+	}
+
+	var ips []net.IP
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() != nil || ipnet.IP.IsLoopback() {
+			continue
+		}
+		ips = append(ips, ipnet.IP)
+	}
+	return ips, nil
+}
+
+// sleepCtx waits for d or until ctx is done, reporting whether the full
+// duration elapsed.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package ndp
+
+import (
+	"net"
+	"syscall"
+)
+
+// setHopLimit sets the unicast and multicast hop limit of c.
+func setHopLimit(c *net.IPConn, hops int) error {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, hops)
+		if sockErr == nil {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, hops)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
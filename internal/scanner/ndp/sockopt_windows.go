@@ -0,0 +1,12 @@
+package ndp
+
+import (
+	"errors"
+	"net"
+)
+
+// setHopLimit is not implemented on Windows, which does not let
+// unprivileged processes send Neighbor Discovery messages.
+func setHopLimit(c *net.IPConn, hops int) error {
+	return errors.New("neighbor discovery is not supported on windows")
+}
@@ -13,6 +13,194 @@ type Result struct {
 	Method   string
 	Details  string
 	Duration time.Duration
+
+	// MAC is the link-layer address of the host, when the scan method
+	// learns it (ARP, neighbor discovery).
+	MAC string
+
+	// Hostname holds the name(s) the host resolved to, when known.
+	Hostname string
+
+	// Hops is the path to the host found by traceroute, ending with the
+	// host itself when it was reached.
+	Hops []Hop
+
+	// RTT is the measured round-trip time of the probe that found the
+	// host, and TTL the TTL (hop limit) of its reply; 0 when unknown.
+	RTT time.Duration
+	TTL int
+
+	// Probe names the probe that got the answer, for scanners that send
+	// several kinds (for example "echo" or "timestamp" for ICMP).
+	Probe string
+
+	// Latency holds the round-trip statistics of a host probed repeatedly
+	// in monitoring mode; nil otherwise.
+	Latency *Latency
+
+	// Ports lists the ports that answered a port scan, in ascending order.
+	// Filtered ports are not listed; open|filtered UDP ports are.
+	Ports []Port
+}
+
+// PortState is the state of a scanned port.
+type PortState string
+
+const (
+	// PortOpen means the port accepted the connection or answered.
+	PortOpen PortState = "open"
+	// PortClosed means the host refused the connection (TCP RST), which
+	// still proves the host is up.
+	PortClosed PortState = "closed"
+	// PortFiltered means nothing answered before the timeout.
+	PortFiltered PortState = "filtered"
+	// PortOpenFiltered means a UDP probe got no answer: the port may be
+	// open with a service that ignored the probe, or filtered.
+	PortOpenFiltered PortState = "open|filtered"
+)
+
+// Port is the state of one port of a host.
+type Port struct {
+	Number   int
+	Protocol string // "tcp" or "udp"
+	State    PortState
+
+	// Service names the protocol spoken on the port when known, and
+	// Banner holds what the service said about itself, e.g. "ntp" and
+	// "NTPv4, stratum 2".
+	Service string
+	Banner  string
+
+	// Product and Version are filled in by service version detection,
+	// e.g. "OpenSSH" and "9.6p1".
+	Product string
+	Version string
+
+	// Cert is the certificate presented in a TLS handshake, when the TLS
+	// probe ran and the port speaks TLS.
+	Cert *Certificate
+
+	// TLS lists the protocol versions and cipher suites the port
+	// accepts, when the TLS audit ran and the port speaks TLS.
+	TLS *TLSInfo
+
+	// HTTP is what the HTTP probe learned from the port's web server.
+	HTTP *HTTPInfo
+
+	// SSH is what the SSH probe learned from the port's SSH server.
+	SSH *SSHInfo
+
+	// Exposure tells whether the database or cache on the port answers
+	// without credentials, when the exposure check ran.
+	Exposure *Exposure
+}
+
+// Exposure is the outcome of a read-only query sent to a database or
+// cache without credentials.
+type Exposure struct {
+	Service string // "redis", "memcached", "mongodb" or "elasticsearch"
+	Version string
+
+	// Unauthenticated is set when the query was answered; otherwise the
+	// server asked for credentials.
+	Unauthenticated bool
+
+	// Detail is what the answer revealed, e.g. "1520 keys" or
+	// "cluster logs-prod".
+	Detail string
+}
+
+// HTTPInfo fingerprints a web server from its front page.
+type HTTPInfo struct {
+	URL      string // the page fetched, e.g. "https://10.0.0.5:8443/"
+	Status   int
+	Server   string // Server header
+	Title    string // HTML page title
+	Redirect string // Location of a redirect, resolved against URL
+
+	// FaviconHash is the favicon hash in Shodan's format (MurmurHash3 of
+	// the base64-encoded icon), empty when there is no favicon.
+	FaviconHash string
+}
+
+// Certificate describes a TLS server certificate.
+type Certificate struct {
+	Subject     string
+	SANs        []string // DNS names and IP addresses
+	Issuer      string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	KeyType     string // e.g. "RSA 2048", "ECDSA P-256", "Ed25519"
+	Fingerprint string // SHA-256 of the DER encoding, in hex
+	SelfSigned  bool
+}
+
+// TLSInfo lists what a TLS server accepts.
+type TLSInfo struct {
+	Versions []TLSVersion // highest first
+
+	// Weak lists the accepted protocol versions and cipher suites
+	// considered deprecated, e.g. "protocol TLS 1.0" or
+	// "cipher TLS_RSA_WITH_RC4_128_SHA".
+	Weak []string
+}
+
+// TLSVersion is a protocol version a TLS server accepts and the cipher
+// suites it accepts with it, in the order the server chose them.
+type TLSVersion struct {
+	Name   string // e.g. "TLS 1.2"
+	Suites []string
+}
+
+// SSHInfo describes an SSH server: its identification, host keys and
+// the algorithms it offers.
+type SSHInfo struct {
+	Version  string // identification string, e.g. "SSH-2.0-OpenSSH_9.6p1"
+	HostKeys []SSHHostKey
+
+	KEX               []string
+	HostKeyAlgorithms []string
+	Ciphers           []string
+	MACs              []string
+
+	// Weak lists the offered algorithms and host keys considered weak,
+	// e.g. "cipher 3des-cbc" or "host key ssh-rsa 1024 bits".
+	Weak []string
+}
+
+// SSHHostKey is one host key of an SSH server.
+type SSHHostKey struct {
+	Type        string // e.g. "ssh-ed25519", "ssh-rsa"
+	Bits        int
+	Key         string // base64 public key blob, as in known_hosts
+	Fingerprint string // "SHA256:...", as ssh-keygen -l prints it
+}
+
+// Latency summarizes repeated probes of one host.
+type Latency struct {
+	Sent     int
+	Received int
+	Min      time.Duration
+	Avg      time.Duration
+	Max      time.Duration
+
+	// Jitter is the mean difference between consecutive round-trip times.
+	Jitter time.Duration
+}
+
+// Loss returns the percentage of probes that got no reply.
+func (l *Latency) Loss() float64 {
+	if l.Sent == 0 {
+		return 0
+	}
+	return float64(l.Sent-l.Received) * 100 / float64(l.Sent)
+}
+
+// Hop is one step on the path to a host.
+type Hop struct {
+	TTL int
+	IP  string // empty when nothing answered at this TTL
+	RTT time.Duration
 }
 
 // Scanner defines the interface that all scanner implementations must satisfy.
@@ -23,3 +211,14 @@ type Scanner interface {
 	// Name returns the human-readable name of the scanner.
 	Name() string
 }
+
+// BatchScanner is implemented by scanners that can probe all targets in a
+// single pass (for example from one socket) instead of one target per
+// engine worker.
+type BatchScanner interface {
+	// ScanAll scans all targets and returns one result per target. The
+	// progress callback, when non-nil, is called with the number of
+	// targets handled so far. ok is false when the scanner cannot batch
+	// in its current configuration; the engine then scans per target.
+	ScanAll(ctx context.Context, targets []string, progress func(done int)) (results []Result, ok bool)
+}
@@ -0,0 +1,95 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"maki/internal/scanner"
+)
+
+// DefaultPingPorts are the ports probed in ping mode unless SetPorts
+// selects others: services that are commonly reachable on servers,
+// workstations and network gear alike.
+var DefaultPingPorts = []int{80, 443, 22, 445, 3389}
+
+// SetPingMode switches the scanner to discovery only (like nmap -PS):
+// the ports are probed together and the host is reported alive as soon
+// as one of them accepts or refuses a connection, without waiting for the
+// rest. Enabling it selects DefaultPingPorts; call SetPorts afterwards to
+// probe others.
+func (s *Scanner) SetPingMode(enabled bool) {
+	s.ping = enabled
+	if enabled {
+		s.ports = append([]int(nil), DefaultPingPorts...)
+	}
+}
+
+// pingHost probes the ports until the first answer.
+func (s *Scanner) pingHost(ctx context.Context, ip string) scanner.Result {
+	start := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		port scanner.Port
+		err  error
+	}
+	answers := make(chan answer, len(s.ports))
+	sem := make(chan struct{}, s.concurrency())
+	go func() {
+		for _, port := range s.ports {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(p int) {
+				defer func() { <-sem }()
+				state, err := s.probePort(ctx, ip, p)
+				answers <- answer{scanner.Port{Number: p, Protocol: "tcp", State: state}, err}
+			}(port)
+		}
+	}()
+
+	for range s.ports {
+		var a answer
+		select {
+		case a = <-answers:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if a.err != nil {
+			return s.sourceFailed(ip, a.err, start)
+		}
+		p := a.port
+		if p.State == scanner.PortFiltered {
+			continue
+		}
+
+		details := fmt.Sprintf("Port %d open", p.Number)
+		if p.State == scanner.PortClosed {
+			details = fmt.Sprintf("Port %d refused (RST)", p.Number)
+		}
+		return scanner.Result{
+			IP:       ip,
+			Alive:    true,
+			Method:   s.Name(),
+			Details:  details,
+			Duration: time.Since(start),
+			Probe:    fmt.Sprintf("tcp/%d", p.Number),
+			Ports:    []scanner.Port{p},
+		}
+	}
+
+	return scanner.Result{
+		IP:       ip,
+		Alive:    false,
+		Method:   s.Name(),
+		Details:  "No response",
+		Duration: time.Since(start),
+	}
+}
@@ -0,0 +1,135 @@
+package tcp
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// commonPortsData is the list of common TCP ports, most frequently open
+// first, as comma-separated numbers.
+//
+//go:embed commonPorts.txt
+var commonPortsData string
+
+// commonPorts is the parsed, deduplicated commonPortsData.
+var commonPorts = parseCommonPorts(commonPortsData)
+
+// parseCommonPorts parses the embedded port list, keeping the first
+// occurrence of each valid port.
+func parseCommonPorts(data string) []int {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, field := range strings.Split(data, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || port < 1 || port > 65535 || seen[port] {
+			continue
+		}
+		seen[port] = true
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// CommonPorts returns the embedded list of common ports, most frequently
+// open first.
+func CommonPorts() []int {
+	return append([]int(nil), commonPorts...)
+}
+
+// TopPorts returns the n most common ports, or all of them when n is
+// larger than the list.
+func TopPorts(n int) []int {
+	if n > len(commonPorts) {
+		n = len(commonPorts)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return append([]int(nil), commonPorts[:n]...)
+}
+
+// ParsePorts parses a port specification: a comma-separated list of
+// ports ("22"), ranges ("8000-8100"), top-N selectors of the common port
+// list ("top100"), or "-" for every port. Duplicates are removed and the
+// order of first appearance is kept.
+func ParsePorts(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "-" {
+		return portRange(1, 65535), nil
+	}
+
+	seen := make(map[int]bool)
+	var ports []int
+	add := func(list []int) {
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		switch {
+		case field == "":
+			continue
+		case strings.HasPrefix(field, "top"):
+			n, err := strconv.Atoi(strings.TrimPrefix(field, "top"))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid top-N selector %q", field)
+			}
+			add(TopPorts(n))
+		case strings.Contains(field, "-"):
+			lo, hi, _ := strings.Cut(field, "-")
+			from, err := parsePort(lo, 1)
+			if err != nil {
+				return nil, err
+			}
+			to, err := parsePort(hi, 65535)
+			if err != nil {
+				return nil, err
+			}
+			if to < from {
+				return nil, fmt.Errorf("invalid port range %q", field)
+			}
+			add(portRange(from, to))
+		default:
+			port, err := parsePort(field, 0)
+			if err != nil {
+				return nil, err
+			}
+			add([]int{port})
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports in %q", spec)
+	}
+	return ports, nil
+}
+
+// parsePort parses a single port number; an empty string yields def, so
+// that open-ended ranges like "1024-" work.
+func parsePort(s string, def int) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" && def != 0 {
+		return def, nil
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q (want 1-65535)", s)
+	}
+	return port, nil
+}
+
+// portRange returns the ports from first to last inclusive.
+func portRange(first, last int) []int {
+	ports := make([]int, 0, last-first+1)
+	for p := first; p <= last; p++ {
+		ports = append(ports, p)
+	}
+	return ports
+}
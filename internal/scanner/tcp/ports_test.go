@@ -0,0 +1,125 @@
+package tcp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+	}{
+		{"22", []int{22}},
+		{" 22 , 80,443 ", []int{22, 80, 443}},
+		{"443,22,443,22", []int{443, 22}},
+		{"8000-8003", []int{8000, 8001, 8002, 8003}},
+		{"5-5", []int{5}},
+		{"80,79-81", []int{80, 79, 81}},
+		{"65533-", []int{65533, 65534, 65535}},
+		{"-3", []int{1, 2, 3}},
+		{"top3", []int{80, 23, 443}},
+		{"TOP3", []int{80, 23, 443}},
+		{"443,top3,22", []int{443, 80, 23, 22}},
+		{"top1,top3", []int{80, 23, 443}},
+		{",,22,,", []int{22}},
+		{"1,65535", []int{1, 65535}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePorts(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParsePortsAll(t *testing.T) {
+	for _, spec := range []string{"-", " - ", "1-65535", "-,22"} {
+		got, err := ParsePorts(spec)
+		if err != nil {
+			t.Fatalf("ParsePorts(%q): %v", spec, err)
+		}
+		if len(got) != 65535 || got[0] != 1 || got[65534] != 65535 {
+			t.Errorf("ParsePorts(%q) = %d ports from %d to %d, want 1-65535", spec, len(got), got[0], got[len(got)-1])
+		}
+	}
+}
+
+func TestParsePortsTopN(t *testing.T) {
+	all := CommonPorts()
+	got, err := ParsePorts("top100000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, all) {
+		t.Errorf("top100000 = %d ports, want the %d common ports", len(got), len(all))
+	}
+	if got, _ := ParsePorts("top100"); len(got) != 100 {
+		t.Errorf("top100 = %d ports", len(got))
+	}
+}
+
+func TestParsePortsErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		" , ",
+		"0",
+		"65536",
+		"-1-5",
+		"http",
+		"22,abc",
+		"100-90",
+		"0-10",
+		"10-70000",
+		"top0",
+		"top-5",
+		"top",
+		"topten",
+	} {
+		if got, err := ParsePorts(spec); err == nil {
+			t.Errorf("ParsePorts(%q) = %v, want an error", spec, got)
+		}
+	}
+}
+
+func TestCommonPorts(t *testing.T) {
+	ports := CommonPorts()
+	if len(ports) == 0 {
+		t.Fatal("CommonPorts() is empty")
+	}
+	seen := make(map[int]bool)
+	for _, p := range ports {
+		if p < 1 || p > 65535 || seen[p] {
+			t.Fatalf("CommonPorts() has invalid or duplicate port %d", p)
+		}
+		seen[p] = true
+	}
+
+	ports[0] = 0
+	if CommonPorts()[0] != 80 {
+		t.Error("CommonPorts() returns the shared list")
+	}
+}
+
+func TestTopPorts(t *testing.T) {
+	if got := TopPorts(0); len(got) != 0 {
+		t.Errorf("TopPorts(0) = %v", got)
+	}
+	if got := TopPorts(-1); len(got) != 0 {
+		t.Errorf("TopPorts(-1) = %v", got)
+	}
+	if got := TopPorts(2); !reflect.DeepEqual(got, []int{80, 23}) {
+		t.Errorf("TopPorts(2) = %v", got)
+	}
+}
+
+func TestParseCommonPorts(t *testing.T) {
+	got := parseCommonPorts("80, 23,80,x,0,70000,\n443\n")
+	if want := []int{80, 23, 443}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCommonPorts() = %v, want %v", got, want)
+	}
+}
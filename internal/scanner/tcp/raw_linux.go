@@ -0,0 +1,28 @@
+package tcp
+
+import (
+	"context"
+	"net"
+
+	"maki/internal/network"
+)
+
+// listenRawTCP opens a raw socket that sends TCP segments (the kernel adds
+// the IP header) and receives a copy of every incoming one. It is bound to
+// src's address and interface where they are set. It requires root or
+// CAP_NET_RAW.
+func listenRawTCP(v6 bool, src network.Source) (*net.IPConn, error) {
+	netw, laddr := "ip4:tcp", net.IPv4zero
+	if v6 {
+		netw, laddr = "ip6:tcp", net.IPv6unspecified
+	}
+	if ip := src.LocalIP(laddr); ip != nil {
+		laddr = ip
+	}
+	lc := net.ListenConfig{Control: src.Control}
+	conn, err := lc.ListenPacket(context.Background(), netw, laddr.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.IPConn), nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package tcp
+
+import (
+	"errors"
+	"net"
+	"runtime"
+
+	"maki/internal/network"
+)
+
+// listenRawTCP is not available on this platform: raw TCP sockets do not
+// receive incoming segments outside Linux.
+func listenRawTCP(v6 bool, src network.Source) (*net.IPConn, error) {
+	return nil, errors.New("SYN scan is not supported on " + runtime.GOOS)
+}
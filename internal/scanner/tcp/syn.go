@@ -0,0 +1,165 @@
+package tcp
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/packet"
+	"maki/internal/scanner"
+)
+
+// synProber sends SYNs from a raw socket and classifies the answers:
+// SYN-ACK means open, RST means closed, and silence means filtered. Open
+// ports are reset right away, so no connection is ever established and
+// services do not log one.
+type synProber struct {
+	conn *net.IPConn
+	v6   bool
+	port uint16 // our source port, shared by all probes
+	from network.Source
+
+	mu      sync.Mutex
+	waiters map[synKey]chan *packet.TCPSegment
+	sources map[string]net.IP
+}
+
+// synKey identifies an outstanding probe by the address and port the
+// answer comes from.
+type synKey struct {
+	ip   string
+	port uint16
+}
+
+// openSynProber opens a raw TCP socket for the address family, sending
+// from src where it is set.
+func openSynProber(v6 bool, src network.Source) (*synProber, error) {
+	conn, err := listenRawTCP(v6, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &synProber{
+		conn: conn,
+		v6:   v6,
+		// Pick a source port in the upper ephemeral range; the kernel
+		// resets any SYN-ACK to it as it has no socket bound there.
+		port:    uint16(40000 + rand.Intn(20000)),
+		from:    src,
+		waiters: make(map[synKey]chan *packet.TCPSegment),
+		sources: make(map[string]net.IP),
+	}
+	if src.Port != 0 {
+		p.port = uint16(src.Port)
+	}
+	go p.run()
+	return p, nil
+}
+
+// Close closes the socket, which also stops the reader.
+func (p *synProber) Close() error {
+	return p.conn.Close()
+}
+
+// probe sends a SYN to dst:port and waits up to timeout for the answer.
+func (p *synProber) probe(ctx context.Context, dst net.IP, port int, timeout time.Duration) scanner.PortState {
+	src, err := p.source(dst)
+	if err != nil {
+		return scanner.PortFiltered
+	}
+
+	key := synKey{ip: dst.String(), port: uint16(port)}
+	ch := make(chan *packet.TCPSegment, 1)
+	p.mu.Lock()
+	p.waiters[key] = ch
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiters, key)
+		p.mu.Unlock()
+	}()
+
+	seq := rand.Uint32()
+	syn := &packet.TCPSegment{SrcPort: p.port, DstPort: uint16(port), Seq: seq, Flags: packet.TCPFlagSYN, Window: 1024}
+	if _, err := p.conn.WriteToIP(syn.Marshal(src, dst), &net.IPAddr{IP: dst}); err != nil {
+		return scanner.PortFiltered
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case seg := <-ch:
+			switch {
+			case seg.Flags&packet.TCPFlagRST != 0:
+				return scanner.PortClosed
+			case seg.Flags&(packet.TCPFlagSYN|packet.TCPFlagACK) == packet.TCPFlagSYN|packet.TCPFlagACK && seg.Ack == seq+1:
+				rst := &packet.TCPSegment{SrcPort: p.port, DstPort: uint16(port), Seq: seg.Ack, Flags: packet.TCPFlagRST}
+				_, _ = p.conn.WriteToIP(rst.Marshal(src, dst), &net.IPAddr{IP: dst})
+				return scanner.PortOpen
+			}
+		case <-timer.C:
+			return scanner.PortFiltered
+		case <-ctx.Done():
+			return scanner.PortFiltered
+		}
+	}
+}
+
+// source returns the local address the kernel would send from to reach
+// dst, which the TCP checksum covers.
+func (p *synProber) source(dst net.IP) (net.IP, error) {
+	if ip := p.from.LocalIP(dst); ip != nil {
+		return ip, nil
+	}
+	p.mu.Lock()
+	src, ok := p.sources[dst.String()]
+	p.mu.Unlock()
+	if ok {
+		return src, nil
+	}
+
+	// Connecting a UDP socket sends nothing but resolves the route, out
+	// of the bound interface if there is one.
+	route := network.Source{Interface: p.from.Interface}
+	conn, err := route.Dialer(0).DialContext(context.Background(), "udp", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, err
+	}
+	src = conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	p.mu.Lock()
+	p.sources[dst.String()] = src
+	p.mu.Unlock()
+	return src, nil
+}
+
+// run reads TCP segments until the socket is closed and hands answers to
+// our source port to the waiting probe.
+func (p *synProber) run() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := p.conn.ReadFromIP(buf)
+		if err != nil {
+			return
+		}
+		seg, err := packet.ParseTCPSegment(buf[:n])
+		if err != nil || seg.DstPort != p.port {
+			continue
+		}
+
+		p.mu.Lock()
+		ch, ok := p.waiters[synKey{ip: from.IP.String(), port: seg.SrcPort}]
+		p.mu.Unlock()
+		if ok {
+			select {
+			case ch <- seg:
+			default:
+			}
+		}
+	}
+}
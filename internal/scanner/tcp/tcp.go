@@ -1,158 +1,463 @@
 // Package tcp implements TCP connect scanning for network discovery.
+//
+// With SYN mode enabled, ports are probed half-open from a raw socket
+// instead (Linux, root/CAP_NET_RAW), falling back to connect scanning
+// when the socket cannot be opened.
 package tcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"maki/internal/network"
+	"maki/internal/probe"
+	"maki/internal/probe/banner"
+	"maki/internal/probe/exposure"
+	"maki/internal/probe/service"
+	"maki/internal/probe/sshinfo"
+	"maki/internal/probe/tlsinfo"
+	"maki/internal/probe/webinfo"
+	"maki/internal/proxy"
 	"maki/internal/scanner"
 )
 
 // Scanner implements the Scanner interface for TCP connect scanning.
 type Scanner struct {
-	timeout time.Duration
-	ports   []int
+	timeout  time.Duration
+	ports    []int
+	ping     bool
+	syn      bool
+	banners  bool
+	version  bool
+	tls      bool
+	tlsAudit bool
+	http     bool
+	ssh      bool
+	exposure bool
+	proxy    *proxy.Dialer
+	source   network.Source
+
+	mu         sync.Mutex
+	synProbers map[bool]*synProber // keyed by "is IPv6"
+	synErr     map[bool]error
 }
 
-// New creates a new TCP scanner with the specified timeout.
-// It loads the common ports from the commonPorts.txt file.
+// New creates a new TCP scanner with the specified timeout. It scans the
+// embedded list of common ports until SetPorts selects others.
 func New(timeout time.Duration) *Scanner {
-	ports := loadCommonPorts()
 	return &Scanner{
-		timeout: timeout,
-		ports:   ports,
+		timeout:    timeout,
+		ports:      CommonPorts(),
+		synProbers: make(map[bool]*synProber),
+		synErr:     make(map[bool]error),
 	}
 }
 
-// loadCommonPorts reads and parses the commonPorts.txt file.
-func loadCommonPorts() []int {
-	// Try to read the commonPorts.txt file
-	data, err := os.ReadFile("internal/commonPorts.txt")
-	if err != nil {
-		// Fallback to a minimal set of common ports if file doesn't exist
-		fmt.Printf("Warning: Could not read commonPorts.txt, using minimal port list: %v\n", err)
-		return []int{21, 22, 23, 25, 53, 80, 110, 135, 139, 143, 443, 445, 993, 995, 3389, 8080}
-	}
+// SetSYN enables SYN (half-open) scanning: a SYN is sent from a raw
+// socket, SYN-ACK means open, RST closed and silence filtered, and open
+// ports are reset instead of completing the handshake. Without raw socket
+// access the scanner falls back to connect scanning.
+func (s *Scanner) SetSYN(enabled bool) {
+	s.syn = enabled
+}
 
-	// Parse comma-separated port numbers
-	portStrings := strings.Split(strings.TrimSpace(string(data)), ",")
-	ports := make([]int, 0, len(portStrings))
+// SetBannerGrab enables reading the greeting of every open port after
+// the scan, which identifies SSH, FTP, SMTP, POP3, IMAP, Telnet and MySQL
+// servers. It takes one more connection per open port.
+func (s *Scanner) SetBannerGrab(enabled bool) {
+	s.banners = enabled
+}
 
-	for _, portStr := range portStrings {
-		portStr = strings.TrimSpace(portStr)
-		if portStr == "" {
-			continue
-		}
+// SetVersionDetection enables identifying the service and version on
+// every open port after the scan by sending the probes of the built-in
+// service database and matching the responses, like nmap -sV. A port can
+// take several connections, each up to the timeout.
+func (s *Scanner) SetVersionDetection(enabled bool) {
+	s.version = enabled
+}
 
-		port, err := strconv.Atoi(portStr)
-		if err != nil {
-			fmt.Printf("Warning: Invalid port number '%s', skipping\n", portStr)
-			continue
-		}
+// SetTLSProbe enables a TLS handshake with every open port after the
+// scan, recording the certificate of those that speak TLS.
+func (s *Scanner) SetTLSProbe(enabled bool) {
+	s.tls = enabled
+}
 
-		if port < 1 || port > 65535 {
-			fmt.Printf("Warning: Port %d out of range (1-65535), skipping\n", port)
-			continue
+// SetHTTPProbe enables fingerprinting the web server on every open port
+// after the scan: status, Server header, title, redirect and favicon
+// hash. Ports identified as another service are skipped.
+func (s *Scanner) SetHTTPProbe(enabled bool) {
+	s.http = enabled
+}
+
+// SetTLSAudit enables enumerating the TLS protocol versions and cipher
+// suites every open port accepts after the scan. When the TLS probe runs
+// too, only ports that presented a certificate are audited.
+func (s *Scanner) SetTLSAudit(enabled bool) {
+	s.tlsAudit = enabled
+}
+
+// SetExposureCheck enables checking whether the Redis, Memcached,
+// MongoDB and Elasticsearch servers among the open ports answer without
+// credentials after the scan. Services are recognized by the name other
+// probes gave them or by their default port.
+func (s *Scanner) SetExposureCheck(enabled bool) {
+	s.exposure = enabled
+}
+
+// SetSSHProbe enables auditing the SSH server on every open port after
+// the scan: identification, host keys and offered algorithms, with weak
+// ones flagged. Ports identified as another service are skipped.
+func (s *Scanner) SetSSHProbe(enabled bool) {
+	s.ssh = enabled
+}
+
+// SetProxy routes every connection, port probes and open-port probes
+// alike, through a SOCKS5 or HTTP CONNECT proxy. SYN probing is disabled,
+// as raw packets cannot go through a proxy, and fewer connections are kept
+// in flight so as not to overwhelm it.
+func (s *Scanner) SetProxy(p *proxy.Dialer) {
+	s.proxy = p
+}
+
+// SetSource sends every probe, SYNs included, from a local address,
+// source port or interface. Connections to a proxy leave from the address
+// and interface but not the port.
+func (s *Scanner) SetSource(src network.Source) {
+	s.source = src
+}
+
+// synEnabled reports whether ports are probed with SYN packets.
+func (s *Scanner) synEnabled() bool {
+	return s.syn && s.proxy == nil
+}
+
+// concurrency returns how many ports of a host are probed at once.
+func (s *Scanner) concurrency() int {
+	if s.proxy != nil {
+		return maxProxiedPorts
+	}
+	return maxConcurrentPorts
+}
+
+// dialer returns the dialer for connect probes and banner grabs.
+func (s *Scanner) dialer() probe.Dialer {
+	if s.proxy != nil {
+		d := *s.proxy
+		d.Timeout = s.timeout
+		if !s.source.IsZero() {
+			d.Forward = network.Source{IP: s.source.IP, Interface: s.source.Interface}.Dialer(s.timeout)
 		}
+		return &d
+	}
+	return s.source.Dialer(s.timeout)
+}
 
-		ports = append(ports, port)
+// Close releases the raw sockets opened for SYN scanning.
+func (s *Scanner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for v6, p := range s.synProbers {
+		p.Close()
+		delete(s.synProbers, v6)
 	}
+	return nil
+}
 
-	if len(ports) == 0 {
-		fmt.Println("Warning: No valid ports found in commonPorts.txt, using minimal port list")
-		return []int{21, 22, 23, 25, 53, 80, 110, 135, 139, 143, 443, 445, 993, 995, 3389, 8080}
+// synProber returns the SYN prober for the address family, opening it on
+// first use, or nil when no raw socket is available.
+func (s *Scanner) synProber(v6 bool) *synProber {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.synProbers[v6]; ok {
+		return p
+	}
+	if _, failed := s.synErr[v6]; failed {
+		return nil
+	}
+
+	p, err := openSynProber(v6, s.source)
+	if err != nil {
+		s.synErr[v6] = err
+		fmt.Printf("\nℹ️  SYN scan unavailable (%v), falling back to connect scan\n", err)
+		return nil
+	}
+	s.synProbers[v6] = p
+	return p
+}
+
+// SetPorts sets the ports scanned on each host.
+func (s *Scanner) SetPorts(ports []int) {
+	if len(ports) > 0 {
+		s.ports = ports
 	}
+}
 
-	fmt.Printf("Loaded %d ports from commonPorts.txt\n", len(ports))
-	return ports
+// Ports returns the ports scanned on each host.
+func (s *Scanner) Ports() []int {
+	return s.ports
 }
 
 // Name returns the human-readable name of this scanner.
 func (s *Scanner) Name() string {
-	return "TCP Connect Scan"
+	name := "TCP Connect Scan"
+	switch {
+	case s.ping && s.synEnabled():
+		name = "TCP SYN Ping"
+	case s.ping:
+		name = "TCP Ping"
+	case s.synEnabled():
+		name = "TCP SYN Scan"
+	}
+	if s.proxy != nil {
+		name += " via " + s.proxy.String()
+	}
+	return name
 }
 
 // Scan performs a TCP connect scan on the given IP address.
-// It scans all common ports concurrently and returns a Result indicating
-// which ports are open.
+// It scans all configured ports concurrently and classifies each as open,
+// closed (refused with RST) or filtered (no answer). Any open or closed
+// port proves the host is up.
 func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
+	if s.ping {
+		return s.pingHost(ctx, ip)
+	}
+
 	start := time.Now()
-	openPorts := s.scanPorts(ctx, ip)
+	ports, err := s.scanPorts(ctx, ip)
+	if err != nil {
+		return s.sourceFailed(ip, err, start)
+	}
+	if s.banners || s.version || s.tls || s.tlsAudit || s.http || s.ssh || s.exposure {
+		s.probeOpenPorts(ctx, ip, ports)
+	}
 	duration := time.Since(start)
 
-	if len(openPorts) > 0 {
-		return scanner.Result{
-			IP:       ip,
-			Alive:    true,
-			Method:   s.Name(),
-			Details:  fmt.Sprintf("Ports: %s", formatPorts(openPorts)),
-			Duration: duration,
+	var open []int
+	counts := make(map[scanner.PortState]int)
+	answered := make([]scanner.Port, 0, len(ports))
+	for _, p := range ports {
+		counts[p.State]++
+		if p.State == scanner.PortOpen {
+			open = append(open, p.Number)
+		}
+		if p.State != scanner.PortFiltered {
+			answered = append(answered, p)
 		}
 	}
+	summary := fmt.Sprintf("%d open, %d closed, %d filtered",
+		counts[scanner.PortOpen], counts[scanner.PortClosed], counts[scanner.PortFiltered])
 
-	return scanner.Result{
+	result := scanner.Result{
 		IP:       ip,
-		Alive:    false,
+		Alive:    len(answered) > 0,
 		Method:   s.Name(),
-		Details:  "No open ports",
 		Duration: duration,
+		Ports:    answered,
+	}
+	switch {
+	case len(open) > 0:
+		result.Details = fmt.Sprintf("Ports: %s; %s", formatPorts(open), summary)
+	case result.Alive:
+		result.Details = fmt.Sprintf("No open ports, host answered with RST; %s", summary)
+	default:
+		result.Details = "No open ports"
 	}
+	return result
 }
 
-// scanPorts scans all configured ports for the given IP address concurrently.
-func (s *Scanner) scanPorts(ctx context.Context, ip string) []int {
+// maxConcurrentPorts bounds the connection attempts in flight to a
+// single host, so that large port ranges do not exhaust file descriptors.
+const maxConcurrentPorts = 500
+
+// maxProxiedPorts bounds the connections in flight to a single host
+// through a proxy.
+const maxProxiedPorts = 50
+
+// scanPorts scans all configured ports for the given IP address
+// concurrently and returns their states in ascending port order. Ports
+// not probed because ctx was cancelled are left out. The scan stops at
+// the first probe that cannot be sent from the source, returning its
+// error.
+func (s *Scanner) scanPorts(ctx context.Context, ip string) ([]scanner.Port, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	var (
-		openPorts []int
-		mu        sync.Mutex
-		wg        sync.WaitGroup
+		ports   []scanner.Port
+		bindErr error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, s.concurrency())
 	)
 
 	for _, port := range s.ports {
 		wg.Add(1)
+		sem <- struct{}{}
 		go func(p int) {
 			defer wg.Done()
+			defer func() { <-sem }()
 
 			// Check if context is cancelled
 			select {
 			case <-ctx.Done():
 				return
 			default:
-				if s.isPortOpen(ctx, ip, p) {
-					mu.Lock()
-					openPorts = append(openPorts, p)
-					mu.Unlock()
+				state, err := s.probePort(ctx, ip, p)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil && bindErr == nil {
+					bindErr = err
+					cancel()
 				}
+				if ctx.Err() != nil {
+					return
+				}
+				ports = append(ports, scanner.Port{Number: p, Protocol: "tcp", State: state})
 			}
 		}(port)
 	}
 
 	wg.Wait()
-	sort.Ints(openPorts)
-	return openPorts
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].Number < ports[j].Number
+	})
+	return ports, bindErr
 }
 
-// isPortOpen checks if a specific port is open on the given IP address.
-func (s *Scanner) isPortOpen(ctx context.Context, ip string, port int) bool {
-	address := fmt.Sprintf("%s:%d", ip, port)
-
-	dialer := &net.Dialer{
-		Timeout: s.timeout,
+// probePort classifies a port with a SYN probe when enabled and
+// available, and with a connect probe otherwise. An error means the probe
+// could not be sent from the source at all.
+func (s *Scanner) probePort(ctx context.Context, ip string, port int) (scanner.PortState, error) {
+	if s.synEnabled() {
+		if dst := net.ParseIP(ip); dst != nil {
+			if p := s.synProber(dst.To4() == nil); p != nil {
+				return p.probe(ctx, dst, port, s.timeout), nil
+			}
+		}
 	}
+	return s.connectPort(ctx, ip, port)
+}
+
+// connectPort connects to a port and classifies it: open when the
+// handshake completes, closed when the host refuses it, and filtered on
+// timeout or any other error. Failing to bind the source address or port
+// is returned as an error instead, since it says nothing about the port.
+func (s *Scanner) connectPort(ctx context.Context, ip string, port int) (scanner.PortState, error) {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
 
-	conn, err := dialer.DialContext(ctx, "tcp", address)
+	conn, err := s.dialer().DialContext(ctx, "tcp", address)
 	if err != nil {
-		return false
+		switch {
+		case network.IsBindError(err):
+			return scanner.PortFiltered, err
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return scanner.PortClosed, nil
+		}
+		return scanner.PortFiltered, nil
 	}
 
 	conn.Close()
-	return true
+	return scanner.PortOpen, nil
+}
+
+// sourceFailed is the result for a host whose probes could not be sent
+// from the configured source.
+func (s *Scanner) sourceFailed(ip string, err error, start time.Time) scanner.Result {
+	return scanner.Result{
+		IP:       ip,
+		Alive:    false,
+		Method:   s.Name(),
+		Details:  fmt.Sprintf("Cannot send from %s: %v", s.source, err),
+		Duration: time.Since(start),
+	}
+}
+
+// probeOpenPorts runs the enabled probes (banner grab, version
+// detection, TLS certificate and audit, exposure check, SSH audit, HTTP
+// fingerprint) on every open port concurrently and records what they
+// find.
+func (s *Scanner) probeOpenPorts(ctx context.Context, ip string, ports []scanner.Port) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, s.concurrency())
+	)
+	for i := range ports {
+		if ports[i].State != scanner.PortOpen {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p *scanner.Port) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			address := net.JoinHostPort(ip, strconv.Itoa(p.Number))
+			if s.banners {
+				if b, err := banner.Grab(ctx, s.dialer(), address, s.timeout); err == nil {
+					p.Service = b.Service
+					p.Banner = b.Text
+				}
+			}
+			if s.version {
+				if m, ok := service.Default().Detect(ctx, s.dialer(), address, p.Number, s.timeout); ok {
+					p.Service = m.Service
+					p.Product = m.Product
+					p.Version = m.Version
+					if p.Banner == "" {
+						p.Banner = m.Info
+					}
+				}
+			}
+			if s.tls {
+				if c, err := tlsinfo.Grab(ctx, s.dialer(), address, "", s.timeout); err == nil {
+					p.Cert = c
+				}
+			}
+			if s.tlsAudit && (!s.tls || p.Cert != nil) {
+				if info, err := tlsinfo.Audit(ctx, s.dialer(), address, s.timeout); err == nil {
+					p.TLS = info
+				}
+			}
+			if s.exposure {
+				if svc := exposure.Service(p.Service, p.Number); svc != "" {
+					if e, err := exposure.Check(ctx, s.dialer(), address, svc, s.timeout); err == nil {
+						p.Exposure = e
+						if p.Service == "" {
+							p.Service = svc
+						}
+					}
+				}
+			}
+			if s.ssh && (p.Service == "" || p.Service == "ssh") {
+				if info, err := sshinfo.Grab(ctx, s.dialer(), address, s.timeout); err == nil {
+					p.SSH = info
+					p.Service = "ssh"
+				}
+			}
+			if s.http && (p.Service == "" || strings.HasPrefix(p.Service, "http")) {
+				if info, err := webinfo.Fetch(ctx, s.dialer(), ip, p.Number, p.Cert != nil, s.timeout); err == nil {
+					p.HTTP = info
+					if p.Service == "" {
+						p.Service = "http"
+						if strings.HasPrefix(info.URL, "https:") {
+							p.Service = "https"
+						}
+					}
+				}
+			}
+		}(&ports[i])
+	}
+	wg.Wait()
 }
 
 // formatPorts formats a slice of port numbers as a comma-separated string.
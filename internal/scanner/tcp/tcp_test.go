@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/scanner"
+)
+
+// listen opens a local listener that accepts and closes connections.
+func listen(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr)
+}
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestScanPortStates(t *testing.T) {
+	open := listen(t).Port
+	closed := closedPort(t)
+
+	s := New(time.Second)
+	s.SetPorts([]int{closed, open})
+	r := s.Scan(context.Background(), "127.0.0.1")
+	if !r.Alive || len(r.Ports) != 2 {
+		t.Fatalf("Scan() = alive %v, ports %+v, details %q", r.Alive, r.Ports, r.Details)
+	}
+	for _, p := range r.Ports {
+		want := scanner.PortClosed
+		if p.Number == open {
+			want = scanner.PortOpen
+		}
+		if p.State != want {
+			t.Errorf("port %d = %v, want %v", p.Number, p.State, want)
+		}
+	}
+}
+
+func TestScanSourceBindFailure(t *testing.T) {
+	open := listen(t).Port
+	// A listener holds the source port, so no probe can be bound to it.
+	busy := network.Source{Port: listen(t).Port}
+
+	for _, ping := range []bool{false, true} {
+		s := New(time.Second)
+		s.SetSource(busy)
+		if ping {
+			s.SetPingMode(true)
+		}
+		s.SetPorts([]int{open, closedPort(t)})
+		r := s.Scan(context.Background(), "127.0.0.1")
+		if r.Alive || len(r.Ports) != 0 || !strings.HasPrefix(r.Details, "Cannot send from "+busy.String()) || !strings.Contains(r.Details, "address already in use") {
+			t.Errorf("Scan() with ping %v = alive %v, ports %+v, details %q; want a bind failure", ping, r.Alive, r.Ports, r.Details)
+		}
+	}
+}
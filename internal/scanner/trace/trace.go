@@ -0,0 +1,461 @@
+// Package trace implements traceroute-style path discovery.
+//
+// Probes are sent with increasing TTL (IPv6 hop limit); routers along the
+// way answer with ICMP Time Exceeded, and the destination answers with a
+// port unreachable (UDP), an echo reply (ICMP) or a SYN-ACK/RST (TCP).
+// All ICMP answers are read from one raw socket per address family and
+// matched to the waiting probe by the headers they quote.
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/packet"
+	"maki/internal/scanner"
+)
+
+// Mode selects the probe protocol.
+type Mode string
+
+const (
+	ModeUDP  Mode = "udp"
+	ModeICMP Mode = "icmp"
+	ModeTCP  Mode = "tcp"
+)
+
+const (
+	// DefaultMaxHops is the default TTL limit.
+	DefaultMaxHops = 30
+
+	// udpBasePort is the first destination port of UDP probes; the TTL is
+	// added to it, as classic traceroute does.
+	udpBasePort = 33434
+
+	// tcpPort is the destination port of TCP probes.
+	tcpPort = 80
+
+	// tcpBasePort is the first source port of TCP probes; the TTL is added
+	// to it so that the answers quoting each probe can be told apart.
+	tcpBasePort = 33434
+
+	// maxSilentHops stops tracing after this many consecutive hops that
+	// did not answer.
+	maxSilentHops = 5
+)
+
+// Scanner implements traceroute against each target.
+type Scanner struct {
+	timeout time.Duration
+	mode    Mode
+	maxHops int
+	id      uint16
+
+	mu        sync.Mutex
+	listeners map[bool]*listener // keyed by "is IPv6"
+	seq       uint16
+}
+
+// New creates a new traceroute scanner. The timeout applies to each hop.
+func New(timeout time.Duration, mode Mode) *Scanner {
+	switch mode {
+	case ModeICMP, ModeTCP:
+	default:
+		mode = ModeUDP
+	}
+	return &Scanner{
+		timeout:   timeout,
+		mode:      mode,
+		maxHops:   DefaultMaxHops,
+		id:        uint16(os.Getpid() & 0xffff),
+		listeners: make(map[bool]*listener),
+	}
+}
+
+// SetMaxHops sets the maximum TTL probed.
+func (s *Scanner) SetMaxHops(n int) {
+	if n > 0 && n <= 255 {
+		s.maxHops = n
+	}
+}
+
+// Name returns the scanner name.
+func (s *Scanner) Name() string {
+	return fmt.Sprintf("Traceroute (%s)", strings.ToUpper(string(s.mode)))
+}
+
+// Close releases the raw sockets opened by the scanner.
+func (s *Scanner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for v6, l := range s.listeners {
+		l.conn.Close()
+		delete(s.listeners, v6)
+	}
+	return nil
+}
+
+// permissionWarningShown tracks if we've already shown the permission warning
+var permissionWarningShown bool
+
+// Scan traces the path to the target IP.
+func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
+	start := time.Now()
+
+	dst := net.ParseIP(ip)
+	if dst == nil {
+		return s.failed(ip, "Invalid address", start)
+	}
+	v6 := dst.To4() == nil
+
+	l, err := s.listener(v6)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EPERM) {
+			s.mu.Lock()
+			if !permissionWarningShown {
+				fmt.Println("\n⚠️  WARNING: Traceroute requires root/sudo privileges")
+				fmt.Println("   Please run with: sudo")
+				fmt.Println()
+				permissionWarningShown = true
+			}
+			s.mu.Unlock()
+		}
+		return s.failed(ip, fmt.Sprintf("Cannot receive ICMP: %v", err), start)
+	}
+
+	var (
+		hops    []scanner.Hop
+		reached bool
+		silent  int
+	)
+	for ttl := 1; ttl <= s.maxHops; ttl++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		hop, done := s.probe(ctx, l, dst, v6, ttl)
+		hops = append(hops, hop)
+
+		if done {
+			reached = hop.IP == dst.String()
+			break
+		}
+		if hop.IP == "" {
+			silent++
+			if silent >= maxSilentHops {
+				break
+			}
+		} else {
+			silent = 0
+		}
+	}
+
+	// Drop the run of silent hops at the end of an unfinished trace.
+	for len(hops) > 0 && hops[len(hops)-1].IP == "" {
+		hops = hops[:len(hops)-1]
+	}
+
+	result := scanner.Result{
+		IP:       ip,
+		Alive:    reached,
+		Method:   s.Name(),
+		Duration: time.Since(start),
+		Hops:     hops,
+	}
+	if reached {
+		suffix := "s"
+		if len(hops) == 1 {
+			suffix = ""
+		}
+		result.Details = fmt.Sprintf("%d hop%s: %s", len(hops), suffix, FormatPath(hops))
+	} else if len(hops) > 0 {
+		result.Details = fmt.Sprintf("Not reached, last hop %s: %s", hops[len(hops)-1].IP, FormatPath(hops))
+	} else {
+		result.Details = "No response"
+	}
+	return result
+}
+
+// FormatPath formats hops as "192.168.1.1 > * > 10.0.0.1".
+func FormatPath(hops []scanner.Hop) string {
+	parts := make([]string, len(hops))
+	for i, h := range hops {
+		if h.IP == "" {
+			parts[i] = "*"
+		} else {
+			parts[i] = h.IP
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+func (s *Scanner) failed(ip, details string, start time.Time) scanner.Result {
+	return scanner.Result{
+		IP:       ip,
+		Alive:    false,
+		Method:   s.Name(),
+		Details:  details,
+		Duration: time.Since(start),
+	}
+}
+
+// probe sends one probe with the given TTL and waits for its answer. It
+// reports whether the trace is finished: the destination answered, or a
+// router reported it unreachable.
+func (s *Scanner) probe(ctx context.Context, l *listener, dst net.IP, v6 bool, ttl int) (scanner.Hop, bool) {
+	hop := scanner.Hop{TTL: ttl}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var key probeKey
+	switch s.mode {
+	case ModeICMP:
+		s.mu.Lock()
+		s.seq++
+		seq := s.seq
+		s.mu.Unlock()
+		key = probeKey{proto: packet.ProtoICMP, seq: seq}
+	case ModeTCP:
+		key = probeKey{proto: packet.ProtoTCP, dst: dst.String(), port: tcpPort, srcPort: uint16(tcpBasePort + ttl)}
+	default:
+		key = probeKey{proto: packet.ProtoUDP, dst: dst.String(), port: uint16(udpBasePort + ttl)}
+	}
+
+	replies := l.register(key)
+	defer l.unregister(key)
+
+	start := time.Now()
+	var tcpDone chan error
+
+	switch s.mode {
+	case ModeICMP:
+		echoType := uint8(packet.ICMPv4EchoRequest)
+		if v6 {
+			echoType = packet.ICMPv6EchoRequest
+		}
+		echo := &packet.ICMPEcho{Type: echoType, ID: s.id, Seq: key.seq, Data: []byte("maki-trace")}
+		if err := l.send(echo.Marshal(), dst, ttl); err != nil {
+			return hop, false
+		}
+	case ModeTCP:
+		tcpDone = make(chan error, 1)
+		go func() {
+			// Concurrent traces to other targets share the source
+			// port, which Source.Control allows with SO_REUSEADDR.
+			src := network.Source{Port: int(key.srcPort)}
+			d := net.Dialer{
+				LocalAddr: &net.TCPAddr{Port: src.Port},
+				Control: func(netw, addr string, c syscall.RawConn) error {
+					if err := src.Control(netw, addr, c); err != nil {
+						return err
+					}
+					return network.TTLControl(ttl)(netw, addr, c)
+				},
+			}
+			conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(dst.String(), strconv.Itoa(tcpPort)))
+			if err == nil {
+				conn.Close()
+			}
+			tcpDone <- err
+		}()
+	default:
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dst, Port: int(key.port)})
+		if err != nil {
+			return hop, false
+		}
+		defer conn.Close()
+		if err := network.SetTTL(conn, v6, ttl); err != nil {
+			return hop, false
+		}
+		if _, err := conn.Write([]byte("maki-trace")); err != nil {
+			return hop, false
+		}
+	}
+
+	select {
+	case r := <-replies:
+		hop.IP = r.from.String()
+		hop.RTT = r.at.Sub(start)
+		return hop, r.final || !r.timeExceeded
+	case err := <-tcpDone:
+		// The handshake completed or was refused: either way the
+		// destination itself answered.
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			hop.IP = dst.String()
+			hop.RTT = time.Since(start)
+			return hop, true
+		}
+		// Otherwise wait out any ICMP answer still in flight.
+		select {
+		case r := <-replies:
+			hop.IP = r.from.String()
+			hop.RTT = r.at.Sub(start)
+			return hop, !r.timeExceeded
+		case <-ctx.Done():
+			return hop, false
+		}
+	case <-ctx.Done():
+		return hop, false
+	}
+}
+
+// probeKey identifies an outstanding probe in the quoted headers of ICMP
+// answers. srcPort is only set for TCP, whose destination port is the same
+// for every TTL.
+type probeKey struct {
+	proto   uint8
+	dst     string
+	port    uint16
+	srcPort uint16
+	seq     uint16
+}
+
+// reply is an ICMP answer matched to a probe.
+type reply struct {
+	from         net.IP
+	at           time.Time
+	timeExceeded bool
+	final        bool // echo reply from the destination
+}
+
+// listener reads ICMP answers from a raw socket and hands them to the
+// probe waiting for them.
+type listener struct {
+	conn *net.IPConn
+	v6   bool
+	id   uint16
+
+	sendMu  sync.Mutex
+	mu      sync.Mutex
+	waiters map[probeKey]chan reply
+}
+
+// listener returns the raw ICMP listener for the address family, opening
+// it on first use.
+func (s *Scanner) listener(v6 bool) (*listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.listeners[v6]; ok {
+		return l, nil
+	}
+
+	netw, laddr := "ip4:icmp", "0.0.0.0"
+	if v6 {
+		netw, laddr = "ip6:ipv6-icmp", "::"
+	}
+	conn, err := net.ListenIP(netw, &net.IPAddr{IP: net.ParseIP(laddr)})
+	if err != nil {
+		return nil, err
+	}
+
+	l := &listener{
+		conn:    conn,
+		v6:      v6,
+		id:      s.id,
+		waiters: make(map[probeKey]chan reply),
+	}
+	s.listeners[v6] = l
+	go l.run()
+	return l, nil
+}
+
+func (l *listener) register(key probeKey) chan reply {
+	ch := make(chan reply, 1)
+	l.mu.Lock()
+	l.waiters[key] = ch
+	l.mu.Unlock()
+	return ch
+}
+
+func (l *listener) unregister(key probeKey) {
+	l.mu.Lock()
+	delete(l.waiters, key)
+	l.mu.Unlock()
+}
+
+// send writes an ICMP message to dst with the given TTL. The TTL is a
+// socket option, so sends on the shared socket are serialized.
+func (l *listener) send(msg []byte, dst net.IP, ttl int) error {
+	l.sendMu.Lock()
+	defer l.sendMu.Unlock()
+
+	if err := network.SetTTL(l.conn, l.v6, ttl); err != nil {
+		return err
+	}
+	_, err := l.conn.WriteToIP(msg, &net.IPAddr{IP: dst})
+	return err
+}
+
+// run dispatches incoming ICMP messages until the socket is closed.
+func (l *listener) run() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := l.conn.ReadFromIP(buf)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		msg := buf[:n]
+		if len(msg) == 0 {
+			continue
+		}
+
+		echoReply := uint8(packet.ICMPv4EchoReply)
+		if l.v6 {
+			echoReply = packet.ICMPv6EchoReply
+		}
+
+		var key probeKey
+		r := reply{from: from.IP, at: now}
+
+		if msg[0] == echoReply {
+			echo, err := packet.ParseICMPEcho(msg)
+			if err != nil || echo.ID != l.id {
+				continue
+			}
+			key = probeKey{proto: packet.ProtoICMP, seq: echo.Seq}
+			r.final = true
+		} else {
+			icmpErr, err := packet.ParseICMPError(msg, l.v6)
+			if err != nil {
+				continue
+			}
+			r.timeExceeded = icmpErr.IsTimeExceeded()
+			switch icmpErr.Protocol {
+			case packet.ProtoUDP, packet.ProtoTCP:
+				key = probeKey{proto: icmpErr.Protocol, dst: icmpErr.Dst.String(), port: icmpErr.DstPort}
+				if icmpErr.Protocol == packet.ProtoTCP {
+					key.srcPort = icmpErr.SrcPort
+				}
+			case packet.ProtoICMP, packet.ProtoICMPv6:
+				if icmpErr.EchoID != l.id {
+					continue
+				}
+				key = probeKey{proto: packet.ProtoICMP, seq: icmpErr.EchoSeq}
+			default:
+				continue
+			}
+		}
+
+		l.mu.Lock()
+		ch, ok := l.waiters[key]
+		l.mu.Unlock()
+		if ok {
+			select {
+			case ch <- r:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package udp
+
+import (
+	"fmt"
+	"strings"
+
+	"maki/internal/scanner/tcp"
+)
+
+// ParsePorts parses a UDP port specification: a comma-separated list of
+// ports and ranges, or "-" for every port, as tcp.ParsePorts reads it.
+// Top-N selectors are rejected, since the common port list ranks TCP
+// ports.
+func ParsePorts(spec string) ([]int, error) {
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if strings.HasPrefix(field, "top") {
+			return nil, fmt.Errorf("%q ranks TCP ports; list UDP ports or ranges instead", field)
+		}
+	}
+	return tcp.ParsePorts(spec)
+}
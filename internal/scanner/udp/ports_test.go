@@ -0,0 +1,25 @@
+package udp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	got, err := ParsePorts("53, 161-162,500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{53, 161, 162, 500}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePorts() = %v, want %v", got, want)
+	}
+	if got, err := ParsePorts("-"); err != nil || len(got) != 65535 {
+		t.Errorf("ParsePorts(\"-\") = %d ports, %v", len(got), err)
+	}
+
+	for _, spec := range []string{"top10", "53, TOP5", "top", "0", "", "100-90"} {
+		if got, err := ParsePorts(spec); err == nil {
+			t.Errorf("ParsePorts(%q) = %v, want an error", spec, got)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"maki/internal/probe"
+)
+
+// udpProbe is a protocol-specific payload for a well-known UDP port, with a
+// decoder that summarizes the answer ("" when nothing useful is found).
+type udpProbe struct {
+	service string
+	payload func() []byte
+	decode  func(resp []byte) string
+}
+
+// probes maps well-known ports to their probes. Ports not listed get an
+// empty datagram.
+var probes = map[int]udpProbe{
+	53:   {"dns", dnsVersionQuery, decodeDNSVersion},
+	123:  {"ntp", ntpRequest, decodeNTP},
+	137:  {"netbios-ns", netbiosStatusQuery, decodeNetBIOS},
+	161:  {"snmp", snmpGetSysDescr, decodeSNMP},
+	1900: {"ssdp", ssdpSearch, decodeSSDP},
+	5353: {"mdns", mdnsServicesQuery, decodeMDNS},
+}
+
+// DefaultPorts are the ports with protocol-specific probes.
+var DefaultPorts = []int{53, 123, 137, 161, 1900, 5353}
+
+// maxBanner bounds the length of decoded answers.
+const maxBanner = 120
+
+// DNS record types and classes used by the probes.
+const (
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsClassIN = 1
+	dnsClassCH = 3
+)
+
+// dnsQuery builds a DNS query for name.
+func dnsQuery(id uint16, name string, qtype, qclass uint16) []byte {
+	b := make([]byte, 12, 64)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[4:], 1) // QDCOUNT
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	b = binary.BigEndian.AppendUint16(b, qclass)
+	return b
+}
+
+// dnsVersionQuery asks a DNS server for its version (version.bind CH TXT).
+func dnsVersionQuery() []byte {
+	return dnsQuery(0x6d6b, "version.bind", dnsTypeTXT, dnsClassCH)
+}
+
+// decodeDNSVersion returns the version string of a version.bind answer.
+func decodeDNSVersion(resp []byte) string {
+	answers, err := dnsAnswers(resp, dnsTypeTXT)
+	if err != nil || len(answers) == 0 {
+		return ""
+	}
+	rdata := answers[0].rdata
+	if len(rdata) == 0 {
+		return ""
+	}
+	n := int(rdata[0])
+	if n > len(rdata)-1 {
+		return ""
+	}
+	return probe.Sanitize(string(rdata[1:1+n]), maxBanner)
+}
+
+// mdnsServicesQuery asks an mDNS responder, over unicast, for the DNS-SD
+// service types it advertises.
+func mdnsServicesQuery() []byte {
+	return dnsQuery(0x6d6b, "_services._dns-sd._udp.local", dnsTypePTR, dnsClassIN)
+}
+
+// decodeMDNS lists the advertised service types, e.g. "_http._tcp, _ipp._tcp".
+func decodeMDNS(resp []byte) string {
+	answers, err := dnsAnswers(resp, dnsTypePTR)
+	if err != nil {
+		return ""
+	}
+	var services []string
+	for _, a := range answers {
+		name, _, err := readDNSName(resp, a.offset)
+		if err != nil {
+			continue
+		}
+		services = append(services, strings.TrimSuffix(name, ".local"))
+	}
+	return probe.Sanitize(strings.Join(services, ", "), maxBanner)
+}
+
+// dnsAnswer is one answer record; offset locates its rdata in the message
+// for decoding compressed names.
+type dnsAnswer struct {
+	rdata  []byte
+	offset int
+}
+
+// dnsAnswers returns the answer records of the given type in msg.
+func dnsAnswers(msg []byte, qtype uint16) ([]dnsAnswer, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("dns: truncated header")
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	an := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	for i := 0; i < qd; i++ {
+		_, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+
+	var answers []dnsAnswer
+	for i := 0; i < an; i++ {
+		_, next, err := readDNSName(msg, off)
+		if err != nil {
+			return answers, err
+		}
+		if next+10 > len(msg) {
+			return answers, errors.New("dns: truncated record")
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		if start+length > len(msg) {
+			return answers, errors.New("dns: truncated rdata")
+		}
+		if typ == qtype {
+			answers = append(answers, dnsAnswer{rdata: msg[start : start+length], offset: start})
+		}
+		off = start + length
+	}
+	return answers, nil
+}
+
+// readDNSName reads a possibly compressed name at off and returns it with
+// the offset just past it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("dns: truncated name")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 16 {
+				return "", 0, errors.New("dns: bad compression pointer")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("dns: truncated label")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// ntpRequest is an NTPv4 client request.
+func ntpRequest() []byte {
+	b := make([]byte, 48)
+	b[0] = 4<<3 | 3 // version 4, mode 3 (client)
+	return b
+}
+
+// decodeNTP reports the server's version and stratum, plus the reference
+// ID of primary (stratum 1) servers, e.g. "NTPv4, stratum 1, refid GPS".
+func decodeNTP(resp []byte) string {
+	if len(resp) < 48 {
+		return ""
+	}
+	version := resp[0] >> 3 & 7
+	stratum := resp[1]
+	if stratum == 1 {
+		return fmt.Sprintf("NTPv%d, stratum 1, refid %s", version, probe.Sanitize(string(bytes.TrimRight(resp[12:16], "\x00")), 4))
+	}
+	return fmt.Sprintf("NTPv%d, stratum %d", version, stratum)
+}
+
+// netbiosStatusQuery is a NetBIOS node status (NBSTAT) request for the
+// wildcard name "*".
+func netbiosStatusQuery() []byte {
+	b := []byte{
+		0x6d, 0x6b, // transaction ID
+		0x00, 0x00, // flags
+		0x00, 0x01, // QDCOUNT
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 'C', 'K', // "*", first-level encoded
+	}
+	for i := 0; i < 15; i++ {
+		b = append(b, 'A', 'A') // NUL padding
+	}
+	return append(b, 0x00, 0x00, 0x21, 0x00, 0x01) // NBSTAT, IN
+}
+
+// decodeNetBIOS returns the computer name and workgroup from a node
+// status response, e.g. "FILESERVER, workgroup OFFICE".
+func decodeNetBIOS(resp []byte) string {
+	_, off, err := readDNSName(resp, 12)
+	if err != nil || off+11 > len(resp) {
+		return ""
+	}
+	off += 10 // type, class, TTL, rdlength
+	count := int(resp[off])
+	off++
+
+	var name, group string
+	for i := 0; i < count && off+18 <= len(resp); i, off = i+1, off+18 {
+		entry := strings.TrimRight(string(resp[off:off+15]), " \x00")
+		suffix := resp[off+15]
+		isGroup := resp[off+16]&0x80 != 0
+		if suffix != 0x00 {
+			continue
+		}
+		if isGroup && group == "" {
+			group = entry
+		} else if !isGroup && name == "" {
+			name = entry
+		}
+	}
+
+	switch {
+	case name != "" && group != "":
+		return probe.Sanitize(fmt.Sprintf("%s, workgroup %s", name, group), maxBanner)
+	default:
+		return probe.Sanitize(name+group, maxBanner)
+	}
+}
+
+// sysDescrOID is the BER encoding of 1.3.6.1.2.1.1.1.0 (sysDescr.0).
+var sysDescrOID = []byte{0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+
+// snmpGetSysDescr is an SNMPv1 GetRequest for sysDescr.0 with the
+// "public" community.
+func snmpGetSysDescr() []byte {
+	varbind := ber(0x30, sysDescrOID, []byte{0x05, 0x00})
+	pdu := ber(0xa0,
+		[]byte{0x02, 0x04, 0x6d, 0x61, 0x6b, 0x69}, // request ID
+		[]byte{0x02, 0x01, 0x00},                   // error status
+		[]byte{0x02, 0x01, 0x00},                   // error index
+		ber(0x30, varbind),
+	)
+	return ber(0x30,
+		[]byte{0x02, 0x01, 0x00}, // version 1
+		ber(0x04, []byte("public")),
+		pdu,
+	)
+}
+
+// ber encodes a BER TLV with a short-form length.
+func ber(tag byte, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	return append([]byte{tag, byte(len(body))}, body...)
+}
+
+// decodeSNMP returns the first line of sysDescr from a GetResponse.
+func decodeSNMP(resp []byte) string {
+	i := bytes.Index(resp, sysDescrOID)
+	if i < 0 {
+		return ""
+	}
+	b := resp[i+len(sysDescrOID):]
+	if len(b) < 2 || b[0] != 0x04 {
+		return ""
+	}
+
+	length, off := int(b[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 2 || len(b) < 2+n {
+			return ""
+		}
+		length = 0
+		for _, c := range b[2 : 2+n] {
+			length = length<<8 | int(c)
+		}
+		off += n
+	}
+	if off+length > len(b) {
+		length = len(b) - off
+	}
+	descr, _, _ := strings.Cut(string(b[off:off+length]), "\n")
+	return probe.Sanitize(descr, maxBanner)
+}
+
+// ssdpSearch is an SSDP M-SEARCH for all device and service types.
+func ssdpSearch() []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: ssdp:all\r\n\r\n")
+}
+
+// decodeSSDP returns the SERVER header of an M-SEARCH response.
+func decodeSSDP(resp []byte) string {
+	for _, line := range strings.Split(string(resp), "\r\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "server") {
+			return probe.Sanitize(strings.TrimSpace(value), maxBanner)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// dnsResponse builds a response to query carrying one answer record per
+// rdata, with the owner name compressed to the question.
+func dnsResponse(query []byte, qtype uint16, rdatas ...[]byte) []byte {
+	b := append([]byte(nil), query...)
+	b[2] = 0x84 // response, authoritative
+	binary.BigEndian.PutUint16(b[6:], uint16(len(rdatas)))
+	for _, rdata := range rdatas {
+		b = append(b, 0xc0, 12) // pointer to the question name
+		b = binary.BigEndian.AppendUint16(b, qtype)
+		b = binary.BigEndian.AppendUint16(b, dnsClassCH)
+		b = binary.BigEndian.AppendUint32(b, 0)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+		b = append(b, rdata...)
+	}
+	return b
+}
+
+// txt encodes s as a single TXT character string.
+func txt(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func TestDecodeDNSVersion(t *testing.T) {
+	query := dnsVersionQuery()
+	long := strings.Repeat("v", 255)
+	full := dnsResponse(query, dnsTypeTXT, txt("9.18.24-Ubuntu"))
+
+	tests := []struct {
+		name string
+		resp []byte
+		want string
+	}{
+		{"version", full, "9.18.24-Ubuntu"},
+		{"255-byte string", dnsResponse(query, dnsTypeTXT, txt(long)), long[:maxBanner]},
+		{"empty string", dnsResponse(query, dnsTypeTXT, txt("")), ""},
+		{"empty rdata", dnsResponse(query, dnsTypeTXT, nil), ""},
+		{"string longer than rdata", dnsResponse(query, dnsTypeTXT, []byte{255, 'a', 'b'}), ""},
+		{"no answer", dnsResponse(query, dnsTypeTXT), ""},
+		{"other record type", dnsResponse(query, dnsTypePTR, txt("x")), ""},
+		{"truncated rdata", full[:len(full)-4], ""},
+		{"truncated record", full[:len(query)+6], ""},
+		{"truncated header", full[:8], ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeDNSVersion(tt.resp); got != tt.want {
+				t.Errorf("decodeDNSVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMDNS(t *testing.T) {
+	query := mdnsServicesQuery()
+	name := func(labels ...string) []byte {
+		var b []byte
+		for _, l := range labels {
+			b = append(b, byte(len(l)))
+			b = append(b, l...)
+		}
+		return append(b, 0)
+	}
+	// "_ipp._tcp" followed by a pointer to "local" in the question.
+	compressed := append(name("_ipp", "_tcp")[:10], 0xc0, byte(12+len(name("_services", "_dns-sd", "_udp"))-1))
+
+	tests := []struct {
+		name string
+		resp []byte
+		want string
+	}{
+		{"services", dnsResponse(query, dnsTypePTR, name("_http", "_tcp", "local"), compressed), "_http._tcp, _ipp._tcp"},
+		{"no answer", dnsResponse(query, dnsTypePTR), ""},
+		{"pointer loop", dnsResponse(query, dnsTypePTR, []byte{0xc0, byte(len(query) + 12)}), ""},
+		{"truncated", dnsResponse(query, dnsTypePTR, name("_http", "_tcp", "local"))[:len(query)+14], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeMDNS(tt.resp); got != tt.want {
+				t.Errorf("decodeMDNS() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeNTP(t *testing.T) {
+	reply := func(version, stratum byte, refid string) []byte {
+		b := make([]byte, 48)
+		b[0] = version<<3 | 4
+		b[1] = stratum
+		copy(b[12:16], refid)
+		return b
+	}
+
+	tests := []struct {
+		name string
+		resp []byte
+		want string
+	}{
+		{"primary", reply(4, 1, "GPS"), "NTPv4, stratum 1, refid GPS"},
+		{"secondary", reply(3, 2, "\x0a\x00\x00\x01"), "NTPv3, stratum 2"},
+		{"truncated", reply(4, 1, "GPS")[:47], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeNTP(tt.resp); got != tt.want {
+				t.Errorf("decodeNTP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeNetBIOS(t *testing.T) {
+	entry := func(name string, suffix byte, group bool) []byte {
+		b := []byte(name + strings.Repeat(" ", 15-len(name)))
+		b = append(b, suffix, 0x04, 0x00)
+		if group {
+			b[16] |= 0x80
+		}
+		return b
+	}
+	status := func(entries ...[]byte) []byte {
+		b := append([]byte(nil), netbiosStatusQuery()...)
+		b = append(b[:len(b)-4], 0x00, 0x21, 0x00, 0x01) // answer name and type, class
+		b = append(b, 0, 0, 0, 0)                        // TTL
+		b = append(b, 0, 0)                              // rdlength, unchecked
+		b = append(b, byte(len(entries)))
+		for _, e := range entries {
+			b = append(b, e...)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		resp []byte
+		want string
+	}{
+		{"name and workgroup", status(entry("FILESERVER", 0x20, false), entry("FILESERVER", 0x00, false), entry("OFFICE", 0x00, true)), "FILESERVER, workgroup OFFICE"},
+		{"name only", status(entry("PRINTER", 0x00, false)), "PRINTER"},
+		{"no entries", status(), ""},
+		{"count beyond data", status(entry("PRINTER", 0x00, false))[:len(status())+10], ""},
+		{"truncated", netbiosStatusQuery()[:20], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeNetBIOS(tt.resp); got != tt.want {
+				t.Errorf("decodeNetBIOS() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSNMP(t *testing.T) {
+	response := func(descr []byte) []byte {
+		varbind := ber(0x30, sysDescrOID, descr)
+		return ber(0x30, []byte{0x02, 0x01, 0x00}, ber(0x04, []byte("public")), ber(0xa2, ber(0x30, varbind)))
+	}
+	long := strings.Repeat("x", 200)
+	longForm := append([]byte{0x04, 0x81, 200}, long...)
+
+	tests := []struct {
+		name string
+		resp []byte
+		want string
+	}{
+		{"short form", response(ber(0x04, []byte("Linux gw 6.1.0\nsecond line"))), "Linux gw 6.1.0"},
+		{"long form", append(append([]byte(nil), sysDescrOID...), longForm...), long[:maxBanner]},
+		{"length beyond data", append(append([]byte(nil), sysDescrOID...), 0x04, 0x20, 'R', 'o', 'u', 't', 'e', 'r'), "Router"},
+		{"bad length of length", append(append([]byte(nil), sysDescrOID...), 0x04, 0x85, 1), ""},
+		{"not a string", response([]byte{0x05, 0x00}), ""},
+		{"no sysDescr", []byte{0x30, 0x00}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeSNMP(tt.resp); got != tt.want {
+				t.Errorf("decodeSNMP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSSDP(t *testing.T) {
+	tests := []struct {
+		name string
+		resp string
+		want string
+	}{
+		{"server", "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nserver: Linux/3.14 UPnP/1.0 MiniUPnPd/2.1\r\n\r\n", "Linux/3.14 UPnP/1.0 MiniUPnPd/2.1"},
+		{"no server", "HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\n\r\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeSSDP([]byte(tt.resp)); got != tt.want {
+				t.Errorf("decodeSSDP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSQuery(t *testing.T) {
+	q := dnsVersionQuery()
+	want := []byte("\x07version\x04bind\x00\x00\x10\x00\x03")
+	if !bytes.Equal(q[12:], want) {
+		t.Errorf("question = %q, want %q", q[12:], want)
+	}
+	if qd := binary.BigEndian.Uint16(q[4:]); qd != 1 {
+		t.Errorf("QDCOUNT = %d, want 1", qd)
+	}
+}
@@ -0,0 +1,221 @@
+// Package udp implements UDP port scanning for network discovery.
+//
+// Each port gets a protocol-appropriate payload (a DNS query on 53, an
+// NTP request on 123, ...) from a connected UDP socket. A reply means the
+// port is open; an ICMP port unreachable, which the kernel reports on the
+// socket as "connection refused", means it is closed; silence leaves it
+// open|filtered, since many services ignore probes they do not understand.
+package udp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/scanner"
+)
+
+// maxConcurrentPorts bounds the probes in flight to a single host.
+const maxConcurrentPorts = 100
+
+// Scanner implements the Scanner interface for UDP scanning.
+type Scanner struct {
+	timeout time.Duration
+	ports   []int
+	source  network.Source
+}
+
+// New creates a new UDP scanner with the specified timeout, probing
+// DefaultPorts until SetPorts selects others.
+func New(timeout time.Duration) *Scanner {
+	return &Scanner{
+		timeout: timeout,
+		ports:   append([]int(nil), DefaultPorts...),
+	}
+}
+
+// SetPorts sets the ports scanned on each host.
+func (s *Scanner) SetPorts(ports []int) {
+	if len(ports) > 0 {
+		s.ports = ports
+	}
+}
+
+// Ports returns the ports scanned on each host.
+func (s *Scanner) Ports() []int {
+	return s.ports
+}
+
+// SetSource sends probes from a local address, source port or interface,
+// e.g. port 53 to get through a firewall that trusts DNS replies.
+func (s *Scanner) SetSource(src network.Source) {
+	s.source = src
+}
+
+// Name returns the human-readable name of this scanner.
+func (s *Scanner) Name() string {
+	return "UDP Scan"
+}
+
+// Scan probes every configured port of the host. Any open or closed port
+// proves the host is up.
+func (s *Scanner) Scan(ctx context.Context, ip string) scanner.Result {
+	start := time.Now()
+	ports, err := s.scanPorts(ctx, ip)
+	if err != nil {
+		return scanner.Result{
+			IP:       ip,
+			Alive:    false,
+			Method:   s.Name(),
+			Details:  fmt.Sprintf("Cannot send from %s: %v", s.source, err),
+			Duration: time.Since(start),
+		}
+	}
+
+	var open []string
+	counts := make(map[scanner.PortState]int)
+	for _, p := range ports {
+		counts[p.State]++
+		if p.State == scanner.PortOpen {
+			open = append(open, formatPort(p))
+		}
+	}
+	summary := fmt.Sprintf("%d open, %d closed, %d open|filtered",
+		counts[scanner.PortOpen], counts[scanner.PortClosed], counts[scanner.PortOpenFiltered])
+
+	result := scanner.Result{
+		IP:       ip,
+		Alive:    counts[scanner.PortOpen]+counts[scanner.PortClosed] > 0,
+		Method:   s.Name(),
+		Duration: time.Since(start),
+		Ports:    ports,
+	}
+	switch {
+	case len(open) > 0:
+		result.Details = fmt.Sprintf("Ports: %s; %s", strings.Join(open, ", "), summary)
+	case result.Alive:
+		result.Details = fmt.Sprintf("No open ports, host answered with port unreachable; %s", summary)
+	default:
+		result.Details = "No response"
+	}
+	return result
+}
+
+// formatPort formats an open port as "123/ntp"; what the service said
+// is kept in the port's Banner.
+func formatPort(p scanner.Port) string {
+	s := strconv.Itoa(p.Number)
+	if p.Service != "" {
+		s += "/" + p.Service
+	}
+	return s
+}
+
+// scanPorts probes all configured ports concurrently and returns their
+// states in ascending port order. It stops at the first probe that cannot
+// be sent from the source, returning its error.
+func (s *Scanner) scanPorts(ctx context.Context, ip string) ([]scanner.Port, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		ports   []scanner.Port
+		bindErr error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxConcurrentPorts)
+	)
+
+	for _, port := range s.ports {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+			result, err := s.probePort(ctx, ip, p)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && bindErr == nil {
+				bindErr = err
+				cancel()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			ports = append(ports, result)
+		}(port)
+	}
+
+	wg.Wait()
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].Number < ports[j].Number
+	})
+	return ports, bindErr
+}
+
+// probePort sends the port's probe and classifies the answer. Failing to
+// bind the source address or port is returned as an error, since it says
+// nothing about the port.
+func (s *Scanner) probePort(ctx context.Context, ip string, port int) (scanner.Port, error) {
+	result := scanner.Port{Number: port, Protocol: "udp", State: scanner.PortOpenFiltered}
+
+	pr, known := probes[port]
+	payload := []byte{}
+	if known {
+		result.Service = pr.service
+		payload = pr.payload()
+	}
+
+	conn, err := s.source.Dialer(0).DialContext(ctx, "udp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		if network.IsBindError(err) {
+			return result, err
+		}
+		return result, nil
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(payload); err != nil {
+		if isRefused(err) {
+			result.State = scanner.PortClosed
+		}
+		return result, nil
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	switch {
+	case err == nil:
+		result.State = scanner.PortOpen
+		if known {
+			result.Banner = pr.decode(buf[:n])
+		}
+	case isRefused(err):
+		result.State = scanner.PortClosed
+	}
+	return result, nil
+}
+
+// isRefused reports whether err is the ICMP port unreachable reported on
+// a connected UDP socket (ECONNRESET on Windows).
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
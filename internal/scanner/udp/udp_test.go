@@ -0,0 +1,56 @@
+package udp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"maki/internal/network"
+	"maki/internal/scanner"
+)
+
+// echoServer answers every datagram with "pong" from a local port.
+func echoServer(t *testing.T) int {
+	t.Helper()
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			_, from, err := c.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = c.WriteTo([]byte("pong"), from)
+		}
+	}()
+	return c.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestScanPortStates(t *testing.T) {
+	open := echoServer(t)
+	s := New(time.Second)
+	s.SetPorts([]int{open})
+	r := s.Scan(context.Background(), "127.0.0.1")
+	if !r.Alive || len(r.Ports) != 1 || r.Ports[0].State != scanner.PortOpen {
+		t.Errorf("Scan() = alive %v, ports %+v, details %q", r.Alive, r.Ports, r.Details)
+	}
+}
+
+func TestScanSourceBindFailure(t *testing.T) {
+	open := echoServer(t)
+	busy := network.Source{Port: echoServer(t)}
+
+	s := New(time.Second)
+	s.SetSource(busy)
+	s.SetPorts([]int{open})
+	r := s.Scan(context.Background(), "127.0.0.1")
+	if r.Alive || len(r.Ports) != 0 || !strings.HasPrefix(r.Details, "Cannot send from "+busy.String()) || !strings.Contains(r.Details, "address already in use") {
+		t.Errorf("Scan() = alive %v, ports %+v, details %q; want a bind failure", r.Alive, r.Ports, r.Details)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,24 +16,32 @@ import (
 	"maki/internal/network"
 	nmapscan "maki/internal/nmap"
 	"maki/internal/output"
+	"maki/internal/proxy"
 	"maki/internal/scanner"
 	"maki/internal/scanner/arp"
+	"maki/internal/scanner/dns"
 	"maki/internal/scanner/icmp"
+	"maki/internal/scanner/ndp"
 	"maki/internal/scanner/tcp"
+	"maki/internal/scanner/trace"
+	"maki/internal/scanner/udp"
 )
 
 func main() {
 	printBanner()
 
 	// Get subnet from user
-	subnet := getUserInput("Enter target subnet (default: 192.168.1.0/24): ")
+	subnet := getUserInput("Enter target subnet or addresses, IPv4 or IPv6 (default: 192.168.1.0/24): ")
 	if subnet == "" {
 		subnet = "192.168.1.0/24"
 		fmt.Printf("Using default subnet: %s\n", subnet)
 	}
 
+	// Get exclusions from user
+	exclude := getUserInput("Enter addresses to exclude (e.g. 192.168.1.1, 192.168.1.200-192.168.1.254; leave empty for none): ")
+
 	// Parse the subnet
-	targets, err := network.ParseCIDR(subnet)
+	targets, err := network.ParseTargets(subnet, exclude)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -41,16 +52,181 @@ func main() {
 	// Get scan type choice
 	scanChoice := getScanChoice()
 
-	// Get network interface if ARP scan is selected
+	// Get ICMP probe types if the ICMP scan is selected
+	icmpProbes := []icmp.Probe{icmp.ProbeEcho}
+	if scanChoice == "1" || scanChoice == "4" {
+		probes, err := icmp.ParseProbes(getUserInput("\nEnter ICMP probes, comma-separated (echo/timestamp/mask/info, default: echo): "))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		icmpProbes = probes
+	}
+
+	// Get ports if the TCP scan or TCP ping is selected
+	var tcpPorts []int
+	if scanChoice == "2" || scanChoice == "4" || scanChoice == "10" {
+		prompt := "\nEnter TCP ports (e.g. 22,80,8000-8100, top100, - for all; default: common ports): "
+		if scanChoice == "10" {
+			prompt = fmt.Sprintf("\nEnter TCP ping ports (default: %s): ", formatPortList(tcp.DefaultPingPorts))
+		}
+		if input := getUserInput(prompt); input != "" {
+			ports, err := tcp.ParsePorts(input)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			tcpPorts = ports
+		}
+	}
+
+	// Ask whether to route the TCP scan or TCP ping through a proxy
+	var tcpProxy *proxy.Dialer
+	if scanChoice == "2" || scanChoice == "4" || scanChoice == "10" {
+		if input := getUserInput("Route TCP connections through a proxy (socks5://[user:pass@]host:port or http://host:port; leave empty for none): "); input != "" {
+			p, err := proxy.New(input)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			tcpProxy = p
+			if scanChoice == "4" {
+				fmt.Println("ℹ️  Only TCP goes through the proxy: the ICMP, ARP, neighbor discovery and reverse DNS scans still run from this machine")
+			}
+		}
+	}
+
+	// Ask whether to use SYN probes for the TCP scan or TCP ping; raw
+	// packets cannot go through a proxy
+	var tcpSYN bool
+	if (scanChoice == "2" || scanChoice == "4" || scanChoice == "10") && tcpProxy == nil {
+		answer := strings.ToLower(getUserInput("Use SYN (half-open) probes? Needs root on Linux (y/N): "))
+		tcpSYN = answer == "y" || answer == "yes"
+	}
+
+	// Get the probes to run on open TCP ports
+	var portProbes map[string]bool
+	if scanChoice == "2" || scanChoice == "4" {
+		probes, err := parseProbeList(getUserInput("Probe open ports, comma-separated (banner, version, tls, tlsaudit, http, ssh, exposure; leave empty for none): "), tcpPortProbes)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		portProbes = probes
+	}
+
+	// Get ports if the UDP scan is selected
+	var udpPorts []int
+	if scanChoice == "11" {
+		if input := getUserInput(fmt.Sprintf("\nEnter UDP ports (e.g. 53,161,500-520, - for all; default: %s): ", formatPortList(udp.DefaultPorts))); input != "" {
+			ports, err := udp.ParsePorts(input)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			udpPorts = ports
+		}
+	}
+
+	// Get network interface if ARP or neighbor discovery is selected
 	var networkInterface string
-	if scanChoice == "3" || scanChoice == "4" {
-		networkInterface = getUserInput("\nEnter network interface for ARP scan (e.g., eth0, wlan0): ")
+	if scanChoice == "3" || scanChoice == "4" || scanChoice == "5" {
+		networkInterface = getUserInput("\nEnter network interface for ARP/neighbor discovery (e.g., eth0, wlan0): ")
 		if networkInterface == "" {
-			fmt.Println("Error: Network interface is required for ARP scan")
+			fmt.Println("Error: Network interface is required for ARP and neighbor discovery scans")
 			os.Exit(1)
 		}
 	}
 
+	// Get DNS server if reverse DNS is selected
+	var dnsServer string
+	if scanChoice == "4" || scanChoice == "6" {
+		dnsServer = getUserInput("\nEnter DNS server for reverse lookups (leave empty for system resolver): ")
+	}
+
+	// Get probe type if traceroute is selected
+	var traceMode trace.Mode
+	if scanChoice == "7" {
+		traceMode = trace.Mode(strings.ToLower(getUserInput("\nEnter traceroute probe type (udp/icmp/tcp, default: udp): ")))
+	}
+
+	// Get send rate if the ICMP sweep is selected
+	sweepRate := icmp.DefaultSweepRate
+	if scanChoice == "8" {
+		if input := getUserInput(fmt.Sprintf("\nEnter sweep rate in packets/second (default: %d): ", icmp.DefaultSweepRate)); input != "" {
+			rate, err := strconv.Atoi(input)
+			if err != nil || rate <= 0 {
+				fmt.Println("Error: Sweep rate must be a positive number")
+				os.Exit(1)
+			}
+			sweepRate = rate
+		}
+	}
+
+	// Get probe count, interval and sort order if latency monitoring is selected
+	monitorCount := icmp.DefaultMonitorCount
+	monitorInterval := icmp.DefaultMonitorInterval
+	latencySort := output.SortByLoss
+	if scanChoice == "9" {
+		if input := getUserInput(fmt.Sprintf("\nEnter probes per host (default: %d): ", icmp.DefaultMonitorCount)); input != "" {
+			count, err := strconv.Atoi(input)
+			if err != nil || count <= 0 {
+				fmt.Println("Error: Probe count must be a positive number")
+				os.Exit(1)
+			}
+			monitorCount = count
+		}
+		if input := getUserInput(fmt.Sprintf("Enter interval between probes in milliseconds (default: %d): ", icmp.DefaultMonitorInterval.Milliseconds())); input != "" {
+			ms, err := strconv.Atoi(input)
+			if err != nil || ms <= 0 {
+				fmt.Println("Error: Interval must be a positive number")
+				os.Exit(1)
+			}
+			monitorInterval = time.Duration(ms) * time.Millisecond
+		}
+		if input := strings.ToLower(getUserInput("Sort results by (ip/loss/avg/max/jitter, default: loss): ")); input != "" {
+			switch key := output.LatencySort(input); key {
+			case output.SortByIP, output.SortByLoss, output.SortByAvg, output.SortByMax, output.SortByJitter:
+				latencySort = key
+			default:
+				fmt.Println("Error: Unknown sort key")
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Get the source address, port and interface for the scans that send
+	// their own probes
+	var source network.Source
+	switch scanChoice {
+	case "1", "2", "4", "8", "9", "10", "11":
+		addr := getUserInput("\nSend probes from (source address and/or port, e.g. 10.0.0.5, 10.0.0.5:53, :53; leave empty for automatic): ")
+		iface := getUserInput("Bind probes to interface (e.g. eth0.20, Linux only; leave empty for automatic): ")
+		// Check that the source can be bound for the protocols sent from
+		// it; proxied connections only take its address.
+		var networks []string
+		switch {
+		case scanChoice == "11":
+			networks = []string{"udp"}
+		case scanChoice == "2" || scanChoice == "4" || scanChoice == "10":
+			if tcpProxy == nil {
+				networks = []string{"tcp"}
+			}
+		}
+		src, err := network.ParseSource(addr, iface, networks...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		source = src
+		switch {
+		case source.Port != 0 && (scanChoice == "1" || scanChoice == "8" || scanChoice == "9"):
+			fmt.Println("ℹ️  ICMP has no ports: the source port is ignored")
+		case !source.IsZero() && scanChoice == "4":
+			fmt.Println("ℹ️  The source applies to the ICMP and TCP scans: the ARP, neighbor discovery and reverse DNS scans ignore it")
+		}
+	}
+
 	// Ask for output directory
 	outputDir := getUserInput("\nEnter output directory path (leave empty to skip file export): ")
 
@@ -64,18 +240,34 @@ func main() {
 
 	switch scanChoice {
 	case "1":
-		runICMPScan(ctx, targets, report, timeout)
+		runICMPScan(ctx, targets, report, timeout, icmpProbes, source)
 	case "2":
-		runTCPScan(ctx, targets, report, timeout)
+		runTCPScan(ctx, targets, report, timeout, tcpPorts, tcpSYN, tcpProxy, source, portProbes)
 	case "3":
 		runARPScan(ctx, targets, report, arpTimeout, networkInterface)
 	case "4":
-		runICMPScan(ctx, targets, report, timeout)
-		runTCPScan(ctx, targets, report, timeout)
-		runARPScan(ctx, targets, report, arpTimeout, networkInterface)
+		runICMPScan(ctx, targets, report, timeout, icmpProbes, source)
+		runTCPScan(ctx, targets, report, timeout, tcpPorts, tcpSYN, tcpProxy, source, portProbes)
+		arpResults := runARPScan(ctx, targets, report, arpTimeout, networkInterface)
+		runNDPScan(ctx, report, timeout, networkInterface, arpResults)
+		runDNSScan(ctx, targets, report, timeout, dnsServer)
+	case "5":
+		runNDPScan(ctx, report, timeout, networkInterface, nil)
+	case "6":
+		runDNSScan(ctx, targets, report, timeout, dnsServer)
+	case "7":
+		runTraceScan(ctx, targets, report, timeout, traceMode)
+	case "8":
+		runICMPSweep(ctx, targets, report, timeout, sweepRate, source)
+	case "9":
+		runLatencyMonitor(ctx, targets, report, timeout, monitorCount, monitorInterval, latencySort, source)
+	case "10":
+		runTCPPing(ctx, targets, report, timeout, tcpPorts, tcpSYN, tcpProxy, source)
+	case "11":
+		runUDPScan(ctx, targets, report, timeout, udpPorts, source)
 	default:
 		fmt.Println("Invalid choice. Defaulting to ICMP scan.")
-		runICMPScan(ctx, targets, report, timeout)
+		runICMPScan(ctx, targets, report, timeout, icmpProbes, source)
 	}
 
 	// Export to file if path provided
@@ -88,14 +280,29 @@ func main() {
 			hostsPath := filepath.Join(savedDir, "hosts.txt")
 			fmt.Printf("\n✅ Results saved to: %s\n", filePath)
 			fmt.Printf("✅ Host list saved to: %s (use with `nmap -iL %s`)\n", hostsPath, hostsPath)
-
-			maybeRunNmap(hostsPath, savedDir, subnet, len(report.UniqueHosts()))
+			if scanChoice == "9" {
+				fmt.Printf("✅ Latency statistics saved to: %s\n", filepath.Join(savedDir, "latency.csv"))
+			}
+			for _, c := range report.HostKeyChanges {
+				fmt.Printf("⚠️  SSH host key of %s changed since the last run (%s): %s -> %s\n", c.Host, c.Type, c.OldFingerprint, c.NewFingerprint)
+			}
+
+			if jsonPath, err := nmapscan.Export(report, savedDir); err != nil {
+				fmt.Printf("❌ Error saving network map: %v\n", err)
+			} else {
+				fmt.Printf("✅ Network map saved to: %s (open with web/index.html)\n", jsonPath)
+			}
+
+			if tcpProxy != nil {
+				fmt.Println("ℹ️  nmap does not use the proxy: it can only map hosts this machine reaches directly")
+			}
+			maybeRunNmap(hostsPath, savedDir, subnet, report)
 		}
 	}
 }
 
-func maybeRunNmap(hostsPath, outputDir, subnet string, aliveCount int) {
-	if aliveCount == 0 {
+func maybeRunNmap(hostsPath, outputDir, subnet string, report *output.Report) {
+	if len(report.UniqueHosts()) == 0 {
 		return
 	}
 
@@ -108,7 +315,7 @@ func maybeRunNmap(hostsPath, outputDir, subnet string, aliveCount int) {
 	fmt.Println("\n🗺️  Running nmap -A -F (this may take a while)...")
 	fmt.Println()
 
-	_, jsonPath, err := nmapscan.Run(hostsPath, outputDir, subnet)
+	_, jsonPath, err := nmapscan.Run(hostsPath, outputDir, subnet, report)
 	if err != nil {
 		fmt.Printf("\n❌ nmap scan failed: %v\n", err)
 		return
@@ -138,15 +345,25 @@ func getScanChoice() string {
 	fmt.Println("  2. TCP Connect Scan (common ports)")
 	fmt.Println("  3. ARP Scan (local network)")
 	fmt.Println("  4. All Scans Combined")
+	fmt.Println("  5. IPv6 Neighbor Discovery (local link)")
+	fmt.Println("  6. Reverse DNS Lookup")
+	fmt.Println("  7. Traceroute (path and topology)")
+	fmt.Println("  8. Fast ICMP Ping Sweep (large ranges)")
+	fmt.Println("  9. Latency & Packet Loss Monitor")
+	fmt.Println(" 10. TCP Ping (fast discovery on a few ports)")
+	fmt.Println(" 11. UDP Scan (DNS, NTP, NetBIOS, SNMP, SSDP, mDNS)")
 	fmt.Println()
-	return getUserInput("Enter your choice (1-4): ")
+	return getUserInput("Enter your choice (1-11): ")
 }
 
-func runICMPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration) {
+func runICMPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, probes []icmp.Probe, src network.Source) {
 	fmt.Println("\n🏓 Starting ICMP Ping Scan...")
 	fmt.Println()
 
 	icmpScanner := icmp.New(timeout)
+	icmpScanner.SetProbes(probes)
+	icmpScanner.SetSource(src)
+	defer icmpScanner.Close()
 	scanEngine := engine.New(icmpScanner, 0)
 	results := scanEngine.Scan(ctx, targets)
 
@@ -154,28 +371,263 @@ func runICMPScan(ctx context.Context, targets []string, report *output.Report, t
 	printResults(results, "ICMP Ping Scan")
 }
 
-func runTCPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration) {
-	fmt.Println("\n🔌 Starting TCP Connect Scan...")
+// runICMPSweep pings all targets from a single socket at a fixed rate.
+// Without native ICMP sockets it degrades to the per-host ICMP scan.
+func runICMPSweep(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, rate int, src network.Source) {
+	fmt.Printf("\n🌊 Starting ICMP Ping Sweep (%d packets/s)...\n", rate)
+	fmt.Println()
+
+	icmpScanner := icmp.New(timeout)
+	icmpScanner.SetSweepRate(rate)
+	icmpScanner.SetSource(src)
+	defer icmpScanner.Close()
+	scanEngine := engine.New(icmpScanner, 0)
+	results := scanEngine.Scan(ctx, targets)
+
+	report.AddScan(output.ScanTypeICMP, results)
+	printResults(results, "ICMP Ping Sweep")
+}
+
+// runLatencyMonitor pings every target count times and reports its RTT
+// statistics and packet loss, sorted by the chosen key.
+func runLatencyMonitor(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, count int, interval time.Duration, sortKey output.LatencySort, src network.Source) {
+	fmt.Printf("\n📈 Starting Latency Monitor (%d probes per host, %v apart)...\n", count, interval)
 	fmt.Println()
 
+	icmpScanner := icmp.New(timeout)
+	icmpScanner.SetMonitor(count, interval)
+	icmpScanner.SetSource(src)
+	defer icmpScanner.Close()
+	scanEngine := engine.New(icmpScanner, 0)
+	results := scanEngine.Scan(ctx, targets)
+	output.SortLatency(results, sortKey)
+
+	report.AddScan(output.ScanTypeLatency, results)
+	printResults(results, "Latency Monitor")
+}
+
+func runTCPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, ports []int, syn bool, via *proxy.Dialer, src network.Source, probes map[string]bool) {
 	tcpScanner := tcp.New(timeout)
+	tcpScanner.SetPorts(ports)
+	tcpScanner.SetSYN(syn)
+	tcpScanner.SetSource(src)
+	if !useProxy(ctx, tcpScanner, via, timeout) {
+		return
+	}
+	tcpScanner.SetBannerGrab(probes["banner"])
+	tcpScanner.SetVersionDetection(probes["version"])
+	tcpScanner.SetTLSProbe(probes["tls"])
+	tcpScanner.SetTLSAudit(probes["tlsaudit"])
+	tcpScanner.SetHTTPProbe(probes["http"])
+	tcpScanner.SetSSHProbe(probes["ssh"])
+	tcpScanner.SetExposureCheck(probes["exposure"])
+	defer tcpScanner.Close()
+
+	fmt.Printf("\n🔌 Starting %s (%d ports)...\n", tcpScanner.Name(), len(tcpScanner.Ports()))
+	fmt.Println()
+
 	scanEngine := engine.New(tcpScanner, 0)
 	results := scanEngine.Scan(ctx, targets)
 
 	report.AddScan(output.ScanTypeTCP, results)
-	printResults(results, "TCP Connect Scan")
+	printResults(results, tcpScanner.Name())
+	if probes["tls"] {
+		printCertificateIssues(results)
+	}
+	if probes["exposure"] {
+		printExposedServices(results)
+	}
+}
+
+// useProxy checks that the proxy, if any, is reachable and accepts maki,
+// and routes the scanner's connections through it. It returns false when
+// the scan should not run.
+func useProxy(ctx context.Context, tcpScanner *tcp.Scanner, via *proxy.Dialer, timeout time.Duration) bool {
+	if via == nil {
+		return true
+	}
+	via.Timeout = timeout
+	if err := via.Verify(ctx); err != nil {
+		fmt.Printf("\n❌ Proxy %s unusable: %v\n", via, err)
+		return false
+	}
+	tcpScanner.SetProxy(via)
+	return true
+}
+
+// runTCPPing marks hosts alive on the first TCP port that answers, open
+// or refused, without scanning the rest.
+func runTCPPing(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, ports []int, syn bool, via *proxy.Dialer, src network.Source) {
+	tcpScanner := tcp.New(timeout)
+	tcpScanner.SetPingMode(true)
+	tcpScanner.SetPorts(ports)
+	tcpScanner.SetSYN(syn)
+	tcpScanner.SetSource(src)
+	if !useProxy(ctx, tcpScanner, via, timeout) {
+		return
+	}
+	defer tcpScanner.Close()
+
+	fmt.Printf("\n⚡ Starting %s (ports %s)...\n", tcpScanner.Name(), formatPortList(tcpScanner.Ports()))
+	fmt.Println()
+
+	scanEngine := engine.New(tcpScanner, 0)
+	results := scanEngine.Scan(ctx, targets)
+
+	report.AddScan(output.ScanTypeTCPPing, results)
+	printResults(results, tcpScanner.Name())
 }
 
-func runARPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, iface string) {
+// runUDPScan probes UDP ports with protocol-specific payloads.
+func runUDPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, ports []int, src network.Source) {
+	udpScanner := udp.New(timeout)
+	udpScanner.SetPorts(ports)
+	udpScanner.SetSource(src)
+
+	fmt.Printf("\n📨 Starting UDP Scan (ports %s)...\n", formatPortList(udpScanner.Ports()))
+	fmt.Println()
+
+	scanEngine := engine.New(udpScanner, 0)
+	results := scanEngine.Scan(ctx, targets)
+
+	report.AddScan(output.ScanTypeUDP, results)
+	printResults(results, "UDP Scan")
+}
+
+func runARPScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, iface string) []scanner.Result {
 	fmt.Printf("\n📡 Starting ARP Scan on interface %s...\n", iface)
 	fmt.Println()
 
+	targets, v6 := network.SplitFamilies(targets)
+	if len(v6) > 0 {
+		fmt.Printf("  Skipping %d IPv6 targets (ARP is IPv4-only)\n\n", len(v6))
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
 	arpScanner := arp.New(timeout, iface)
+	defer arpScanner.Close()
 	scanEngine := engine.New(arpScanner, 0)
 	results := scanEngine.Scan(ctx, targets)
 
 	report.AddScan(output.ScanTypeARP, results)
 	printResults(results, "ARP Scan")
+	return results
+}
+
+// runNDPScan discovers IPv6 neighbors on iface. The whole link is probed
+// via multicast rather than the target list; when ARP results are given,
+// neighbors are matched to IPv4 hosts by MAC address.
+func runNDPScan(ctx context.Context, report *output.Report, timeout time.Duration, iface string, arpResults []scanner.Result) {
+	fmt.Printf("\n🛰️  Starting IPv6 Neighbor Discovery on interface %s...\n", iface)
+	fmt.Println()
+
+	ndpScanner := ndp.New(timeout, iface)
+	results, err := ndpScanner.Discover(ctx)
+	if err != nil {
+		fmt.Printf("❌ Neighbor discovery failed: %v\n", err)
+		return
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return network.LessIP(results[i].IP, results[j].IP)
+	})
+	ndp.Correlate(results, arpResults)
+
+	report.AddScan(output.ScanTypeNDP, results)
+	printResults(results, "Neighbor Discovery")
+}
+
+func runDNSScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, server string) {
+	fmt.Println("\n🏷️  Starting Reverse DNS Lookup...")
+	fmt.Println()
+
+	dnsScanner := dns.New(timeout, server)
+	scanEngine := engine.New(dnsScanner, 0)
+	results := scanEngine.Scan(ctx, targets)
+
+	report.AddScan(output.ScanTypeDNS, results)
+	printResults(results, "Reverse DNS")
+}
+
+func runTraceScan(ctx context.Context, targets []string, report *output.Report, timeout time.Duration, mode trace.Mode) {
+	traceScanner := trace.New(timeout, mode)
+	defer traceScanner.Close()
+
+	fmt.Printf("\n🧭 Starting %s...\n", traceScanner.Name())
+	fmt.Println()
+
+	scanEngine := engine.New(traceScanner, 0)
+	results := scanEngine.Scan(ctx, targets)
+
+	report.AddScan(output.ScanTypeTrace, results)
+	printResults(results, "Traceroute")
+}
+
+// tcpPortProbes are the probes that can be run on open TCP ports.
+var tcpPortProbes = []string{"banner", "version", "tls", "tlsaudit", "http", "ssh", "exposure"}
+
+// parseProbeList parses a comma-separated list of probe names, rejecting
+// names not in allowed.
+func parseProbeList(input string, allowed []string) (map[string]bool, error) {
+	probes := make(map[string]bool)
+	for _, name := range strings.Split(input, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, a := range allowed {
+			if name == a {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown probe %q (want %s)", name, strings.Join(allowed, ", "))
+		}
+		probes[name] = true
+	}
+	return probes, nil
+}
+
+// printCertificateIssues lists the certificates found by the TLS probe
+// that are expired, expiring soon or self-signed.
+func printCertificateIssues(results []scanner.Result) {
+	issues := output.CertificateIssues(results, time.Now())
+	fmt.Println()
+	if len(issues) == 0 {
+		fmt.Println("🔒 No expired, expiring or self-signed certificates found")
+		return
+	}
+	fmt.Printf("⚠️  %d certificate issue(s):\n", len(issues))
+	for _, issue := range issues {
+		fmt.Printf("  %s  %s  %s\n", net.JoinHostPort(issue.IP, strconv.Itoa(issue.Port)), issue.Problem, issue.Cert.Subject)
+	}
+}
+
+// printExposedServices lists the databases and caches that answered
+// without credentials.
+func printExposedServices(results []scanner.Result) {
+	exposed := output.ExposedServices(results)
+	fmt.Println()
+	if len(exposed) == 0 {
+		fmt.Println("🔒 No database or cache answered without authentication")
+		return
+	}
+	fmt.Printf("⚠️  %d service(s) answering without authentication:\n", len(exposed))
+	for _, e := range exposed {
+		fmt.Printf("  %s  %s\n", net.JoinHostPort(e.IP, strconv.Itoa(e.Port)), output.FormatExposure(e.Exposure))
+	}
+}
+
+// formatPortList formats ports as "80,443,22".
+func formatPortList(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, p := range ports {
+		parts[i] = strconv.Itoa(p)
+	}
+	return strings.Join(parts, ",")
 }
 
 func printResults(results []scanner.Result, scanName string) {
@@ -184,11 +636,29 @@ func printResults(results []scanner.Result, scanName string) {
 	fmt.Printf("                    %s RESULTS                    \n", strings.ToUpper(scanName))
 	fmt.Println("════════════════════════════════════════════════════════════════")
 
+	// IPv6 addresses are wider than the 15 columns an IPv4 address needs.
+	width := 15
+	for _, r := range results {
+		if r.Alive && len(r.IP) > width {
+			width = len(r.IP)
+		}
+	}
+
 	aliveCount := 0
 	for _, r := range results {
 		if r.Alive {
 			aliveCount++
-			fmt.Printf("  ✅ %-15s  %s\n", r.IP, r.Details)
+			details := r.Details
+			if r.Hostname != "" {
+				details = strings.TrimSpace(fmt.Sprintf("[%s] %s", r.Hostname, details))
+			}
+			fmt.Printf("  ✅ %-*s  %s\n", width, r.IP, details)
+			for _, p := range output.DescribedPorts(r.Ports) {
+				fmt.Printf("     %-*s  └ %s\n", width, "", output.FormatPort(p))
+				for _, line := range output.PortDetails(p) {
+					fmt.Printf("     %-*s    %s\n", width, "", line)
+				}
+			}
 		}
 	}
 